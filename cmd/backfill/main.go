@@ -0,0 +1,302 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/config"
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/repository"
+	"github.com/prefeitura-rio/app-notification-core/internal/service"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Comando único que normaliza CPF, telefone e email já gravados no banco
+// (membros, subscriptions, notificações e destinatários) e vincula ao destinatário as
+// notificações gravadas antes de recipient_id existir.
+//
+// Uso: go run cmd/backfill/main.go [-dry-run] [-batch-size 500]
+func main() {
+	dryRun := flag.Bool("dry-run", false, "apenas reporta as alterações, sem gravar")
+	batchSize := flag.Int("batch-size", 500, "quantidade de registros por lote")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := config.NewDatabase(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	recipientRepo := repository.NewRecipientRepository(db)
+	b := &backfill{db: db, dryRun: *dryRun, batchSize: *batchSize, recipientRepo: recipientRepo, recipientService: service.NewRecipientService(recipientRepo)}
+
+	b.members()
+	b.subscriptions()
+	b.notifications()
+	b.recipients()
+	b.notificationRecipients()
+
+	log.Printf("Backfill finished (dry-run=%v): %d updated, %d invalid value(s) left untouched", b.dryRun, b.updated, b.invalid)
+	if b.dryRun && b.missingRecipients > 0 {
+		log.Printf("[dry-run] %d notification(s) have no recipient yet; one would be created for each identity", b.missingRecipients)
+	}
+}
+
+type backfill struct {
+	db               *gorm.DB
+	dryRun           bool
+	batchSize        int
+	recipientRepo    repository.RecipientRepository
+	recipientService service.RecipientService
+	updated          int
+	invalid          int
+	// missingRecipients conta, no dry-run, as notificações cujo destinatário seria criado
+	missingRecipients int
+}
+
+// normalize aplica a função de normalização, mantendo o valor original se ele for inválido
+func (b *backfill) normalize(kind, value string, fn func(string) (string, error)) string {
+	if value == "" {
+		return value
+	}
+	normalized, err := fn(value)
+	if err != nil {
+		log.Printf("Invalid %s %q: %v", kind, value, err)
+		b.invalid++
+		return value
+	}
+	return normalized
+}
+
+func (b *backfill) normalizePtr(kind string, value *string, fn func(string) (string, error)) *string {
+	if value == nil || *value == "" {
+		return value
+	}
+	normalized := b.normalize(kind, *value, fn)
+	return &normalized
+}
+
+func (b *backfill) save(tx *gorm.DB, model any, id uuid.UUID, updates map[string]any) error {
+	b.updated++
+	if b.dryRun {
+		log.Printf("[dry-run] %T %s: %v", model, id, updates)
+		return nil
+	}
+	return tx.Model(model).Where("id = ?", id).Updates(updates).Error
+}
+
+func (b *backfill) members() {
+	var members []entity.Member
+	err := b.db.FindInBatches(&members, b.batchSize, func(tx *gorm.DB, batch int) error {
+		for _, m := range members {
+			cpf := b.normalize("CPF", m.CPF, validation.NormalizeCPF)
+			phone := b.normalize("phone", m.Phone, validation.NormalizePhone)
+			email := b.normalize("email", m.Email, validation.NormalizeEmail)
+			if cpf == m.CPF && phone == m.Phone && email == m.Email {
+				continue
+			}
+			if err := b.save(b.db, &entity.Member{}, m.ID, map[string]any{"cpf": cpf, "phone": phone, "email": email}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		log.Fatalf("Failed to backfill members: %v", err)
+	}
+	log.Println("Members normalized")
+}
+
+func (b *backfill) subscriptions() {
+	var subscriptions []entity.Subscription
+	err := b.db.FindInBatches(&subscriptions, b.batchSize, func(tx *gorm.DB, batch int) error {
+		for _, s := range subscriptions {
+			cpf := b.normalize("CPF", s.UserCPF, validation.NormalizeCPF)
+			phone := b.normalize("phone", s.UserPhone, validation.NormalizePhone)
+			email := b.normalize("email", s.UserEmail, validation.NormalizeEmail)
+			if cpf == s.UserCPF && phone == s.UserPhone && email == s.UserEmail {
+				continue
+			}
+			if err := b.save(b.db, &entity.Subscription{}, s.ID, map[string]any{"user_cpf": cpf, "user_phone": phone, "user_email": email}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		log.Fatalf("Failed to backfill subscriptions: %v", err)
+	}
+	log.Println("Subscriptions normalized")
+}
+
+func (b *backfill) notifications() {
+	var notifications []entity.Notification
+	err := b.db.Where("user_cpf IS NOT NULL OR user_phone IS NOT NULL OR user_email IS NOT NULL").
+		FindInBatches(&notifications, b.batchSize, func(tx *gorm.DB, batch int) error {
+			for _, n := range notifications {
+				cpf := b.normalizePtr("CPF", n.UserCPF, validation.NormalizeCPF)
+				phone := b.normalizePtr("phone", n.UserPhone, validation.NormalizePhone)
+				email := b.normalizePtr("email", n.UserEmail, validation.NormalizeEmail)
+				if samePtr(cpf, n.UserCPF) && samePtr(phone, n.UserPhone) && samePtr(email, n.UserEmail) {
+					continue
+				}
+				if err := b.save(b.db, &entity.Notification{}, n.ID, map[string]any{"user_cpf": cpf, "user_phone": phone, "user_email": email}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		log.Fatalf("Failed to backfill notifications: %v", err)
+	}
+	log.Println("Notifications normalized")
+}
+
+// recipients normaliza os destinatários e unifica os que passam a compartilhar um identificador
+func (b *backfill) recipients() {
+	var ids []uuid.UUID
+	if err := b.db.Model(&entity.Recipient{}).Order("created_at ASC").Pluck("id", &ids).Error; err != nil {
+		log.Fatalf("Failed to list recipients: %v", err)
+	}
+
+	for _, id := range ids {
+		recipient, err := b.recipientRepo.FindByID(id)
+		if err != nil {
+			// Já unificado com outro destinatário
+			continue
+		}
+
+		cpf := b.normalizePtr("CPF", recipient.CPF, validation.NormalizeCPF)
+		phone := b.normalizePtr("phone", recipient.Phone, validation.NormalizePhone)
+		email := b.normalizePtr("email", recipient.Email, validation.NormalizeEmail)
+		if samePtr(cpf, recipient.CPF) && samePtr(phone, recipient.Phone) && samePtr(email, recipient.Email) {
+			continue
+		}
+
+		matches, err := b.recipientRepo.FindByIdentifiers(deref(cpf), deref(phone), deref(email))
+		if err != nil {
+			log.Fatalf("Failed to find recipients: %v", err)
+		}
+
+		var existing *entity.Recipient
+		for i := range matches {
+			if matches[i].ID != recipient.ID {
+				existing = &matches[i]
+				break
+			}
+		}
+
+		b.updated++
+		if existing == nil {
+			recipient.CPF, recipient.Phone, recipient.Email = cpf, phone, email
+			if b.dryRun {
+				log.Printf("[dry-run] Recipient %s normalized", recipient.ID)
+				continue
+			}
+			if err := b.recipientRepo.Update(recipient); err != nil {
+				log.Fatalf("Failed to update recipient %s: %v", recipient.ID, err)
+			}
+			continue
+		}
+
+		if existing.CPF != nil && cpf != nil && *existing.CPF != *cpf {
+			log.Printf("Recipient %s conflicts with %s (different CPF), skipping", recipient.ID, existing.ID)
+			b.updated--
+			continue
+		}
+
+		log.Printf("Recipient %s merged into %s", recipient.ID, existing.ID)
+		if b.dryRun {
+			continue
+		}
+		fillPtr(&existing.CPF, cpf)
+		fillPtr(&existing.Phone, phone)
+		fillPtr(&existing.Email, email)
+		if existing.Name == "" {
+			existing.Name = recipient.Name
+		}
+		if err := b.recipientRepo.Merge(existing, []uuid.UUID{recipient.ID}); err != nil {
+			log.Fatalf("Failed to merge recipient %s: %v", recipient.ID, err)
+		}
+	}
+	log.Println("Recipients normalized")
+}
+
+// notificationRecipients preenche recipient_id das notificações individuais gravadas antes da
+// coluna existir, incluindo as cópias por membro dos envios agendados a grupo. Resolve cria e
+// unifica destinatários, então o dry-run apenas busca os existentes e conta os que faltam.
+func (b *backfill) notificationRecipients() {
+	var notifications []entity.Notification
+	err := b.db.Where("recipient_id IS NULL AND group_job = ? AND (user_cpf IS NOT NULL OR user_phone IS NOT NULL OR user_email IS NOT NULL)", false).
+		FindInBatches(&notifications, b.batchSize, func(tx *gorm.DB, batch int) error {
+			for _, n := range notifications {
+				recipient, err := b.notificationRecipient(n)
+				if err != nil {
+					log.Printf("Notification %s: failed to resolve recipient: %v", n.ID, err)
+					b.invalid++
+					continue
+				}
+				if recipient == nil {
+					b.missingRecipients++
+					continue
+				}
+				if err := b.save(b.db, &entity.Notification{}, n.ID, map[string]any{"recipient_id": recipient.ID}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		log.Fatalf("Failed to link notifications to recipients: %v", err)
+	}
+	log.Println("Notifications linked to recipients")
+}
+
+// notificationRecipient resolve o destinatário da notificação. No dry-run, só consulta
+// recipientRepo e retorna nil quando ele ainda não existe.
+func (b *backfill) notificationRecipient(n entity.Notification) (*entity.Recipient, error) {
+	if !b.dryRun {
+		return b.recipientService.Resolve(deref(n.UserCPF), deref(n.UserPhone), deref(n.UserEmail), "")
+	}
+
+	cpf, phone, email, err := validation.NormalizeIdentity(deref(n.UserCPF), deref(n.UserPhone), deref(n.UserEmail))
+	if err != nil {
+		return nil, err
+	}
+	matches, err := b.recipientRepo.FindByIdentifiers(cpf, phone, email)
+	if err != nil || len(matches) == 0 {
+		return nil, err
+	}
+	for i := range matches {
+		if cpf != "" && matches[i].CPF != nil && *matches[i].CPF == cpf {
+			return &matches[i], nil
+		}
+	}
+	return &matches[0], nil
+}
+
+func samePtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func fillPtr(target **string, value *string) {
+	if *target == nil && value != nil {
+		*target = value
+	}
+}
@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	_ "github.com/prefeitura-rio/app-notification-core/docs"
+	"github.com/prefeitura-rio/app-notification-core/internal/command"
 	"github.com/prefeitura-rio/app-notification-core/internal/config"
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
 	"github.com/prefeitura-rio/app-notification-core/internal/handler"
+	"github.com/prefeitura-rio/app-notification-core/internal/outbox"
 	"github.com/prefeitura-rio/app-notification-core/internal/repository"
 	"github.com/prefeitura-rio/app-notification-core/internal/scheduler"
 	"github.com/prefeitura-rio/app-notification-core/internal/service"
 	"github.com/prefeitura-rio/app-notification-core/internal/websocket"
+	"github.com/prefeitura-rio/app-notification-core/internal/worker"
 	"github.com/prefeitura-rio/app-notification-core/pkg/auth"
 	"github.com/prefeitura-rio/app-notification-core/pkg/queue"
 	"github.com/prefeitura-rio/app-notification-core/pkg/utils"
@@ -44,6 +54,13 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	if cfg.Privacy.SubjectHashSecret == "" {
+		log.Fatalf("PRIVACY_SUBJECT_HASH_SECRET is required to issue erasure receipts")
+	}
+
+	// SIGTERM (rollout do Kubernetes) e SIGINT iniciam o encerramento gracioso
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	db, err := config.NewDatabase(cfg.Database)
 	if err != nil {
@@ -53,6 +70,15 @@ func main() {
 	groupRepo := repository.NewGroupRepository(db)
 	notificationRepo := repository.NewNotificationRepository(db)
 	subscriptionRepo := repository.NewSubscriptionRepository(db)
+	recipientRepo := repository.NewRecipientRepository(db)
+	consentRepo := repository.NewConsentRepository(db)
+	privacyRepo := repository.NewPrivacyRepository(db)
+	suppressionRepo := repository.NewSuppressionRepository(db)
+	deliveryRepo := repository.NewDeliveryRepository(db)
+	deadLetterAuditRepo := repository.NewDeadLetterAuditRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	processingLedgerRepo := repository.NewProcessingLedgerRepository(db)
+	recurringScheduleRepo := repository.NewRecurringScheduleRepository(db)
 
 	hub := websocket.NewHub()
 	go hub.Run()
@@ -60,45 +86,123 @@ func main() {
 	mailman := utils.NewMailmanClient(cfg.DataRelay.URL, cfg.DataRelay.Token)
 	webPush := utils.NewWebPushClient(cfg)
 
-	// Conectar ao RabbitMQ
-	rabbitMQ, err := queue.NewRabbitMQClient(cfg)
+	// Conectar à fila (RabbitMQ ou Postgres, conforme QUEUE_BACKEND)
+	messageQueue, err := queue.New(cfg, db)
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
+		log.Fatalf("Failed to connect to queue: %v", err)
 	}
-	defer rabbitMQ.Close()
-
-	groupService := service.NewGroupService(groupRepo)
-	notificationService := service.NewNotificationService(notificationRepo, groupRepo, subscriptionRepo, hub, mailman, webPush, rabbitMQ)
 
-	// Iniciar scheduler de notificações agendadas
-	notificationScheduler := scheduler.NewNotificationScheduler(notificationRepo, notificationService)
-	notificationScheduler.Start()
-	defer notificationScheduler.Stop()
+	// Relay do outbox: publica na fila as notificações gravadas pelo serviço
+	outboxRelay := outbox.NewRelay(outboxRepo, notificationRepo, messageQueue, cfg.Outbox.PollInterval, cfg.Outbox.BatchSize, cfg.Outbox.Retention, cfg.Outbox.MaxPending, cfg.Outbox.MaxAttempts)
+	outboxRelay.Start()
 
-	// Iniciar workers para processar a fila
+	groupService := service.NewGroupService(groupRepo)
+	recipientService := service.NewRecipientService(recipientRepo)
+	consentService := service.NewConsentService(consentRepo, cfg.Consent.RequiredPurposes)
+	privacyService := service.NewPrivacyService(privacyRepo, consentRepo, recipientService, cfg.Privacy.SubjectHashSecret)
+	suppressionService := service.NewSuppressionService(suppressionRepo)
+	emailEventService := service.NewEmailEventService(deliveryRepo, suppressionService)
+	deadLetterService := service.NewDeadLetterService(messageQueue, deadLetterAuditRepo, notificationRepo)
+	notificationService := service.NewNotificationService(notificationRepo, groupRepo, subscriptionRepo, recipientService, consentService, suppressionService, deliveryRepo, processingLedgerRepo, hub, mailman, webPush, outboxRepo, outboxRelay, messageQueue)
+
+	recurringScheduleService := service.NewRecurringScheduleService(recurringScheduleRepo, groupRepo, notificationService, consentService)
+
+	// Iniciar scheduler de notificações agendadas e recorrentes
+	notificationScheduler := scheduler.NewNotificationScheduler(notificationRepo, notificationService, recurringScheduleService, cfg.Scheduler.BatchSize, cfg.Scheduler.ClaimLease)
+	notificationScheduler.Start(ctx)
+
+	// Os workers param de receber mensagens quando workerCtx é cancelado e concluem a que
+	// estão processando
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workerGroup sync.WaitGroup
+
+	// Workers de fan-out, com um pool por prioridade para que envios em massa de baixa
+	// prioridade não atrasem os urgentes, e de entrega, com um pool por canal para que um
+	// canal lento (ex: relay de email) não atrase os demais. O supervisor ajusta cada pool
+	// entre o mínimo e o máximo conforme a profundidade da fila.
 	workers := cfg.RabbitMQ.Workers
 	if workers == 0 {
 		workers = 3 // Default
 	}
-	log.Printf("Starting %d workers to process notifications...", workers)
-	for i := 0; i < workers; i++ {
-		workerID := i + 1
-		go func(id int) {
-			log.Printf("Worker %d started", id)
-			rabbitMQ.ConsumeNotifications(func(msg *queue.NotificationMessage) error {
-				return notificationService.ProcessNotification(msg.Notification)
-			})
-		}(workerID)
+	lanePools := map[entity.NotificationPriority][2]int{
+		entity.PriorityHigh:   {cfg.RabbitMQ.WorkersHigh, cfg.RabbitMQ.WorkersHighMax},
+		entity.PriorityNormal: {workers, cfg.RabbitMQ.WorkersMax},
+		entity.PriorityLow:    {cfg.RabbitMQ.WorkersLow, cfg.RabbitMQ.WorkersLowMax},
+	}
+	supervisor := worker.NewSupervisor(messageQueue, cfg.Worker.ScaleInterval, cfg.Worker.BacklogPerWorker, cfg.Worker.MaxLatency)
+	for _, priority := range entity.Priorities {
+		priority := priority
+		supervisor.AddPool(worker.Pool{
+			Name: string(priority),
+			Min:  lanePools[priority][0],
+			Max:  lanePools[priority][1],
+			// O consumer é reiniciado automaticamente após quedas de conexão
+			Consume: func(ctx context.Context, handler func(*queue.NotificationMessage) error) error {
+				return messageQueue.ConsumeNotifications(ctx, priority, handler)
+			},
+			Handle: func(msg *queue.NotificationMessage) error {
+				return notificationService.FanOut(msg.NotificationID)
+			},
+		})
+	}
+	for _, channel := range entity.DeliveryChannels {
+		channel := channel
+		supervisor.AddPool(worker.Pool{
+			Name: string(channel),
+			Min:  cfg.RabbitMQ.Channels[channel].Workers,
+			Max:  cfg.RabbitMQ.Channels[channel].MaxWorkers,
+			Consume: func(ctx context.Context, handler func(*queue.NotificationMessage) error) error {
+				return messageQueue.ConsumeChannel(ctx, channel, handler)
+			},
+			Handle: func(msg *queue.NotificationMessage) error {
+				return notificationService.DeliverLeg(msg.NotificationID, channel, msg.FinalAttempt)
+			},
+		})
+	}
+	workerGroup.Add(1)
+	go func() {
+		defer workerGroup.Done()
+		supervisor.Run(workerCtx)
+	}()
+
+	// Consumers de comandos de envio publicados por outros sistemas na exchange
+	// notifications.commands (apenas no backend rabbitmq)
+	if cfg.RabbitMQ.CommandsEnabled {
+		if rabbit, ok := messageQueue.(*queue.RabbitMQClient); ok {
+			commandConsumer := command.NewConsumer(rabbit, notificationService, repository.NewProcessedCommandRepository(db))
+			commandWorkers := cfg.RabbitMQ.CommandWorkers
+			if commandWorkers <= 0 {
+				commandWorkers = 1
+			}
+			log.Printf("Starting %d command consumers...", commandWorkers)
+			for i := 0; i < commandWorkers; i++ {
+				workerGroup.Add(1)
+				go func(id int) {
+					defer workerGroup.Done()
+					err := commandConsumer.Run(workerCtx)
+					log.Printf("Command consumer %d stopped: %v", id, err)
+				}(i + 1)
+			}
+		} else {
+			log.Printf("Warning: RABBITMQ_COMMANDS_ENABLED requires QUEUE_BACKEND=rabbitmq, command consumer not started")
+		}
 	}
 
 	groupHandler := handler.NewGroupHandler(groupService)
-	notificationHandler := handler.NewNotificationHandler(notificationService)
-	scheduledNotificationHandler := handler.NewScheduledNotificationHandler(notificationRepo)
-	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionRepo)
-	wsHandler := handler.NewWebSocketHandler(hub)
+	notificationHandler := handler.NewNotificationHandler(notificationService, recipientService)
+	scheduledNotificationHandler := handler.NewScheduledNotificationHandler(notificationRepo, notificationService)
+	recurringScheduleHandler := handler.NewRecurringScheduleHandler(recurringScheduleService)
+	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionRepo, recipientService)
+	recipientHandler := handler.NewRecipientHandler(recipientService)
+	consentHandler := handler.NewConsentHandler(consentService, recipientService)
+	privacyHandler := handler.NewPrivacyHandler(privacyService)
+	suppressionHandler := handler.NewSuppressionHandler(suppressionService)
+	emailWebhookHandler := handler.NewEmailWebhookHandler(emailEventService, cfg.EmailWebhook.Secret)
+	wsHandler := handler.NewWebSocketHandler(hub, recipientService)
 	integrationHandler := handler.NewIntegrationHandler(cfg)
-	queueHandler := handler.NewQueueHandler(rabbitMQ)
-	healthHandler := handler.NewHealthHandler(db, rabbitMQ)
+	queueHandler := handler.NewQueueHandler(messageQueue, supervisor, outboxRelay)
+	deadLetterHandler := handler.NewDeadLetterHandler(deadLetterService)
+	healthHandler := handler.NewHealthHandler(db, messageQueue)
 
 	gin.SetMode(cfg.Server.Mode)
 	router := gin.Default()
@@ -133,6 +237,7 @@ func main() {
 			notifications.PUT("/:id", notificationHandler.Update)
 			notifications.DELETE("/:id", notificationHandler.Delete)
 			notifications.POST("/:id/read", notificationHandler.MarkAsRead)
+			notifications.GET("/:id/deliveries", notificationHandler.GetDeliveries)
 
 			notifications.GET("/cpf/:cpf", notificationHandler.GetByCPF)
 			notifications.GET("/phone/:phone", notificationHandler.GetByPhone)
@@ -147,9 +252,55 @@ func main() {
 		scheduledNotifications := v1.Group("/scheduled-notifications")
 		{
 			scheduledNotifications.GET("", scheduledNotificationHandler.ListScheduled)
+			scheduledNotifications.PATCH("/:id", scheduledNotificationHandler.UpdateScheduled)
 			scheduledNotifications.POST("/:id/cancel", scheduledNotificationHandler.CancelScheduled)
 		}
 
+		recurringSchedules := v1.Group("/recurring-schedules")
+		{
+			recurringSchedules.POST("", recurringScheduleHandler.Create)
+			recurringSchedules.GET("", recurringScheduleHandler.List)
+			recurringSchedules.GET("/:id", recurringScheduleHandler.Get)
+			recurringSchedules.GET("/:id/occurrences", recurringScheduleHandler.Occurrences)
+			recurringSchedules.POST("/:id/pause", recurringScheduleHandler.Pause)
+			recurringSchedules.POST("/:id/resume", recurringScheduleHandler.Resume)
+			recurringSchedules.DELETE("/:id", recurringScheduleHandler.Delete)
+		}
+
+		recipients := v1.Group("/recipients")
+		{
+			recipients.GET("/lookup", recipientHandler.Lookup)
+			recipients.GET("/:id", recipientHandler.Get)
+			recipients.PUT("/:id/timezone", recipientHandler.SetTimezone)
+			recipients.GET("/:id/consents", consentHandler.History)
+			recipients.GET("/:id/consents/current", consentHandler.Current)
+		}
+
+		v1.POST("/consents", auth.OptionalJWTMiddleware(), consentHandler.Record)
+
+		privacy := v1.Group("/privacy")
+		{
+			privacy.GET("/subjects/:cpf/export", privacyHandler.Export)
+			privacy.GET("/subjects/:cpf/receipts", privacyHandler.ListReceipts)
+			privacy.DELETE("/subjects/:cpf", auth.OptionalJWTMiddleware(), privacyHandler.Erase)
+			privacy.GET("/receipts/:id", privacyHandler.GetReceipt)
+		}
+
+		suppressions := v1.Group("/suppressions")
+		{
+			suppressions.POST("", suppressionHandler.Create)
+			suppressions.GET("", suppressionHandler.List)
+			suppressions.POST("/import", suppressionHandler.Import)
+			suppressions.GET("/check", suppressionHandler.Check)
+			suppressions.GET("/:id", suppressionHandler.Get)
+			suppressions.DELETE("/:id", suppressionHandler.Delete)
+		}
+
+		webhooks := v1.Group("/webhooks")
+		{
+			webhooks.POST("/email", emailWebhookHandler.Receive)
+		}
+
 		v1.GET("/ws", wsHandler.ServeWS)
 
 		subscriptions := v1.Group("/subscriptions")
@@ -169,6 +320,10 @@ func main() {
 		{
 			queue.GET("/stats", queueHandler.GetStats)
 			queue.POST("/purge", queueHandler.PurgeQueue)
+			queue.GET("/dlq", deadLetterHandler.List)
+			queue.POST("/dlq/replay", auth.OptionalJWTMiddleware(), deadLetterHandler.Replay)
+			queue.POST("/dlq/discard", auth.OptionalJWTMiddleware(), deadLetterHandler.Discard)
+			queue.GET("/dlq/audit", deadLetterHandler.Audit)
 		}
 	}
 
@@ -180,9 +335,67 @@ func main() {
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	addr := cfg.Server.Host + ":" + cfg.Server.Port
-	log.Printf("Server starting on %s", addr)
-	if err := router.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{Addr: addr, Handler: router}
+	go func() {
+		log.Printf("Server starting on %s", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Printf("Shutting down (timeout %s)...", cfg.Server.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// 1. Parar de aceitar requisições e aguardar as em andamento
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown: %v", err)
+	}
+
+	// 2. Parar a liberação de agendadas e a publicação do outbox; o que ficar pendente é
+	// retomado por outra instância
+	if err := notificationScheduler.Stop(shutdownCtx); err != nil {
+		log.Printf("Scheduler did not finish before the deadline: %v", err)
+	}
+	outboxRelay.Stop()
+
+	// 3. Workers deixam de receber mensagens e concluem as em processamento dentro do prazo;
+	// as que não concluírem voltam para a fila e o ledger evita envios repetidos
+	stopWorkers()
+	if err := waitGroup(shutdownCtx, &workerGroup); err != nil {
+		log.Printf("Workers did not finish before the deadline: %v", err)
+	}
+
+	// 4. Close frames para os clientes WebSocket reconectarem em outra instância
+	if err := hub.Shutdown(shutdownCtx); err != nil {
+		log.Printf("WebSocket shutdown: %v", err)
+	}
+
+	// 5. Conexões
+	if err := messageQueue.Close(); err != nil {
+		log.Printf("Failed to close queue: %v", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	log.Println("Shutdown complete")
+}
+
+// waitGroup aguarda o WaitGroup até o prazo do contexto
+func waitGroup(ctx context.Context, group *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		group.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
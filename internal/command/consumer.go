@@ -0,0 +1,335 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/repository"
+	"github.com/prefeitura-rio/app-notification-core/internal/service"
+	"github.com/prefeitura-rio/app-notification-core/pkg/queue"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Transport recebe os comandos e publica as respostas (implementado por queue.RabbitMQClient)
+type Transport interface {
+	ConsumeCommands(ctx context.Context, handler func(*queue.CommandDelivery) error) error
+	PublishEvent(routingKey, correlationID, replyTo string, body []byte) error
+}
+
+// Consumer valida os comandos de envio recebidos da exchange notifications.commands e os
+// encaminha ao NotificationService, publicando uma resposta com os IDs das notificações
+// criadas. Comandos inválidos recebem uma resposta "rejected" e vão para a DLQ de comandos;
+// erros temporários devolvem o comando para a fila. Comandos já processados (mesmo
+// command_id) recebem a resposta gravada, sem criar as notificações de novo. O ID de cada
+// notificação deriva do command_id e da posição do destinatário, então a reentrega de um
+// lote interrompido (ex: pod encerrado durante o AwaitCapacity) não envia de novo aos
+// destinatários já processados.
+type Consumer struct {
+	transport     Transport
+	notifications service.NotificationService
+	processed     repository.ProcessedCommandRepository
+}
+
+func NewConsumer(transport Transport, notifications service.NotificationService, processed repository.ProcessedCommandRepository) *Consumer {
+	return &Consumer{transport: transport, notifications: notifications, processed: processed}
+}
+
+// Run consome os comandos até o contexto ser cancelado
+func (c *Consumer) Run(ctx context.Context) error {
+	return c.transport.ConsumeCommands(ctx, func(delivery *queue.CommandDelivery) error {
+		return c.handle(ctx, delivery)
+	})
+}
+
+func (c *Consumer) handle(ctx context.Context, delivery *queue.CommandDelivery) error {
+	envelope, err := decodeEnvelope(delivery.Body)
+	reply := &Reply{
+		Version:         SchemaVersion,
+		CommandID:       delivery.MessageID,
+		NotificationIDs: []uuid.UUID{},
+	}
+	if envelope != nil {
+		reply.Type = envelope.Type
+		if envelope.CommandID != "" {
+			reply.CommandID = envelope.CommandID
+		}
+	}
+
+	if err == nil && reply.CommandID != "" {
+		previous, findErr := c.processed.FindByID(reply.CommandID)
+		switch {
+		case findErr == nil:
+			log.Printf("Command consumer: Command %s already processed, replaying its reply", reply.CommandID)
+			c.reply(delivery, storedReply(previous))
+			return nil
+		case !errors.Is(findErr, gorm.ErrRecordNotFound):
+			return findErr
+		}
+	}
+
+	if err == nil {
+		log.Printf("Command consumer: Processing %s command %s from %q", envelope.Type, reply.CommandID, envelope.Source)
+		switch envelope.Type {
+		case TypeSend:
+			err = c.send(envelope.Payload, reply)
+		case TypeSendBatch:
+			err = c.sendBatch(ctx, envelope.Payload, reply)
+		}
+	}
+
+	if err != nil {
+		if !isInvalid(err) {
+			// Erro temporário: nada foi criado e o comando volta para a fila
+			return err
+		}
+		reply.Status = StatusRejected
+		reply.Errors = []string{err.Error()}
+		c.reply(delivery, reply)
+		if errors.Is(err, errInvalid) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", errInvalid, err)
+	}
+
+	c.record(reply)
+	c.reply(delivery, reply)
+	log.Printf("Command consumer: Command %s %s (%d notification(s))", reply.CommandID, reply.Status, len(reply.NotificationIDs))
+	return nil
+}
+
+// record grava o resultado do comando para responder às reentregas. Como a resposta, uma falha
+// aqui não devolve o comando para a fila.
+func (c *Consumer) record(reply *Reply) {
+	if reply.CommandID == "" {
+		return
+	}
+	err := c.processed.Create(&entity.ProcessedCommand{
+		CommandID:       reply.CommandID,
+		Type:            reply.Type,
+		Status:          reply.Status,
+		NotificationIDs: reply.NotificationIDs,
+		Total:           reply.Total,
+		Succeeded:       reply.Succeeded,
+		Failed:          reply.Failed,
+		Errors:          reply.Errors,
+	})
+	if err != nil {
+		log.Printf("Command consumer: Failed to record command %s: %v", reply.CommandID, err)
+	}
+}
+
+// storedReply reconstrói a resposta de um comando já processado
+func storedReply(command *entity.ProcessedCommand) *Reply {
+	return &Reply{
+		Version:         SchemaVersion,
+		CommandID:       command.CommandID,
+		Type:            command.Type,
+		Status:          command.Status,
+		NotificationIDs: command.NotificationIDs,
+		Total:           command.Total,
+		Succeeded:       command.Succeeded,
+		Failed:          command.Failed,
+		Errors:          command.Errors,
+	}
+}
+
+// send processa notification.send: uma notificação para um cidadão
+func (c *Consumer) send(payload json.RawMessage, reply *Reply) error {
+	var cmd SendNotification
+	if err := decodePayload(payload, &cmd); err != nil {
+		return err
+	}
+	if err := validateContent(cmd.Title, cmd.Message, cmd.Type, cmd.Priority); err != nil {
+		return err
+	}
+	if err := validateIdentity(cmd.CPF, cmd.Phone, cmd.Email); err != nil {
+		return err
+	}
+	scheduledFor, err := parseSchedule(cmd.IsScheduled, cmd.ScheduledFor, cmd.LocalTime)
+	if err != nil {
+		return err
+	}
+
+	notification := &entity.Notification{
+		Title:        cmd.Title,
+		Message:      cmd.Message,
+		Type:         entity.NotificationType(cmd.Type),
+		Purpose:      cmd.Purpose,
+		Priority:     entity.NotificationPriority(cmd.Priority),
+		Data:         cmd.Data,
+		IsHTML:       cmd.IsHTML,
+		IsScheduled:  cmd.IsScheduled || cmd.LocalTime != nil,
+		ScheduledFor: scheduledFor,
+		LocalTime:    cmd.LocalTime,
+		ExpiresAt:    cmd.ExpiresAt,
+	}
+	notification.ID = notificationID(reply.CommandID, 0)
+	if err := c.sendToUser(cmd.CPF, cmd.Phone, cmd.Email, notification); err != nil {
+		return err
+	}
+
+	reply.Status = StatusAccepted
+	reply.NotificationIDs = append(reply.NotificationIDs, notification.ID)
+	reply.Total, reply.Succeeded = 1, 1
+	return nil
+}
+
+// sendBatch processa notification.send_batch. Como no endpoint HTTP, a falha de um
+// destinatário não interrompe os demais e é reportada na resposta, e os envios imediatos
+// aguardam a fila ter espaço antes de cada destinatário. Se nenhuma notificação foi criada
+// por causa de um erro temporário (incluindo a fila saturada), o comando volta para a fila.
+func (c *Consumer) sendBatch(ctx context.Context, payload json.RawMessage, reply *Reply) error {
+	var cmd SendBatch
+	if err := decodePayload(payload, &cmd); err != nil {
+		return err
+	}
+	if err := validateContent(cmd.Title, cmd.Message, cmd.Type, cmd.Priority); err != nil {
+		return err
+	}
+	if len(cmd.Recipients) == 0 {
+		return fmt.Errorf("%w: recipients must not be empty", errInvalid)
+	}
+	scheduledFor, err := parseSchedule(cmd.IsScheduled, cmd.ScheduledFor, cmd.LocalTime)
+	if err != nil {
+		return err
+	}
+	isScheduled := cmd.IsScheduled || cmd.LocalTime != nil
+
+	reply.Total = len(cmd.Recipients)
+	var temporaryErr error
+	for i, recipient := range cmd.Recipients {
+		if !isScheduled {
+			if err := c.notifications.AwaitCapacity(ctx); err != nil {
+				if reply.Succeeded == 0 && reply.Failed == 0 {
+					return err
+				}
+				remaining := len(cmd.Recipients) - i
+				reply.Failed += remaining
+				reply.Errors = append(reply.Errors, strconv.Itoa(remaining)+" recipient(s) not sent: "+err.Error())
+				log.Printf("Command consumer: Batch stopped with %d recipient(s) left: %v", remaining, err)
+				break
+			}
+		}
+
+		notification := &entity.Notification{
+			Title:        cmd.Title,
+			Message:      cmd.Message,
+			Type:         entity.NotificationType(cmd.Type),
+			Purpose:      cmd.Purpose,
+			Priority:     entity.NotificationPriority(cmd.Priority),
+			Data:         cmd.Data,
+			IsHTML:       cmd.IsHTML,
+			IsScheduled:  isScheduled,
+			ScheduledFor: scheduledFor,
+			LocalTime:    cmd.LocalTime,
+			ExpiresAt:    cmd.ExpiresAt,
+		}
+		notification.ID = notificationID(reply.CommandID, i)
+
+		err := validateIdentity(recipient.CPF, recipient.Phone, recipient.Email)
+		if err == nil {
+			err = c.sendToUser(recipient.CPF, recipient.Phone, recipient.Email, notification)
+		}
+		if err != nil {
+			if !isInvalid(err) && temporaryErr == nil {
+				temporaryErr = err
+			}
+			reply.Failed++
+			reply.Errors = append(reply.Errors, recipientLabel(i, recipient)+": "+err.Error())
+			log.Printf("Command consumer: Failed to send to recipient %d: %v", i+1, err)
+			continue
+		}
+		reply.Succeeded++
+		reply.NotificationIDs = append(reply.NotificationIDs, notification.ID)
+	}
+
+	switch {
+	case reply.Succeeded == 0 && temporaryErr != nil:
+		return temporaryErr
+	case reply.Failed == 0:
+		reply.Status = StatusAccepted
+	case reply.Succeeded == 0:
+		reply.Status = StatusFailed
+	default:
+		reply.Status = StatusPartial
+	}
+	return nil
+}
+
+// commandNamespace é o namespace dos IDs de notificação derivados dos comandos
+var commandNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/prefeitura-rio/app-notification-core/commands"))
+
+// notificationID deriva o ID da notificação do command_id e da posição do destinatário no
+// comando. Sem command_id, o ID é gerado na gravação.
+func notificationID(commandID string, index int) uuid.UUID {
+	if commandID == "" {
+		return uuid.Nil
+	}
+	return uuid.NewSHA1(commandNamespace, []byte(commandID+":"+strconv.Itoa(index)))
+}
+
+// sendToUser cria a notificação do destinatário. Se a notificação com o ID derivado já existe
+// (reentrega do comando), o destinatário já foi processado e nada é enviado de novo.
+func (c *Consumer) sendToUser(cpf, phone, email string, notification *entity.Notification) error {
+	id := notification.ID
+	err := c.notifications.SendToUser(cpf, phone, email, notification)
+	if !errors.Is(err, gorm.ErrDuplicatedKey) || id == uuid.Nil {
+		return err
+	}
+	if _, findErr := c.notifications.GetNotification(id); findErr != nil {
+		return err
+	}
+	log.Printf("Command consumer: Notification %s already created by a previous delivery, skipping", id)
+	notification.ID = id
+	return nil
+}
+
+// reply publica a resposta. Uma falha aqui não devolve o comando para a fila, o que
+// criaria as notificações de novo.
+func (c *Consumer) reply(delivery *queue.CommandDelivery, reply *Reply) {
+	reply.ProcessedAt = time.Now()
+	body, err := json.Marshal(reply)
+	if err != nil {
+		log.Printf("Command consumer: Failed to marshal reply for command %s: %v", reply.CommandID, err)
+		return
+	}
+
+	correlationID := delivery.CorrelationID
+	if correlationID == "" {
+		correlationID = reply.CommandID
+	}
+	if err := c.transport.PublishEvent("command."+reply.Status, correlationID, delivery.ReplyTo, body); err != nil {
+		log.Printf("Command consumer: Failed to publish reply for command %s: %v", reply.CommandID, err)
+	}
+}
+
+// isInvalid indica se o erro é do próprio comando (e não temporário)
+func isInvalid(err error) bool {
+	return errors.Is(err, errInvalid) ||
+		validation.IsValidationError(err) ||
+		errors.Is(err, service.ErrInvalidPriority) ||
+		errors.Is(err, service.ErrInvalidExpiry) ||
+		errors.Is(err, service.ErrInvalidLocalTime)
+}
+
+func recipientLabel(index int, recipient BatchRecipient) string {
+	switch {
+	case recipient.Name != "":
+		return recipient.Name
+	case recipient.CPF != "":
+		return "CPF " + recipient.CPF
+	case recipient.Phone != "":
+		return "Phone " + recipient.Phone
+	case recipient.Email != "":
+		return "Email " + recipient.Email
+	}
+	return "Recipient " + strconv.Itoa(index+1)
+}
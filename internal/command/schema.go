@@ -0,0 +1,179 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/pkg/queue"
+	"github.com/google/uuid"
+)
+
+// SchemaVersion é a versão do formato dos comandos e respostas. Mudanças incompatíveis
+// recebem uma nova versão; comandos de versões desconhecidas são rejeitados.
+const SchemaVersion = 1
+
+// Tipos de comando
+const (
+	TypeSend      = "notification.send"
+	TypeSendBatch = "notification.send_batch"
+)
+
+// Status da resposta, também usados na routing key do evento (command.<status>)
+const (
+	// StatusAccepted: todas as notificações foram criadas
+	StatusAccepted = "accepted"
+	// StatusPartial: lote com falha em parte dos destinatários
+	StatusPartial = "partial"
+	// StatusFailed: nenhuma notificação do lote foi criada
+	StatusFailed = "failed"
+	// StatusRejected: comando inválido, enviado para a DLQ de comandos
+	StatusRejected = "rejected"
+)
+
+// errInvalid marca erros do próprio comando (corpo, versão ou campos); o transporte envia
+// esses comandos para a DLQ de comandos
+var errInvalid = queue.ErrInvalidCommand
+
+// Envelope é o corpo de todo comando publicado em notifications.commands
+type Envelope struct {
+	Version int    `json:"version"`
+	Type    string `json:"type"`
+	// CommandID identifica o comando na resposta e nas reentregas; se ausente, usa o message_id AMQP
+	CommandID string `json:"command_id,omitempty"`
+	// Source identifica o sistema de origem, apenas para logs
+	Source  string          `json:"source,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// SendNotification espelha SendNotificationRequest (POST /notifications/send/user)
+type SendNotification struct {
+	Title        string         `json:"title"`
+	Message      string         `json:"message"`
+	Type         string         `json:"type"`
+	Purpose      string         `json:"purpose,omitempty"`
+	Priority     string         `json:"priority,omitempty"`
+	Data         map[string]any `json:"data,omitempty"`
+	CPF          string         `json:"cpf,omitempty"`
+	Phone        string         `json:"phone,omitempty"`
+	Email        string         `json:"email,omitempty"`
+	IsHTML       bool           `json:"is_html,omitempty"`
+	IsScheduled  bool           `json:"is_scheduled,omitempty"`
+	ScheduledFor *string        `json:"scheduled_for,omitempty"` // RFC3339
+	LocalTime    *string        `json:"local_time,omitempty"`    // horário de parede no fuso do destinatário, ex: 2026-11-03T09:00:00
+	ExpiresAt    *time.Time     `json:"expires_at,omitempty"`
+}
+
+// BatchRecipient espelha o destinatário de SendBatchRequest
+type BatchRecipient struct {
+	CPF   string `json:"cpf,omitempty"`
+	Phone string `json:"phone,omitempty"`
+	Email string `json:"email,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+// SendBatch espelha SendBatchRequest (POST /notifications/send/batch)
+type SendBatch struct {
+	Title        string           `json:"title"`
+	Message      string           `json:"message"`
+	Type         string           `json:"type"`
+	Purpose      string           `json:"purpose,omitempty"`
+	Priority     string           `json:"priority,omitempty"`
+	Data         map[string]any   `json:"data,omitempty"`
+	IsHTML       bool             `json:"is_html,omitempty"`
+	IsScheduled  bool             `json:"is_scheduled,omitempty"`
+	ScheduledFor *string          `json:"scheduled_for,omitempty"` // RFC3339
+	LocalTime    *string          `json:"local_time,omitempty"`    // horário de parede no fuso de cada destinatário
+	ExpiresAt    *time.Time       `json:"expires_at,omitempty"`
+	Recipients   []BatchRecipient `json:"recipients"`
+}
+
+// Reply é o evento publicado em notifications.events (e no reply_to do comando, se houver)
+type Reply struct {
+	Version         int         `json:"version"`
+	CommandID       string      `json:"command_id"`
+	Type            string      `json:"type,omitempty"`
+	Status          string      `json:"status"`
+	NotificationIDs []uuid.UUID `json:"notification_ids"`
+	Total           int         `json:"total"`
+	Succeeded       int         `json:"succeeded"`
+	Failed          int         `json:"failed"`
+	Errors          []string    `json:"errors,omitempty"`
+	ProcessedAt     time.Time   `json:"processed_at"`
+}
+
+// decodeEnvelope lê o envelope e verifica versão e tipo
+func decodeEnvelope(body []byte) (*Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: malformed JSON: %v", errInvalid, err)
+	}
+	if envelope.Version != SchemaVersion {
+		return &envelope, fmt.Errorf("%w: unsupported version %d (expected %d)", errInvalid, envelope.Version, SchemaVersion)
+	}
+	switch envelope.Type {
+	case TypeSend, TypeSendBatch:
+	default:
+		return &envelope, fmt.Errorf("%w: unknown type %q", errInvalid, envelope.Type)
+	}
+	if len(envelope.Payload) == 0 {
+		return &envelope, fmt.Errorf("%w: payload is required", errInvalid)
+	}
+	return &envelope, nil
+}
+
+// decodePayload lê o payload no formato do tipo do comando, rejeitando campos desconhecidos
+func decodePayload(payload json.RawMessage, target any) error {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("%w: invalid payload: %v", errInvalid, err)
+	}
+	return nil
+}
+
+// validateContent verifica os campos comuns aos dois comandos
+func validateContent(title, message, notificationType, priority string) error {
+	if title == "" || message == "" || notificationType == "" {
+		return fmt.Errorf("%w: title, message and type are required", errInvalid)
+	}
+	if priority != "" && !entity.IsValidPriority(entity.NotificationPriority(priority)) {
+		return fmt.Errorf("%w: priority must be high, normal or low", errInvalid)
+	}
+	switch entity.NotificationType(notificationType) {
+	case entity.TypeInApp, entity.TypePush, entity.TypeEmail, entity.TypeBoth, entity.TypeAll:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown notification type %q", errInvalid, notificationType)
+}
+
+// validateIdentity exige ao menos um identificador do cidadão. O formato é validado pelo
+// serviço ao resolver o destinatário.
+func validateIdentity(cpf, phone, email string) error {
+	if strings.TrimSpace(cpf) == "" && strings.TrimSpace(phone) == "" && strings.TrimSpace(email) == "" {
+		return fmt.Errorf("%w: at least one of cpf, phone or email is required", errInvalid)
+	}
+	return nil
+}
+
+// parseSchedule valida scheduled_for como nos endpoints HTTP; local_time é validado pelo
+// serviço no fuso de cada destinatário
+func parseSchedule(isScheduled bool, scheduledFor, localTime *string) (*time.Time, error) {
+	if localTime != nil && scheduledFor != nil {
+		return nil, fmt.Errorf("%w: use either scheduled_for or local_time", errInvalid)
+	}
+	if !isScheduled || scheduledFor == nil {
+		return nil, nil
+	}
+	scheduledTime, err := time.Parse(time.RFC3339, *scheduledFor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid scheduled_for format, use RFC3339", errInvalid)
+	}
+	if scheduledTime.Before(time.Now()) {
+		return nil, fmt.Errorf("%w: scheduled_for must be in the future", errInvalid)
+	}
+	return &scheduledTime, nil
+}
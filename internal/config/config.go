@@ -2,7 +2,10 @@ package config
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
 	"github.com/spf13/viper"
 )
 
@@ -12,12 +15,22 @@ type Config struct {
 	WebPush  WebPushConfig
 	DataRelay DataRelayConfig
 	RabbitMQ RabbitMQConfig
+	Queue    QueueConfig
+	Outbox   OutboxConfig
+	Worker   WorkerConfig
+	Scheduler SchedulerConfig
+	Consent  ConsentConfig
+	Privacy  PrivacyConfig
+	EmailWebhook EmailWebhookConfig
 }
 
 type ServerConfig struct {
 	Port string
 	Host string
 	Mode string
+	// ShutdownTimeout é o prazo, após SIGTERM, para concluir as requisições e as mensagens
+	// em processamento antes de fechar as conexões
+	ShutdownTimeout time.Duration
 }
 
 type DatabaseConfig struct {
@@ -44,6 +57,117 @@ type RabbitMQConfig struct {
 	URL                string
 	QueueNotifications string
 	Workers            int
+	// WorkersHigh e WorkersLow são os workers dedicados às filas de prioridade alta e baixa;
+	// Workers atende a prioridade normal
+	WorkersHigh int
+	WorkersLow  int
+	// WorkersMax, WorkersHighMax e WorkersLowMax limitam o crescimento de cada pool pelo
+	// supervisor de workers; abaixo do mínimo, o pool tem tamanho fixo
+	WorkersMax     int
+	WorkersHighMax int
+	WorkersLowMax  int
+	// PublishTimeout é o prazo para o broker confirmar cada publicação
+	PublishTimeout time.Duration
+	// PublishChannels é o número de canais confirm que publicam em paralelo
+	PublishChannels int
+	// RetryDelays são os atrasos das filas de retry, em ordem (ex: 30s, 5m, 30m).
+	// Tentativas além da quantidade de atrasos usam o último.
+	RetryDelays []time.Duration
+	// MaxAttempts é o total de tentativas (incluindo a primeira) antes da DLQ
+	MaxAttempts int
+	// MaxLength é o limite de mensagens de cada fila de prioridade e de canal; cheia, a fila
+	// recusa novas publicações (vale também para o backend postgres)
+	MaxLength int
+	// ManagementURL é a API de gerenciamento (ex: http://rabbitmq:15672), usada para aplicar
+	// MaxLength e o overflow reject-publish por política. Vazia, a política não é aplicada.
+	ManagementURL string
+	// Channels configura a fila de cada canal de entrega, alimentada pelos workers das filas
+	// de prioridade
+	Channels map[entity.DeliveryChannel]ChannelQueueConfig
+	// CommandsEnabled habilita o consumo de comandos de envio da exchange
+	// notifications.commands; CommandWorkers é a quantidade de consumers
+	CommandsEnabled bool
+	CommandWorkers  int
+}
+
+// ChannelQueueConfig configura a fila e o pool de workers de um canal de entrega, lidos de
+// RABBITMQ_<CANAL>_* (ex: RABBITMQ_EMAIL_WORKERS, RABBITMQ_IN_APP_PREFETCH)
+type ChannelQueueConfig struct {
+	// Workers é o mínimo do pool e MaxWorkers o limite de crescimento
+	Workers    int
+	MaxWorkers int
+	Prefetch   int
+	// RetryDelays e MaxAttempts substituem, no canal, os valores gerais; vazios usam
+	// RABBITMQ_RETRY_DELAYS e RABBITMQ_MAX_ATTEMPTS
+	RetryDelays []time.Duration
+	MaxAttempts int
+}
+
+// QueueConfig escolhe o backend da fila. Workers, atrasos de retry e tentativas vêm de
+// RabbitMQConfig e valem para os dois backends.
+type QueueConfig struct {
+	// Backend é "rabbitmq" (padrão) ou "postgres"
+	Backend string
+	// PollInterval é o intervalo de consulta da fila vazia no backend postgres
+	PollInterval time.Duration
+	// LockTimeout é o prazo de processamento no backend postgres: mensagens travadas por
+	// mais tempo (ex: worker derrubado) voltam a ser entregues
+	LockTimeout time.Duration
+}
+
+type OutboxConfig struct {
+	// PollInterval é o intervalo entre as verificações do outbox (novas notificações da
+	// própria instância acordam o relay imediatamente)
+	PollInterval time.Duration
+	// BatchSize é a quantidade de mensagens publicadas por transação
+	BatchSize int
+	// Retention é por quanto tempo as mensagens já publicadas são mantidas
+	Retention time.Duration
+	// MaxPending é a quantidade de mensagens aguardando publicação a partir da qual novos
+	// envios são recusados com 429
+	MaxPending int
+	// MaxAttempts é a quantidade de tentativas de publicação de uma mensagem antes de ela ser
+	// separada (parked_at) para não travar as demais
+	MaxAttempts int
+}
+
+// WorkerConfig controla o supervisor que ajusta o tamanho dos pools de workers
+type WorkerConfig struct {
+	// ScaleInterval é o intervalo entre as avaliações de cada pool
+	ScaleInterval time.Duration
+	// BacklogPerWorker é a quantidade de mensagens na fila por worker a partir da qual o
+	// pool cresce
+	BacklogPerWorker int
+	// MaxLatency é o tempo médio de processamento acima do qual o pool cresce enquanto
+	// houver mensagens na fila
+	MaxLatency time.Duration
+}
+
+// SchedulerConfig controla a reserva das notificações agendadas, que permite rodar o
+// scheduler em várias réplicas
+type SchedulerConfig struct {
+	// BatchSize é a quantidade de notificações reservadas por vez
+	BatchSize int
+	// ClaimLease é o prazo da reserva: notificações reservadas por uma instância que caiu
+	// voltam a ser processadas depois dele
+	ClaimLease time.Duration
+}
+
+type ConsentConfig struct {
+	// RequiredPurposes lista as finalidades que exigem consentimento explícito (opt-in).
+	// As demais são entregues a menos que o cidadão tenha revogado o consentimento.
+	RequiredPurposes []string
+}
+
+type PrivacyConfig struct {
+	// SubjectHashSecret é a chave do HMAC que identifica o titular nos comprovantes de
+	// eliminação. Sem ela, o hash de um CPF (espaço pequeno) seria revertido por força bruta.
+	SubjectHashSecret string
+}
+
+type EmailWebhookConfig struct {
+	// Secret autentica os eventos recebidos em /webhooks/email. Vazio desabilita o webhook.
+	Secret string
 }
 
 func Load() (*Config, error) {
@@ -55,9 +179,45 @@ func Load() (*Config, error) {
 	viper.SetDefault("SERVER_PORT", "8080")
 	viper.SetDefault("SERVER_HOST", "0.0.0.0")
 	viper.SetDefault("SERVER_MODE", "debug")
+	viper.SetDefault("SERVER_SHUTDOWN_TIMEOUT", "25s")
 	viper.SetDefault("DB_HOST", "localhost")
 	viper.SetDefault("DB_PORT", "5432")
 	viper.SetDefault("DB_SSLMODE", "disable")
+	viper.SetDefault("CONSENT_REQUIRED_PURPOSES", "marketing")
+	viper.SetDefault("RABBITMQ_WORKERS_HIGH", 3)
+	viper.SetDefault("RABBITMQ_WORKERS_LOW", 1)
+	viper.SetDefault("RABBITMQ_WORKERS_MAX", 10)
+	viper.SetDefault("RABBITMQ_WORKERS_HIGH_MAX", 10)
+	viper.SetDefault("RABBITMQ_WORKERS_LOW_MAX", 3)
+	viper.SetDefault("RABBITMQ_PUBLISH_TIMEOUT", "5s")
+	viper.SetDefault("RABBITMQ_PUBLISH_CHANNELS", 8)
+	viper.SetDefault("RABBITMQ_RETRY_DELAYS", "30s,5m,30m")
+	viper.SetDefault("RABBITMQ_MAX_ATTEMPTS", 4)
+	viper.SetDefault("RABBITMQ_MAX_LENGTH", 100000)
+	viper.SetDefault("RABBITMQ_IN_APP_WORKERS", 2)
+	viper.SetDefault("RABBITMQ_IN_APP_MAX_WORKERS", 6)
+	viper.SetDefault("RABBITMQ_IN_APP_PREFETCH", 20)
+	viper.SetDefault("RABBITMQ_PUSH_WORKERS", 3)
+	viper.SetDefault("RABBITMQ_PUSH_MAX_WORKERS", 10)
+	viper.SetDefault("RABBITMQ_PUSH_PREFETCH", 10)
+	viper.SetDefault("RABBITMQ_EMAIL_WORKERS", 3)
+	viper.SetDefault("RABBITMQ_EMAIL_MAX_WORKERS", 10)
+	viper.SetDefault("RABBITMQ_EMAIL_PREFETCH", 5)
+	viper.SetDefault("RABBITMQ_COMMANDS_ENABLED", false)
+	viper.SetDefault("RABBITMQ_COMMAND_WORKERS", 1)
+	viper.SetDefault("QUEUE_BACKEND", "rabbitmq")
+	viper.SetDefault("QUEUE_POLL_INTERVAL", "1s")
+	viper.SetDefault("QUEUE_LOCK_TIMEOUT", "5m")
+	viper.SetDefault("OUTBOX_POLL_INTERVAL", "1s")
+	viper.SetDefault("OUTBOX_BATCH_SIZE", 100)
+	viper.SetDefault("OUTBOX_RETENTION", "24h")
+	viper.SetDefault("OUTBOX_MAX_PENDING", 10000)
+	viper.SetDefault("OUTBOX_MAX_ATTEMPTS", 20)
+	viper.SetDefault("WORKER_SCALE_INTERVAL", "10s")
+	viper.SetDefault("WORKER_BACKLOG_PER_WORKER", 100)
+	viper.SetDefault("WORKER_MAX_LATENCY", "2s")
+	viper.SetDefault("SCHEDULER_BATCH_SIZE", 100)
+	viper.SetDefault("SCHEDULER_CLAIM_LEASE", "5m")
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
@@ -65,11 +225,33 @@ func Load() (*Config, error) {
 		}
 	}
 
+	retryDelays, err := parseDurations(viper.GetString("RABBITMQ_RETRY_DELAYS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RABBITMQ_RETRY_DELAYS: %w", err)
+	}
+
+	channels := map[entity.DeliveryChannel]ChannelQueueConfig{}
+	for _, channel := range entity.DeliveryChannels {
+		prefix := "RABBITMQ_" + strings.ToUpper(strings.ReplaceAll(string(channel), "-", "_")) + "_"
+		delays, err := parseDurations(viper.GetString(prefix + "RETRY_DELAYS"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %sRETRY_DELAYS: %w", prefix, err)
+		}
+		channels[channel] = ChannelQueueConfig{
+			Workers:     viper.GetInt(prefix + "WORKERS"),
+			MaxWorkers:  viper.GetInt(prefix + "MAX_WORKERS"),
+			Prefetch:    viper.GetInt(prefix + "PREFETCH"),
+			RetryDelays: delays,
+			MaxAttempts: viper.GetInt(prefix + "MAX_ATTEMPTS"),
+		}
+	}
+
 	config := &Config{
 		Server: ServerConfig{
-			Port: viper.GetString("SERVER_PORT"),
-			Host: viper.GetString("SERVER_HOST"),
-			Mode: viper.GetString("SERVER_MODE"),
+			Port:            viper.GetString("SERVER_PORT"),
+			Host:            viper.GetString("SERVER_HOST"),
+			Mode:            viper.GetString("SERVER_MODE"),
+			ShutdownTimeout: viper.GetDuration("SERVER_SHUTDOWN_TIMEOUT"),
 		},
 		Database: DatabaseConfig{
 			Host:     viper.GetString("DB_HOST"),
@@ -92,8 +274,79 @@ func Load() (*Config, error) {
 			URL:                viper.GetString("RABBITMQ_URL"),
 			QueueNotifications: viper.GetString("RABBITMQ_QUEUE_NOTIFICATIONS"),
 			Workers:            viper.GetInt("RABBITMQ_WORKERS"),
+			WorkersHigh:        viper.GetInt("RABBITMQ_WORKERS_HIGH"),
+			WorkersLow:         viper.GetInt("RABBITMQ_WORKERS_LOW"),
+			WorkersMax:         viper.GetInt("RABBITMQ_WORKERS_MAX"),
+			WorkersHighMax:     viper.GetInt("RABBITMQ_WORKERS_HIGH_MAX"),
+			WorkersLowMax:      viper.GetInt("RABBITMQ_WORKERS_LOW_MAX"),
+			PublishTimeout:     viper.GetDuration("RABBITMQ_PUBLISH_TIMEOUT"),
+			PublishChannels:    viper.GetInt("RABBITMQ_PUBLISH_CHANNELS"),
+			RetryDelays:        retryDelays,
+			MaxAttempts:        viper.GetInt("RABBITMQ_MAX_ATTEMPTS"),
+			MaxLength:          viper.GetInt("RABBITMQ_MAX_LENGTH"),
+			ManagementURL:      viper.GetString("RABBITMQ_MANAGEMENT_URL"),
+			Channels:           channels,
+			CommandsEnabled:    viper.GetBool("RABBITMQ_COMMANDS_ENABLED"),
+			CommandWorkers:     viper.GetInt("RABBITMQ_COMMAND_WORKERS"),
+		},
+		Queue: QueueConfig{
+			Backend:      viper.GetString("QUEUE_BACKEND"),
+			PollInterval: viper.GetDuration("QUEUE_POLL_INTERVAL"),
+			LockTimeout:  viper.GetDuration("QUEUE_LOCK_TIMEOUT"),
+		},
+		Outbox: OutboxConfig{
+			PollInterval: viper.GetDuration("OUTBOX_POLL_INTERVAL"),
+			BatchSize:    viper.GetInt("OUTBOX_BATCH_SIZE"),
+			Retention:    viper.GetDuration("OUTBOX_RETENTION"),
+			MaxPending:   viper.GetInt("OUTBOX_MAX_PENDING"),
+			MaxAttempts:  viper.GetInt("OUTBOX_MAX_ATTEMPTS"),
+		},
+		Worker: WorkerConfig{
+			ScaleInterval:    viper.GetDuration("WORKER_SCALE_INTERVAL"),
+			BacklogPerWorker: viper.GetInt("WORKER_BACKLOG_PER_WORKER"),
+			MaxLatency:       viper.GetDuration("WORKER_MAX_LATENCY"),
+		},
+		Scheduler: SchedulerConfig{
+			BatchSize:  viper.GetInt("SCHEDULER_BATCH_SIZE"),
+			ClaimLease: viper.GetDuration("SCHEDULER_CLAIM_LEASE"),
+		},
+		Consent: ConsentConfig{
+			RequiredPurposes: splitList(viper.GetString("CONSENT_REQUIRED_PURPOSES")),
+		},
+		Privacy: PrivacyConfig{
+			SubjectHashSecret: viper.GetString("PRIVACY_SUBJECT_HASH_SECRET"),
+		},
+		EmailWebhook: EmailWebhookConfig{
+			Secret: viper.GetString("EMAIL_WEBHOOK_SECRET"),
 		},
 	}
 
 	return config, nil
 }
+
+// splitList converte uma lista separada por vírgulas em slice, ignorando itens vazios
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseDurations converte uma lista de durações separadas por vírgula (ex: "30s,5m")
+func parseDurations(value string) ([]time.Duration, error) {
+	var durations []time.Duration
+	for _, item := range splitList(value) {
+		d, err := time.ParseDuration(item)
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("delay must be positive: %s", item)
+		}
+		durations = append(durations, d)
+	}
+	return durations, nil
+}
@@ -13,7 +13,10 @@ func NewDatabase(cfg DatabaseConfig) (*gorm.DB, error) {
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		// Violações de unicidade viram gorm.ErrDuplicatedKey
+		TranslateError: true,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -21,8 +24,18 @@ func NewDatabase(cfg DatabaseConfig) (*gorm.DB, error) {
 	if err := db.AutoMigrate(
 		&entity.Group{},
 		&entity.Member{},
+		&entity.Recipient{},
 		&entity.Notification{},
 		&entity.Subscription{},
+		&entity.Consent{},
+		&entity.ErasureReceipt{},
+		&entity.Suppression{},
+		&entity.Delivery{},
+		&entity.DeadLetterAudit{},
+		&entity.OutboxMessage{},
+		&entity.ProcessingEntry{},
+		&entity.RecurringSchedule{},
+		&entity.ProcessedCommand{},
 	); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
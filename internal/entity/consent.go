@@ -0,0 +1,64 @@
+package entity
+
+import (
+	"errors"
+	"time"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ConsentChannel string
+type ConsentAction string
+
+const (
+	ConsentChannelEmail ConsentChannel = "email"
+	ConsentChannelSMS   ConsentChannel = "sms"
+	ConsentChannelPush  ConsentChannel = "push"
+	ConsentChannelInApp ConsentChannel = "in-app"
+
+	ConsentGranted ConsentAction = "granted"
+	ConsentRevoked ConsentAction = "revoked"
+)
+
+// ErrConsentImmutable é retornado ao tentar alterar ou remover um registro do ledger de consentimento
+var ErrConsentImmutable = errors.New("consent records are append-only")
+
+// Consent é um registro imutável do ledger de consentimento (LGPD). O estado atual de um
+// canal/finalidade é sempre o registro mais recente para o destinatário.
+type Consent struct {
+	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
+	RecipientID uuid.UUID      `json:"recipient_id" gorm:"type:uuid;not null;index:idx_consent_lookup"`
+	Channel     ConsentChannel `json:"channel" gorm:"not null;index:idx_consent_lookup"`
+	Purpose     string         `json:"purpose" gorm:"not null;index:idx_consent_lookup"`
+	Action      ConsentAction  `json:"action" gorm:"not null"`
+	Identifier  string         `json:"identifier,omitempty"`
+	Source      string         `json:"source"`
+	IPAddress   string         `json:"ip_address,omitempty"`
+	UserAgent   string         `json:"user_agent,omitempty"`
+	Evidence    map[string]any `json:"evidence,omitempty" gorm:"type:jsonb;serializer:json"`
+	CreatedAt   time.Time      `json:"created_at" gorm:"index"`
+}
+
+func (c *Consent) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+func (c *Consent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrConsentImmutable
+}
+
+func (c *Consent) BeforeDelete(tx *gorm.DB) error {
+	return ErrConsentImmutable
+}
+
+// IsValidConsentChannel indica se o canal é conhecido pelo ledger
+func IsValidConsentChannel(channel ConsentChannel) bool {
+	switch channel {
+	case ConsentChannelEmail, ConsentChannelSMS, ConsentChannelPush, ConsentChannelInApp:
+		return true
+	}
+	return false
+}
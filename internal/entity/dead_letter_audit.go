@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type DeadLetterAction string
+
+const (
+	DeadLetterReplay  DeadLetterAction = "replay"
+	DeadLetterDiscard DeadLetterAction = "discard"
+)
+
+// DeadLetterAudit registra cada ação manual sobre a DLQ: quem fez, quando e quais mensagens
+type DeadLetterAudit struct {
+	ID          uuid.UUID        `json:"id" gorm:"type:uuid;primaryKey"`
+	Action      DeadLetterAction `json:"action" gorm:"not null;index"`
+	All         bool             `json:"all"`
+	Requested   []string         `json:"requested,omitempty" gorm:"type:jsonb;serializer:json"`
+	Processed   []string         `json:"processed" gorm:"type:jsonb;serializer:json"`
+	RequestedBy string           `json:"requested_by"`
+	Reason      string           `json:"reason,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+func (a *DeadLetterAudit) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
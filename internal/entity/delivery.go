@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"time"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type DeliveryChannel string
+type DeliveryStatus string
+
+const (
+	DeliveryChannelInApp DeliveryChannel = "in-app"
+	DeliveryChannelPush  DeliveryChannel = "push"
+	DeliveryChannelEmail DeliveryChannel = "email"
+
+	DeliverySent       DeliveryStatus = "sent"
+	DeliveryFailed     DeliveryStatus = "failed"
+	DeliverySuppressed DeliveryStatus = "suppressed"
+
+	// Status informados pelo provedor de email através do webhook
+	DeliveryDelivered  DeliveryStatus = "delivered"
+	DeliveryBounced    DeliveryStatus = "bounced"
+	DeliveryComplained DeliveryStatus = "complained"
+)
+
+// DeliveryChannels lista os canais de entrega; cada um tem sua própria fila e workers
+var DeliveryChannels = []DeliveryChannel{DeliveryChannelInApp, DeliveryChannelPush, DeliveryChannelEmail}
+
+// Delivery registra o resultado da entrega de uma notificação em um canal para um destino
+// (endereço de email ou endpoint de push). ProviderMessageID relaciona os eventos do provedor
+// de email (entrega, bounce, complaint) à notificação de origem.
+type Delivery struct {
+	ID                uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey"`
+	NotificationID    uuid.UUID       `json:"notification_id" gorm:"type:uuid;not null;index"`
+	Channel           DeliveryChannel `json:"channel" gorm:"not null"`
+	Target            string          `json:"target"`
+	Status            DeliveryStatus  `json:"status" gorm:"not null;index"`
+	Error             string          `json:"error,omitempty"`
+	ProviderMessageID string          `json:"provider_message_id,omitempty" gorm:"index"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
+
+func (d *Delivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"time"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErasureReceipt é o comprovante auditável de uma eliminação de dados (LGPD, art. 18).
+// Não guarda dados pessoais: o titular é identificado apenas pelo HMAC-SHA256 do CPF.
+type ErasureReceipt struct {
+	ID                        uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	SubjectHash               string     `json:"subject_hash" gorm:"not null;index"`
+	RecipientID               *uuid.UUID `json:"recipient_id,omitempty" gorm:"type:uuid"`
+	RequestedBy               string     `json:"requested_by"`
+	Reason                    string     `json:"reason,omitempty"`
+	NotificationsDeleted      int64      `json:"notifications_deleted"`
+	MembershipsDeleted        int64      `json:"memberships_deleted"`
+	SubscriptionsDeleted      int64      `json:"subscriptions_deleted"`
+	RecurringSchedulesDeleted int64      `json:"recurring_schedules_deleted"`
+	ConsentsAnonymised        int64      `json:"consents_anonymised"`
+	RecipientDeleted          bool       `json:"recipient_deleted"`
+	CreatedAt                 time.Time  `json:"created_at"`
+}
+
+func (r *ErasureReceipt) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
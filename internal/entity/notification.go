@@ -8,6 +8,7 @@ import (
 
 type NotificationType string
 type NotificationStatus string
+type NotificationPriority string
 
 const (
 	TypeInApp NotificationType = "in-app"
@@ -16,38 +17,87 @@ const (
 	TypeBoth  NotificationType = "both"
 	TypeAll   NotificationType = "all"
 
-	StatusPending   NotificationStatus = "pending"
-	StatusScheduled NotificationStatus = "scheduled"
-	StatusSent      NotificationStatus = "sent"
-	StatusDelivered NotificationStatus = "delivered"
-	StatusRead      NotificationStatus = "read"
-	StatusFailed    NotificationStatus = "failed"
-	StatusCancelled NotificationStatus = "cancelled"
+	StatusPending    NotificationStatus = "pending"
+	StatusScheduled  NotificationStatus = "scheduled"
+	StatusClaimed    NotificationStatus = "claimed" // agendada, reservada por uma instância do scheduler para envio
+	StatusExpanded   NotificationStatus = "expanded" // envio agendado a grupo: membros resolvidos e notificações individuais criadas
+	StatusSent       NotificationStatus = "sent"
+	StatusDelivered  NotificationStatus = "delivered"
+	StatusRead       NotificationStatus = "read"
+	StatusFailed     NotificationStatus = "failed"
+	StatusCancelled  NotificationStatus = "cancelled"
+	StatusBlocked    NotificationStatus = "blocked"    // nenhum canal com consentimento para a finalidade
+	StatusSuppressed NotificationStatus = "suppressed" // todos os destinos estão na lista de supressão
+	StatusExpired    NotificationStatus = "expired"    // expires_at passou antes do processamento
+
+	// Cada prioridade tem fila e workers próprios: alertas urgentes (ex: Defesa Civil)
+	// não esperam atrás de envios em massa
+	PriorityHigh   NotificationPriority = "high"
+	PriorityNormal NotificationPriority = "normal"
+	PriorityLow    NotificationPriority = "low"
+
+	// PurposeTransactional é a finalidade padrão (avisos de serviço, não exige opt-in)
+	PurposeTransactional = "transactional"
 )
 
+// Priorities lista as prioridades da mais para a menos urgente
+var Priorities = []NotificationPriority{PriorityHigh, PriorityNormal, PriorityLow}
+
+func IsValidPriority(priority NotificationPriority) bool {
+	for _, p := range Priorities {
+		if p == priority {
+			return true
+		}
+	}
+	return false
+}
+
 type Notification struct {
 	ID          uuid.UUID          `json:"id" gorm:"type:uuid;primaryKey"`
 	Title       string             `json:"title" gorm:"not null"`
 	Message     string             `json:"message" gorm:"not null"`
 	Type        NotificationType   `json:"type" gorm:"not null"`
 	Status      NotificationStatus `json:"status" gorm:"default:'pending'"`
+	Purpose     string             `json:"purpose" gorm:"default:'transactional';index"`
+	Priority    NotificationPriority `json:"priority" gorm:"default:'normal';index"`
 	Data        map[string]any     `json:"data,omitempty" gorm:"type:jsonb"`
+	RecipientID *uuid.UUID         `json:"recipient_id,omitempty" gorm:"type:uuid;index;uniqueIndex:idx_notifications_parent_recipient,priority:2"`
 	UserCPF     *string            `json:"user_cpf,omitempty" gorm:"index"`
 	UserPhone   *string            `json:"user_phone,omitempty" gorm:"index"`
 	UserEmail   *string            `json:"user_email,omitempty" gorm:"index"`
 	GroupID     *uuid.UUID         `json:"group_id,omitempty" gorm:"type:uuid;index"`
+	RecurringScheduleID *uuid.UUID `json:"recurring_schedule_id,omitempty" gorm:"type:uuid;index"` // série que gerou a notificação
+	ParentID    *uuid.UUID         `json:"parent_id,omitempty" gorm:"type:uuid;uniqueIndex:idx_notifications_parent_recipient,priority:1"` // envio agendado a grupo que gerou a notificação; no máximo uma por destinatário
+	GroupJob    bool               `json:"group_job" gorm:"default:false"` // envio agendado a grupo, expandido para os membros quando o scheduler o libera
 	Broadcast   bool               `json:"broadcast" gorm:"default:false"`
 	IsHTML      bool               `json:"is_html" gorm:"default:false"`
 	IsScheduled bool               `json:"is_scheduled" gorm:"default:false;index"`
 	ScheduledFor *time.Time        `json:"scheduled_for,omitempty" gorm:"index"`
+	LocalTime    *string           `json:"local_time,omitempty"` // horário de parede pedido no agendamento em horário local
+	Timezone     *string           `json:"timezone,omitempty"`   // fuso do destinatário usado para calcular scheduled_for a partir de local_time
+	Version      int               `json:"version" gorm:"not null;default:1"` // incrementada a cada edição ou reserva do agendamento (concorrência otimista)
+	ClaimedBy    *string           `json:"claimed_by,omitempty"`              // instância do scheduler que reservou a notificação
+	ClaimExpiresAt *time.Time      `json:"claim_expires_at,omitempty" gorm:"index"` // após esse horário outra instância pode retomar a reserva
+	ExpiresAt    *time.Time        `json:"expires_at,omitempty" gorm:"index"` // após esse horário a notificação não é mais enviada
 	CreatedAt   time.Time          `json:"created_at"`
 	UpdatedAt   time.Time          `json:"updated_at"`
 	ReadAt      *time.Time         `json:"read_at,omitempty"`
 }
 
+// LocalTimeLayout é o formato de local_time: data e hora de parede, sem fuso
+const LocalTimeLayout = "2006-01-02T15:04:05"
+
+// IsExpired indica se a notificação perdeu a validade antes de ser enviada
+func (n *Notification) IsExpired(now time.Time) bool {
+	return n.ExpiresAt != nil && !now.Before(*n.ExpiresAt)
+}
+
 func (n *Notification) BeforeCreate(tx *gorm.DB) error {
 	if n.ID == uuid.Nil {
 		n.ID = uuid.New()
 	}
+	if n.Version == 0 {
+		n.Version = 1
+	}
 	return nil
 }
@@ -0,0 +1,33 @@
+package entity
+
+import (
+	"time"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OutboxMessage é gravada na mesma transação da notificação e indica que ela precisa ser
+// publicada na fila. O relay do outbox publica as mensagens pendentes e preenche
+// DispatchedAt, garantindo que toda notificação criada chegue à fila ao menos uma vez.
+// Mensagens que falham são adiadas (NextAttemptAt) e, esgotadas as tentativas, separadas
+// (ParkedAt) para não travar as demais.
+type OutboxMessage struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	NotificationID uuid.UUID  `json:"notification_id" gorm:"type:uuid;not null;index"`
+	Attempts       int        `json:"attempts" gorm:"not null;default:0"`
+	LastError      string     `json:"last_error,omitempty"`
+	ClaimedBy      *string    `json:"claimed_by,omitempty"` // relay que está publicando a mensagem
+	ClaimExpiresAt *time.Time `json:"claim_expires_at,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	ParkedAt       *time.Time `json:"parked_at,omitempty" gorm:"index"`
+	DispatchedAt   *time.Time `json:"dispatched_at,omitempty" gorm:"index"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"index"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (o *OutboxMessage) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
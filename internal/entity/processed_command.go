@@ -0,0 +1,21 @@
+package entity
+
+import (
+	"time"
+	"github.com/google/uuid"
+)
+
+// ProcessedCommand registra o resultado de um comando recebido em notifications.commands.
+// Um comando reentregue (reinício do consumer, publicação repetida pelo sistema de origem)
+// com o mesmo command_id recebe a resposta gravada, sem criar as notificações de novo.
+type ProcessedCommand struct {
+	CommandID       string      `json:"command_id" gorm:"primaryKey"`
+	Type            string      `json:"type"`
+	Status          string      `json:"status" gorm:"not null"`
+	NotificationIDs []uuid.UUID `json:"notification_ids" gorm:"type:jsonb;serializer:json"`
+	Total           int         `json:"total"`
+	Succeeded       int         `json:"succeeded"`
+	Failed          int         `json:"failed"`
+	Errors          []string    `json:"errors,omitempty" gorm:"type:jsonb;serializer:json"`
+	CreatedAt       time.Time   `json:"created_at" gorm:"index"`
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+	"github.com/google/uuid"
+)
+
+type ProcessingState string
+
+const (
+	// ProcessingQueued marca a etapa publicada na fila do canal e ainda não reservada
+	ProcessingQueued     ProcessingState = "queued"
+	ProcessingInProgress ProcessingState = "processing"
+	ProcessingCompleted  ProcessingState = "completed"
+	ProcessingFailed     ProcessingState = "failed"
+)
+
+// ProcessingEntry é o registro, no ledger de processamento, de uma etapa (canal) da entrega
+// de uma notificação. O worker reserva a etapa antes de enviar; reentregas da mesma mensagem
+// encontram a etapa concluída e não repetem o envio. Uma reserva cuja LockedUntil venceu
+// (worker derrubado no meio do envio) pode ser retomada por outro worker; LockedBy identifica
+// a reserva, e só quem a detém conclui ou marca a falha da etapa.
+type ProcessingEntry struct {
+	NotificationID uuid.UUID       `json:"notification_id" gorm:"type:uuid;primaryKey"`
+	Leg            DeliveryChannel `json:"leg" gorm:"primaryKey"`
+	State          ProcessingState `json:"state" gorm:"not null;index"`
+	Attempts       int             `json:"attempts" gorm:"not null;default:0"`
+	LockedUntil    *time.Time      `json:"locked_until,omitempty"`
+	LockedBy       *string         `json:"locked_by,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	CompletedAt    *time.Time      `json:"completed_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
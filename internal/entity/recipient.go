@@ -0,0 +1,28 @@
+package entity
+
+import (
+	"time"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Recipient representa um cidadão, unificando todos os seus identificadores
+// (CPF, telefone e email) e os dispositivos cadastrados para push.
+type Recipient struct {
+	ID            uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
+	CPF           *string        `json:"cpf,omitempty" gorm:"uniqueIndex"`
+	Phone         *string        `json:"phone,omitempty" gorm:"uniqueIndex"`
+	Email         *string        `json:"email,omitempty" gorm:"uniqueIndex"`
+	Name          string         `json:"name,omitempty"`
+	Timezone      *string        `json:"timezone,omitempty"` // fuso IANA (ex: Europe/Lisbon) dos agendamentos em horário local
+	Subscriptions []Subscription `json:"subscriptions,omitempty" gorm:"foreignKey:RecipientID"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+func (r *Recipient) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
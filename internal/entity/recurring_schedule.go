@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"time"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RecurringStatus string
+type RecurringTarget string
+
+const (
+	RecurringActive    RecurringStatus = "active"
+	RecurringPaused    RecurringStatus = "paused"
+	RecurringCompleted RecurringStatus = "completed" // fim da série, limite de ocorrências ou regra esgotada
+
+	RecurringTargetUser      RecurringTarget = "user"
+	RecurringTargetGroup     RecurringTarget = "group"
+	RecurringTargetBroadcast RecurringTarget = "broadcast"
+)
+
+// RecurringSchedule é uma série de notificações: a cada ocorrência da regra (cron ou RRULE,
+// no fuso Timezone) o scheduler cria uma notificação a partir do conteúdo da série para o
+// destino configurado (cidadão, grupo ou broadcast)
+type RecurringSchedule struct {
+	ID             uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey"`
+	Name           string          `json:"name"`
+	Kind           string          `json:"kind" gorm:"not null"`       // cron ou rrule
+	Expression     string          `json:"expression" gorm:"not null"` // ex: "0 9 * * 1" ou "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9;BYMINUTE=0"
+	Timezone       string          `json:"timezone" gorm:"not null;default:'America/Sao_Paulo'"`
+	StartAt        time.Time       `json:"start_at" gorm:"not null"`
+	EndAt          *time.Time      `json:"end_at,omitempty"`
+	MaxOccurrences *int            `json:"max_occurrences,omitempty"`
+	Occurrences    int             `json:"occurrences" gorm:"not null;default:0"` // ocorrências já materializadas
+	Status         RecurringStatus `json:"status" gorm:"not null;default:'active';index"`
+	NextRunAt      *time.Time      `json:"next_run_at,omitempty" gorm:"index"`
+	LastRunAt      *time.Time      `json:"last_run_at,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+
+	// Destino de cada ocorrência
+	Target    RecurringTarget `json:"target" gorm:"not null"`
+	GroupID   *uuid.UUID      `json:"group_id,omitempty" gorm:"type:uuid;index"`
+	UserCPF   *string         `json:"user_cpf,omitempty"`
+	UserPhone *string         `json:"user_phone,omitempty"`
+	UserEmail *string         `json:"user_email,omitempty"`
+
+	// Conteúdo de cada ocorrência
+	Title    string               `json:"title" gorm:"not null"`
+	Message  string               `json:"message" gorm:"not null"`
+	Type     NotificationType     `json:"type" gorm:"not null"`
+	Purpose  string               `json:"purpose" gorm:"default:'transactional'"`
+	Priority NotificationPriority `json:"priority" gorm:"default:'normal'"`
+	Data     map[string]any       `json:"data,omitempty" gorm:"type:jsonb"`
+	IsHTML   bool                 `json:"is_html" gorm:"default:false"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (r *RecurringSchedule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// NewOccurrence cria a notificação da ocorrência, ligada à série
+func (r *RecurringSchedule) NewOccurrence() *Notification {
+	return &Notification{
+		Title:               r.Title,
+		Message:             r.Message,
+		Type:                r.Type,
+		Purpose:             r.Purpose,
+		Priority:            r.Priority,
+		Data:                r.Data,
+		IsHTML:              r.IsHTML,
+		RecurringScheduleID: &r.ID,
+	}
+}
@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"time"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SuppressionChannel string
+type SuppressionReason string
+
+const (
+	SuppressionChannelEmail SuppressionChannel = "email"
+	SuppressionChannelPhone SuppressionChannel = "phone"
+
+	SuppressionHardBounce   SuppressionReason = "hard_bounce"
+	SuppressionComplaint    SuppressionReason = "complaint"
+	SuppressionDoNotContact SuppressionReason = "do_not_contact"
+	SuppressionManual       SuppressionReason = "manual"
+)
+
+// Suppression é um endereço (email ou telefone) que não deve mais ser contatado por
+// nenhum sistema que use este serviço, até ExpiresAt (nil = permanente)
+type Suppression struct {
+	ID        uuid.UUID          `json:"id" gorm:"type:uuid;primaryKey"`
+	Channel   SuppressionChannel `json:"channel" gorm:"not null;uniqueIndex:idx_suppression_address"`
+	Address   string             `json:"address" gorm:"not null;uniqueIndex:idx_suppression_address"`
+	Reason    SuppressionReason  `json:"reason" gorm:"not null"`
+	Source    string             `json:"source"`
+	Note      string             `json:"note,omitempty"`
+	ExpiresAt *time.Time         `json:"expires_at,omitempty" gorm:"index"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+func (s *Suppression) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsActive indica se a supressão ainda está valendo
+func (s *Suppression) IsActive(now time.Time) bool {
+	return s.ExpiresAt == nil || s.ExpiresAt.After(now)
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/service"
+	"github.com/prefeitura-rio/app-notification-core/pkg/auth"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ConsentHandler struct {
+	service    service.ConsentService
+	recipients service.RecipientService
+}
+
+func NewConsentHandler(service service.ConsentService, recipients service.RecipientService) *ConsentHandler {
+	return &ConsentHandler{service: service, recipients: recipients}
+}
+
+type RecordConsentRequest struct {
+	CPF      string         `json:"cpf,omitempty"`
+	Phone    string         `json:"phone,omitempty"`
+	Email    string         `json:"email,omitempty"`
+	Channel  string         `json:"channel" binding:"required"` // email, sms, push ou in-app
+	Purpose  string         `json:"purpose" binding:"required"` // ex: transactional, marketing
+	Granted  *bool          `json:"granted" binding:"required"`
+	Source   string         `json:"source,omitempty"` // origem do registro (app, portal, api...)
+	Evidence map[string]any `json:"evidence,omitempty"`
+}
+
+// Record godoc
+// @Summary Registrar consentimento
+// @Description Registra a concessão ou revogação de consentimento para um canal e finalidade. Se não houver identificadores no corpo, usa os do token JWT. IP e user agent da requisição são gravados como evidência.
+// @Tags consents
+// @Accept json
+// @Produce json
+// @Param consent body RecordConsentRequest true "Dados do consentimento"
+// @Success 201 {object} entity.Consent
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /consents [post]
+func (h *ConsentHandler) Record(c *gin.Context) {
+	var req RecordConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.CPF == "" && req.Phone == "" && req.Email == "" {
+		if userInfo, exists := auth.GetUserInfo(c); exists {
+			req.CPF, req.Phone, req.Email = validation.SanitizeIdentity(userInfo.CPF, userInfo.Phone, userInfo.Email)
+		}
+	}
+
+	channel := entity.ConsentChannel(req.Channel)
+	if !entity.IsValidConsentChannel(channel) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid consent channel"})
+		return
+	}
+
+	recipient, err := h.recipients.Resolve(req.CPF, req.Phone, req.Email, "")
+	if err != nil {
+		if validation.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	action := entity.ConsentRevoked
+	if *req.Granted {
+		action = entity.ConsentGranted
+	}
+
+	source := req.Source
+	if source == "" {
+		source = "api"
+	}
+
+	consent := &entity.Consent{
+		RecipientID: recipient.ID,
+		Channel:     channel,
+		Purpose:     req.Purpose,
+		Action:      action,
+		Identifier:  consentIdentifier(channel, recipient),
+		Source:      source,
+		IPAddress:   c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		Evidence:    req.Evidence,
+	}
+
+	if err := h.service.RecordConsent(consent); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, consent)
+}
+
+// History godoc
+// @Summary Histórico de consentimentos
+// @Description Retorna todos os registros do ledger de consentimento do destinatário, do mais recente ao mais antigo
+// @Tags consents
+// @Produce json
+// @Param id path string true "ID do destinatário"
+// @Param limit query int false "Limite de resultados" default(20)
+// @Param offset query int false "Offset para paginação" default(0)
+// @Success 200 {array} entity.Consent
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /recipients/{id}/consents [get]
+func (h *ConsentHandler) History(c *gin.Context) {
+	recipientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipient ID"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	consents, err := h.service.GetHistory(recipientID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, consents)
+}
+
+// Current godoc
+// @Summary Consentimentos vigentes
+// @Description Retorna o estado atual de cada canal e finalidade do destinatário
+// @Tags consents
+// @Produce json
+// @Param id path string true "ID do destinatário"
+// @Success 200 {array} entity.Consent
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /recipients/{id}/consents/current [get]
+func (h *ConsentHandler) Current(c *gin.Context) {
+	recipientID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipient ID"})
+		return
+	}
+
+	consents, err := h.service.GetCurrent(recipientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, consents)
+}
+
+// consentIdentifier registra o endereço ao qual o consentimento se refere no momento do registro
+func consentIdentifier(channel entity.ConsentChannel, recipient *entity.Recipient) string {
+	switch {
+	case channel == entity.ConsentChannelEmail && recipient.Email != nil:
+		return *recipient.Email
+	case channel == entity.ConsentChannelSMS && recipient.Phone != nil:
+		return *recipient.Phone
+	case recipient.CPF != nil:
+		return *recipient.CPF
+	}
+	return ""
+}
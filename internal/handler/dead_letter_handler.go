@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/service"
+	"github.com/prefeitura-rio/app-notification-core/pkg/auth"
+	"github.com/prefeitura-rio/app-notification-core/pkg/queue"
+	"github.com/gin-gonic/gin"
+)
+
+type DeadLetterHandler struct {
+	service service.DeadLetterService
+}
+
+func NewDeadLetterHandler(service service.DeadLetterService) *DeadLetterHandler {
+	return &DeadLetterHandler{service: service}
+}
+
+type DeadLetterActionRequest struct {
+	MessageIDs []string `json:"message_ids,omitempty"`
+	All        bool     `json:"all,omitempty"` // obrigatório para agir sobre toda a DLQ
+	Reason     string   `json:"reason,omitempty"`
+}
+
+// List godoc
+// @Summary Listar mensagens da DLQ
+// @Description Pagina as mensagens da Dead Letter Queue sem removê-las, com o motivo do dead-letter (header x-death) e o resumo da notificação
+// @Tags queue
+// @Produce json
+// @Param limit query int false "Limite de resultados" default(20)
+// @Param offset query int false "Offset para paginação (offset + limit <= 500)" default(0)
+// @Success 200 {array} queue.DeadLetter
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /queue/dlq [get]
+func (h *DeadLetterHandler) List(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be an integer"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be an integer"})
+		return
+	}
+
+	letters, err := h.service.List(offset, limit)
+	if err != nil {
+		if errors.Is(err, queue.ErrInvalidDeadLetterPage) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, letters)
+}
+
+// Replay godoc
+// @Summary Reprocessar mensagens da DLQ
+// @Description Devolve as mensagens selecionadas (ou todas, com all=true) para a fila principal, zerando a contagem de tentativas. A ação é registrada na auditoria.
+// @Tags queue
+// @Accept json
+// @Produce json
+// @Param request body DeadLetterActionRequest true "Mensagens a reprocessar"
+// @Param X-Requested-By header string false "Responsável pela ação (quando não houver JWT)"
+// @Success 200 {object} entity.DeadLetterAudit
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /queue/dlq/replay [post]
+func (h *DeadLetterHandler) Replay(c *gin.Context) {
+	h.apply(c, h.service.Replay)
+}
+
+// Discard godoc
+// @Summary Descartar mensagens da DLQ
+// @Description Remove definitivamente as mensagens selecionadas (ou todas, com all=true) da DLQ. A ação é registrada na auditoria.
+// @Tags queue
+// @Accept json
+// @Produce json
+// @Param request body DeadLetterActionRequest true "Mensagens a descartar"
+// @Param X-Requested-By header string false "Responsável pela ação (quando não houver JWT)"
+// @Success 200 {object} entity.DeadLetterAudit
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /queue/dlq/discard [post]
+func (h *DeadLetterHandler) Discard(c *gin.Context) {
+	h.apply(c, h.service.Discard)
+}
+
+// Audit godoc
+// @Summary Auditoria da DLQ
+// @Description Lista as ações de reprocessamento e descarte executadas na DLQ
+// @Tags queue
+// @Produce json
+// @Param limit query int false "Limite de resultados" default(20)
+// @Param offset query int false "Offset para paginação" default(0)
+// @Success 200 {array} entity.DeadLetterAudit
+// @Failure 500 {object} map[string]string
+// @Router /queue/dlq/audit [get]
+func (h *DeadLetterHandler) Audit(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	audits, err := h.service.ListAudit(limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, audits)
+}
+
+func (h *DeadLetterHandler) apply(c *gin.Context, action func([]string, bool, string, string) (*entity.DeadLetterAudit, error)) {
+	var req DeadLetterActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	requestedBy := c.GetHeader("X-Requested-By")
+	if userInfo, exists := auth.GetUserInfo(c); exists && userInfo.Sub != "" {
+		requestedBy = userInfo.Sub
+	}
+
+	audit, err := action(req.MessageIDs, req.All, requestedBy, req.Reason)
+	if err != nil {
+		if errors.Is(err, service.ErrNoDeadLetterSelection) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// Parte das mensagens pode ter sido processada: a auditoria informa quais
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "audit": audit})
+		return
+	}
+
+	c.JSON(http.StatusOK, audit)
+}
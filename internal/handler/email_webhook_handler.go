@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookBody limita o tamanho do corpo aceito pelo webhook (1 MiB)
+const maxWebhookBody = 1 << 20
+
+type EmailWebhookHandler struct {
+	service service.EmailEventService
+	secret  string
+	client  *http.Client
+}
+
+func NewEmailWebhookHandler(service service.EmailEventService, secret string) *EmailWebhookHandler {
+	return &EmailWebhookHandler{
+		service: service,
+		secret:  secret,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// RelayEmailEvent é o formato de evento enviado pelo relay de email
+type RelayEmailEvent struct {
+	Event      string `json:"event"` // delivery, bounce ou complaint
+	MessageID  string `json:"message_id"`
+	Recipient  string `json:"recipient"`
+	BounceType string `json:"bounce_type,omitempty"` // hard (permanente) ou soft (temporário)
+	Reason     string `json:"reason,omitempty"`
+}
+
+type snsEnvelope struct {
+	Type         string `json:"Type"`
+	MessageID    string `json:"MessageId"`
+	TopicArn     string `json:"TopicArn"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+type sesRecipient struct {
+	EmailAddress   string `json:"emailAddress"`
+	DiagnosticCode string `json:"diagnosticCode"`
+}
+
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"`
+	Mail             struct {
+		MessageID string `json:"messageId"`
+	} `json:"mail"`
+	Bounce *struct {
+		BounceType        string         `json:"bounceType"`
+		BounceSubType     string         `json:"bounceSubType"`
+		BouncedRecipients []sesRecipient `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint *struct {
+		ComplaintFeedbackType string         `json:"complaintFeedbackType"`
+		ComplainedRecipients  []sesRecipient `json:"complainedRecipients"`
+	} `json:"complaint"`
+	Delivery *struct {
+		Recipients []string `json:"recipients"`
+	} `json:"delivery"`
+}
+
+// Receive godoc
+// @Summary Webhook de eventos de email
+// @Description Recebe eventos de entrega, bounce e complaint no formato do relay ou do SES (via SNS). Autenticado pelo segredo EMAIL_WEBHOOK_SECRET, enviado no header X-Webhook-Secret, no parâmetro token ou como assinatura HMAC-SHA256 do corpo no header X-Webhook-Signature. Hard bounces e reclamações entram na lista de supressão.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param event body RelayEmailEvent true "Evento (ou lista de eventos) do relay, ou mensagem SNS"
+// @Param token query string false "Segredo do webhook (para assinaturas SNS)"
+// @Success 200 {object} service.EmailEventResult
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /webhooks/email [post]
+func (h *EmailWebhookHandler) Receive(c *gin.Context) {
+	if h.secret == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "email webhook is not configured"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+
+	if !h.authenticate(c, body) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid webhook credentials"})
+		return
+	}
+
+	var events []service.EmailEvent
+	if messageType := c.GetHeader("x-amz-sns-message-type"); messageType != "" {
+		var envelope snsEnvelope
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid SNS message"})
+			return
+		}
+
+		switch envelope.Type {
+		case "SubscriptionConfirmation":
+			if err := h.confirmSubscription(envelope.SubscribeURL); err != nil {
+				log.Printf("EmailWebhook: Failed to confirm SNS subscription for %s: %v", envelope.TopicArn, err)
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("EmailWebhook: SNS subscription confirmed for %s", envelope.TopicArn)
+			c.JSON(http.StatusOK, gin.H{"message": "subscription confirmed"})
+			return
+		case "Notification":
+			if events, err = parseSESNotification(envelope.Message); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "ignored"})
+			return
+		}
+	} else {
+		if events, err = parseRelayEvents(body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	result, err := h.service.ProcessEvents(events)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// authenticate aceita o segredo compartilhado (header ou query, usado na URL da assinatura SNS)
+// ou a assinatura HMAC-SHA256 do corpo
+func (h *EmailWebhookHandler) authenticate(c *gin.Context, body []byte) bool {
+	for _, token := range []string{c.GetHeader("X-Webhook-Secret"), c.Query("token")} {
+		if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.secret)) == 1 {
+			return true
+		}
+	}
+
+	signature := strings.TrimPrefix(c.GetHeader("X-Webhook-Signature"), "sha256=")
+	if signature == "" {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(h.secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// confirmSubscription confirma a assinatura do tópico SNS, aceitando apenas URLs da AWS
+func (h *EmailWebhookHandler) confirmSubscription(subscribeURL string) error {
+	parsed, err := url.Parse(subscribeURL)
+	if err != nil || parsed.Scheme != "https" || !strings.HasSuffix(parsed.Hostname(), ".amazonaws.com") {
+		return errors.New("invalid SubscribeURL")
+	}
+
+	resp, err := h.client.Get(parsed.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("SNS returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func parseRelayEvents(body []byte) ([]service.EmailEvent, error) {
+	var raw []RelayEmailEvent
+	if trimmed := strings.TrimSpace(string(body)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, errors.New("invalid event list")
+		}
+	} else {
+		var single RelayEmailEvent
+		if err := json.Unmarshal(body, &single); err != nil {
+			return nil, errors.New("invalid event")
+		}
+		raw = append(raw, single)
+	}
+
+	events := make([]service.EmailEvent, 0, len(raw))
+	for _, r := range raw {
+		eventType, ok := emailEventType(r.Event)
+		if !ok {
+			log.Printf("EmailWebhook: Ignoring unknown relay event %q", r.Event)
+			continue
+		}
+		bounceType := strings.ToLower(r.BounceType)
+		events = append(events, service.EmailEvent{
+			Type:      eventType,
+			MessageID: r.MessageID,
+			Address:   r.Recipient,
+			Permanent: bounceType == "hard" || bounceType == "permanent",
+			Detail:    r.Reason,
+			Source:    "relay-webhook",
+		})
+	}
+	return events, nil
+}
+
+// parseSESNotification converte a notificação do SES (notificationType ou eventType,
+// quando enviada por um configuration set) em um evento por destinatário
+func parseSESNotification(message string) ([]service.EmailEvent, error) {
+	var notification sesNotification
+	if err := json.Unmarshal([]byte(message), &notification); err != nil {
+		return nil, errors.New("invalid SES notification")
+	}
+
+	kind := notification.NotificationType
+	if kind == "" {
+		kind = notification.EventType
+	}
+	eventType, ok := emailEventType(kind)
+	if !ok {
+		log.Printf("EmailWebhook: Ignoring SES notification %q", kind)
+		return nil, nil
+	}
+
+	var events []service.EmailEvent
+	newEvent := func(address, detail string, permanent bool) {
+		events = append(events, service.EmailEvent{
+			Type:      eventType,
+			MessageID: notification.Mail.MessageID,
+			Address:   address,
+			Permanent: permanent,
+			Detail:    detail,
+			Source:    "ses-webhook",
+		})
+	}
+
+	switch eventType {
+	case service.EmailEventBounce:
+		if notification.Bounce == nil {
+			return nil, errors.New("SES bounce without details")
+		}
+		permanent := notification.Bounce.BounceType == "Permanent"
+		for _, r := range notification.Bounce.BouncedRecipients {
+			detail := r.DiagnosticCode
+			if detail == "" {
+				detail = notification.Bounce.BounceType + "/" + notification.Bounce.BounceSubType
+			}
+			newEvent(r.EmailAddress, detail, permanent)
+		}
+	case service.EmailEventComplaint:
+		if notification.Complaint == nil {
+			return nil, errors.New("SES complaint without details")
+		}
+		for _, r := range notification.Complaint.ComplainedRecipients {
+			newEvent(r.EmailAddress, notification.Complaint.ComplaintFeedbackType, false)
+		}
+	case service.EmailEventDelivery:
+		if notification.Delivery == nil {
+			return nil, errors.New("SES delivery without details")
+		}
+		for _, address := range notification.Delivery.Recipients {
+			newEvent(address, "", false)
+		}
+	}
+	return events, nil
+}
+
+func emailEventType(value string) (service.EmailEventType, bool) {
+	switch strings.ToLower(value) {
+	case "delivery", "delivered":
+		return service.EmailEventDelivery, true
+	case "bounce", "bounced":
+		return service.EmailEventBounce, true
+	case "complaint", "complained":
+		return service.EmailEventComplaint, true
+	}
+	return "", false
+}
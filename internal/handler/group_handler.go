@@ -6,6 +6,7 @@ import (
 
 	"github.com/prefeitura-rio/app-notification-core/internal/entity"
 	"github.com/prefeitura-rio/app-notification-core/internal/service"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -178,6 +179,10 @@ func (h *GroupHandler) AddMember(c *gin.Context) {
 
 	member.GroupID = groupID
 	if err := h.service.AddMemberToGroup(&member); err != nil {
+		if validation.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -291,6 +296,10 @@ func (h *GroupHandler) UpdateMember(c *gin.Context) {
 
 	member.ID = memberID
 	if err := h.service.UpdateMember(&member); err != nil {
+		if validation.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
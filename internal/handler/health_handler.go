@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -10,14 +11,14 @@ import (
 )
 
 type HealthHandler struct {
-	db       *gorm.DB
-	rabbitMQ *queue.RabbitMQClient
+	db    *gorm.DB
+	queue queue.Queue
 }
 
-func NewHealthHandler(db *gorm.DB, rabbitMQ *queue.RabbitMQClient) *HealthHandler {
+func NewHealthHandler(db *gorm.DB, queue queue.Queue) *HealthHandler {
 	return &HealthHandler{
-		db:       db,
-		rabbitMQ: rabbitMQ,
+		db:    db,
+		queue: queue,
 	}
 }
 
@@ -71,18 +72,26 @@ func (h *HealthHandler) Readiness(c *gin.Context) {
 		}
 	}
 
-	// Check RabbitMQ
-	if h.rabbitMQ == nil {
-		checks["rabbitmq"] = "not configured"
+	// Check queue (a chave é o backend: rabbitmq ou postgres)
+	if h.queue == nil {
+		checks["queue"] = "not configured"
+		allHealthy = false
+	} else if backend, status := h.queue.Backend(), h.queue.Status(); status.State != queue.StateConnected {
+		// Enquanto reconecta, os envios falhariam: retirar a instância do balanceamento
+		checks[backend] = string(status.State)
+		if status.LastError != "" {
+			checks[backend] += ": " + status.LastError
+		}
 		allHealthy = false
 	} else {
-		_, err := h.rabbitMQ.GetQueueStats()
+		_, err := h.queue.GetQueueStats()
 		if err != nil {
-			checks["rabbitmq"] = "unhealthy: " + err.Error()
+			checks[backend] = "unhealthy: " + err.Error()
 			allHealthy = false
 		} else {
-			checks["rabbitmq"] = "healthy"
+			checks[backend] = "healthy"
 		}
+		checks[backend+"_consumers"] = strconv.Itoa(int(status.ActiveConsumers)) + " active"
 	}
 
 	status := "ready"
@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"errors"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
@@ -9,16 +11,18 @@ import (
 	"github.com/prefeitura-rio/app-notification-core/internal/entity"
 	"github.com/prefeitura-rio/app-notification-core/internal/service"
 	"github.com/prefeitura-rio/app-notification-core/pkg/auth"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type NotificationHandler struct {
-	service service.NotificationService
+	service    service.NotificationService
+	recipients service.RecipientService
 }
 
-func NewNotificationHandler(service service.NotificationService) *NotificationHandler {
-	return &NotificationHandler{service: service}
+func NewNotificationHandler(service service.NotificationService, recipients service.RecipientService) *NotificationHandler {
+	return &NotificationHandler{service: service, recipients: recipients}
 }
 
 // List godoc
@@ -67,8 +71,15 @@ func (h *NotificationHandler) GetMyNotifications(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
-	// Buscar notificações do usuário usando CPF
-	notifications, err := h.service.GetNotificationsByCPF(userInfo.CPF, limit, offset)
+	// Vincular os identificadores do token ao destinatário e buscar sua caixa de entrada
+	cpf, phone, email := validation.SanitizeIdentity(userInfo.CPF, userInfo.Phone, userInfo.Email)
+	recipient, err := h.recipients.Resolve(cpf, phone, email, userInfo.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	notifications, err := h.service.GetNotificationsByRecipient(recipient, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -77,6 +88,7 @@ func (h *NotificationHandler) GetMyNotifications(c *gin.Context) {
 	// Retornar com informações do usuário
 	c.JSON(http.StatusOK, gin.H{
 		"user": gin.H{
+			"recipient_id":   recipient.ID,
 			"cpf":            userInfo.CPF,
 			"email":          userInfo.Email,
 			"name":           userInfo.Name,
@@ -152,6 +164,7 @@ func (h *NotificationHandler) Get(c *gin.Context) {
 // @Param limit query int false "Limite de resultados" default(20)
 // @Param offset query int false "Offset para paginação" default(0)
 // @Success 200 {array} entity.Notification
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /notifications/cpf/{cpf} [get]
 func (h *NotificationHandler) GetByCPF(c *gin.Context) {
@@ -161,6 +174,10 @@ func (h *NotificationHandler) GetByCPF(c *gin.Context) {
 
 	notifications, err := h.service.GetNotificationsByCPF(cpf, limit, offset)
 	if err != nil {
+		if validation.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -177,6 +194,7 @@ func (h *NotificationHandler) GetByCPF(c *gin.Context) {
 // @Param limit query int false "Limite de resultados" default(20)
 // @Param offset query int false "Offset para paginação" default(0)
 // @Success 200 {array} entity.Notification
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /notifications/phone/{phone} [get]
 func (h *NotificationHandler) GetByPhone(c *gin.Context) {
@@ -186,6 +204,10 @@ func (h *NotificationHandler) GetByPhone(c *gin.Context) {
 
 	notifications, err := h.service.GetNotificationsByPhone(phone, limit, offset)
 	if err != nil {
+		if validation.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -202,6 +224,7 @@ func (h *NotificationHandler) GetByPhone(c *gin.Context) {
 // @Param limit query int false "Limite de resultados" default(20)
 // @Param offset query int false "Offset para paginação" default(0)
 // @Success 200 {array} entity.Notification
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /notifications/email/{email} [get]
 func (h *NotificationHandler) GetByEmail(c *gin.Context) {
@@ -211,6 +234,10 @@ func (h *NotificationHandler) GetByEmail(c *gin.Context) {
 
 	notifications, err := h.service.GetNotificationsByEmail(email, limit, offset)
 	if err != nil {
+		if validation.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -302,10 +329,38 @@ func (h *NotificationHandler) MarkAsRead(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "notification marked as read"})
 }
 
+// GetDeliveries godoc
+// @Summary Resultados de entrega da notificação
+// @Description Retorna o resultado da entrega em cada canal e destino (sent, failed ou suppressed)
+// @Tags notifications
+// @Produce json
+// @Param id path string true "ID da notificação"
+// @Success 200 {array} entity.Delivery
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /notifications/{id}/deliveries [get]
+func (h *NotificationHandler) GetDeliveries(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification ID"})
+		return
+	}
+
+	deliveries, err := h.service.GetDeliveries(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
 type SendNotificationRequest struct {
 	Title        string         `json:"title" binding:"required"`
 	Message      string         `json:"message" binding:"required"`
 	Type         string         `json:"type" binding:"required"`
+	Purpose      string         `json:"purpose,omitempty"` // finalidade para verificação de consentimento (padrão: transactional)
+	Priority     string         `json:"priority,omitempty"` // high, normal ou low (padrão: normal)
 	Data         map[string]any `json:"data,omitempty"`
 	CPF          string         `json:"cpf,omitempty"`
 	Phone        string         `json:"phone,omitempty"`
@@ -313,6 +368,8 @@ type SendNotificationRequest struct {
 	IsHTML       bool           `json:"is_html,omitempty"`
 	IsScheduled  bool           `json:"is_scheduled,omitempty"`
 	ScheduledFor *string        `json:"scheduled_for,omitempty"` // RFC3339 format
+	LocalTime    *string        `json:"local_time,omitempty"`    // 2006-01-02T15:04:05, sem fuso: agenda no horário local de cada destinatário
+	ExpiresAt    *time.Time     `json:"expires_at,omitempty"`    // RFC3339; após esse horário a notificação não é enviada
 }
 
 type BatchRecipient struct {
@@ -326,10 +383,14 @@ type SendBatchRequest struct {
 	Title        string           `json:"title" binding:"required"`
 	Message      string           `json:"message" binding:"required"`
 	Type         string           `json:"type" binding:"required"`
+	Purpose      string           `json:"purpose,omitempty"`
+	Priority     string           `json:"priority,omitempty"`
 	Data         map[string]any   `json:"data,omitempty"`
 	IsHTML       bool             `json:"is_html,omitempty"`
 	IsScheduled  bool             `json:"is_scheduled,omitempty"`
 	ScheduledFor *string          `json:"scheduled_for,omitempty"` // RFC3339 format
+	LocalTime    *string          `json:"local_time,omitempty"`    // horário de parede no fuso de cada destinatário
+	ExpiresAt    *time.Time       `json:"expires_at,omitempty"`
 	Recipients   []BatchRecipient `json:"recipients" binding:"required,min=1"`
 }
 
@@ -338,17 +399,20 @@ type BatchResult struct {
 	Succeeded int      `json:"succeeded"`
 	Failed    int      `json:"failed"`
 	Errors    []string `json:"errors,omitempty"`
+	// Buckets conta os destinatários agendados por fuso nos envios com local_time
+	Buckets map[string]int `json:"buckets,omitempty"`
 }
 
 // SendToUser godoc
 // @Summary Enviar notificação para usuário
-// @Description Envia notificação para um usuário específico via CPF, telefone ou email
+// @Description Envia notificação para um usuário específico via CPF, telefone ou email. Com local_time (ex: 2026-11-03T09:00:00, sem fuso), agenda no horário local do fuso cadastrado do destinatário (padrão America/Sao_Paulo)
 // @Tags notifications
 // @Accept json
 // @Produce json
 // @Param notification body SendNotificationRequest true "Dados da notificação"
 // @Success 200 {object} entity.Notification
 // @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /notifications/send/user [post]
 func (h *NotificationHandler) SendToUser(c *gin.Context) {
@@ -362,9 +426,12 @@ func (h *NotificationHandler) SendToUser(c *gin.Context) {
 		Title:   req.Title,
 		Message: req.Message,
 		Type:    entity.NotificationType(req.Type),
+		Purpose: req.Purpose,
+		Priority: entity.NotificationPriority(req.Priority),
 		Data:    req.Data,
 		IsHTML:  req.IsHTML,
 		IsScheduled: req.IsScheduled,
+		ExpiresAt:   req.ExpiresAt,
 	}
 
 	// Parse scheduled_for se fornecido
@@ -384,9 +451,19 @@ func (h *NotificationHandler) SendToUser(c *gin.Context) {
 		notification.ScheduledFor = &scheduledTime
 	}
 
+	// local_time agenda no horário de parede do fuso de cada destinatário
+	if req.LocalTime != nil {
+		if req.ScheduledFor != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "use either scheduled_for or local_time"})
+			return
+		}
+		notification.IsScheduled = true
+		notification.LocalTime = req.LocalTime
+	}
+
 	if err := h.service.SendToUser(req.CPF, req.Phone, req.Email, notification); err != nil {
 		log.Printf("Error sending notification to user: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondSendError(c, err)
 		return
 	}
 
@@ -395,7 +472,7 @@ func (h *NotificationHandler) SendToUser(c *gin.Context) {
 
 // SendToGroup godoc
 // @Summary Enviar notificação para grupo
-// @Description Envia notificação para todos os membros de um grupo
+// @Description Envia notificação para todos os membros de um grupo. Com scheduled_for, grava um único envio agendado (retornado na resposta) cujos membros são resolvidos no horário do envio. Com local_time, cada membro recebe no horário local do seu fuso; os membros de cada fuso formam um lote liberado pelo scheduler
 // @Tags notifications
 // @Accept json
 // @Produce json
@@ -403,6 +480,7 @@ func (h *NotificationHandler) SendToUser(c *gin.Context) {
 // @Param notification body SendNotificationRequest true "Dados da notificação"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /notifications/send/group/{groupId} [post]
 func (h *NotificationHandler) SendToGroup(c *gin.Context) {
@@ -422,9 +500,12 @@ func (h *NotificationHandler) SendToGroup(c *gin.Context) {
 		Title:       req.Title,
 		Message:     req.Message,
 		Type:        entity.NotificationType(req.Type),
+		Purpose:     req.Purpose,
+		Priority:    entity.NotificationPriority(req.Priority),
 		Data:        req.Data,
 		IsHTML:      req.IsHTML,
 		IsScheduled: req.IsScheduled,
+		ExpiresAt:   req.ExpiresAt,
 	}
 
 	// Parse scheduled_for se fornecido
@@ -444,8 +525,24 @@ func (h *NotificationHandler) SendToGroup(c *gin.Context) {
 		notification.ScheduledFor = &scheduledTime
 	}
 
-	if err := h.service.SendToGroup(groupID, notification); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// local_time agenda no horário de parede do fuso de cada destinatário
+	if req.LocalTime != nil {
+		if req.ScheduledFor != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "use either scheduled_for or local_time"})
+			return
+		}
+		notification.IsScheduled = true
+		notification.LocalTime = req.LocalTime
+	}
+
+	if err := h.service.SendToGroup(c.Request.Context(), groupID, notification); err != nil {
+		respondSendError(c, err)
+		return
+	}
+
+	// Envio agendado: um único registro, expandido para os membros no horário
+	if notification.GroupJob {
+		c.JSON(http.StatusOK, notification)
 		return
 	}
 
@@ -461,6 +558,7 @@ func (h *NotificationHandler) SendToGroup(c *gin.Context) {
 // @Param notification body SendNotificationRequest true "Dados da notificação"
 // @Success 200 {object} entity.Notification
 // @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /notifications/send/broadcast [post]
 func (h *NotificationHandler) SendBroadcast(c *gin.Context) {
@@ -474,9 +572,12 @@ func (h *NotificationHandler) SendBroadcast(c *gin.Context) {
 		Title:       req.Title,
 		Message:     req.Message,
 		Type:        entity.NotificationType(req.Type),
+		Purpose:     req.Purpose,
+		Priority:    entity.NotificationPriority(req.Priority),
 		Data:        req.Data,
 		IsHTML:      req.IsHTML,
 		IsScheduled: req.IsScheduled,
+		ExpiresAt:   req.ExpiresAt,
 	}
 
 	// Parse scheduled_for se fornecido
@@ -496,8 +597,18 @@ func (h *NotificationHandler) SendBroadcast(c *gin.Context) {
 		notification.ScheduledFor = &scheduledTime
 	}
 
+	// local_time agenda no horário de parede do fuso de cada destinatário
+	if req.LocalTime != nil {
+		if req.ScheduledFor != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "use either scheduled_for or local_time"})
+			return
+		}
+		notification.IsScheduled = true
+		notification.LocalTime = req.LocalTime
+	}
+
 	if err := h.service.SendBroadcast(notification); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondSendError(c, err)
 		return
 	}
 
@@ -506,13 +617,14 @@ func (h *NotificationHandler) SendBroadcast(c *gin.Context) {
 
 // SendBatch godoc
 // @Summary Enviar notificações em lote
-// @Description Envia notificações para múltiplos destinatários em lote
+// @Description Envia notificações para múltiplos destinatários em lote. Com local_time, cada destinatário recebe no horário local do seu fuso e buckets conta os destinatários por fuso
 // @Tags notifications
 // @Accept json
 // @Produce json
 // @Param batch body SendBatchRequest true "Dados do envio em lote"
 // @Success 200 {object} BatchResult
 // @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Router /notifications/send/batch [post]
 func (h *NotificationHandler) SendBatch(c *gin.Context) {
 	var req SendBatchRequest
@@ -540,6 +652,16 @@ func (h *NotificationHandler) SendBatch(c *gin.Context) {
 		scheduledTime = &parsedTime
 	}
 
+	// local_time agenda no horário de parede do fuso de cada destinatário
+	scheduled := req.IsScheduled
+	if req.LocalTime != nil {
+		if req.ScheduledFor != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "use either scheduled_for or local_time"})
+			return
+		}
+		scheduled = true
+	}
+
 	log.Printf("Processing batch send for %d recipients", len(req.Recipients))
 
 	result := BatchResult{
@@ -548,14 +670,33 @@ func (h *NotificationHandler) SendBatch(c *gin.Context) {
 	}
 
 	for i, recipient := range req.Recipients {
+		// Envios imediatos aguardam a fila ter espaço antes de cada destinatário
+		if !scheduled {
+			if err := h.service.AwaitCapacity(c.Request.Context()); err != nil {
+				if result.Succeeded == 0 && result.Failed == 0 {
+					respondSendError(c, err)
+					return
+				}
+				remaining := len(req.Recipients) - i
+				result.Failed += remaining
+				result.Errors = append(result.Errors, strconv.Itoa(remaining)+" recipient(s) not sent: "+err.Error())
+				log.Printf("Batch send stopped with %d recipient(s) left: %v", remaining, err)
+				break
+			}
+		}
+
 		notification := &entity.Notification{
 			Title:        req.Title,
 			Message:      req.Message,
 			Type:         entity.NotificationType(req.Type),
+			Purpose:      req.Purpose,
+			Priority:     entity.NotificationPriority(req.Priority),
 			Data:         req.Data,
 			IsHTML:       req.IsHTML,
-			IsScheduled:  req.IsScheduled,
+			IsScheduled:  scheduled,
 			ScheduledFor: scheduledTime,
+			LocalTime:    req.LocalTime,
+			ExpiresAt:    req.ExpiresAt,
 		}
 
 		err := h.service.SendToUser(recipient.CPF, recipient.Phone, recipient.Email, notification)
@@ -577,6 +718,12 @@ func (h *NotificationHandler) SendBatch(c *gin.Context) {
 			log.Printf("Failed to send to recipient %d: %v", i+1, err)
 		} else {
 			result.Succeeded++
+			if notification.Timezone != nil {
+				if result.Buckets == nil {
+					result.Buckets = map[string]int{}
+				}
+				result.Buckets[*notification.Timezone]++
+			}
 		}
 	}
 
@@ -584,3 +731,27 @@ func (h *NotificationHandler) SendBatch(c *gin.Context) {
 
 	c.JSON(http.StatusOK, result)
 }
+
+// respondSendError responde com o status do erro de envio; com a fila saturada, informa no
+// header Retry-After quando tentar de novo
+func respondSendError(c *gin.Context, err error) {
+	var saturated *service.QueueSaturatedError
+	if errors.As(err, &saturated) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(saturated.RetryAfter.Seconds()))))
+	}
+	c.JSON(sendErrorStatus(err), gin.H{"error": err.Error()})
+}
+
+// sendErrorStatus mapeia o erro de envio para o status HTTP: identificador, prioridade,
+// validade, local_time inválidos ou broadcast de finalidade com opt-in são erro do cliente e a fila saturada responde 429. A publicação na fila
+// é feita pelo relay do outbox e não afeta a resposta.
+func sendErrorStatus(err error) int {
+	switch {
+	case validation.IsValidationError(err), errors.Is(err, service.ErrInvalidPriority), errors.Is(err, service.ErrInvalidExpiry),
+		errors.Is(err, service.ErrInvalidLocalTime), errors.Is(err, service.ErrBroadcastRequiresConsent):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrQueueSaturated):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/service"
+	"github.com/prefeitura-rio/app-notification-core/pkg/auth"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PrivacyHandler struct {
+	service service.PrivacyService
+}
+
+func NewPrivacyHandler(service service.PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{service: service}
+}
+
+// Export godoc
+// @Summary Exportar dados do titular
+// @Description Gera um arquivo JSON com todos os dados mantidos sobre o titular: notificações, participações em grupos, dispositivos de push, séries recorrentes, preferências e ledger de consentimento (LGPD)
+// @Tags privacy
+// @Produce json
+// @Param cpf path string true "CPF do titular"
+// @Success 200 {object} service.SubjectExport
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /privacy/subjects/{cpf}/export [get]
+func (h *PrivacyHandler) Export(c *gin.Context) {
+	export, err := h.service.ExportSubject(c.Param("cpf"))
+	if err != nil {
+		if validation.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=\"lgpd-export-"+export.GeneratedAt.Format("20060102150405")+".json\"")
+	c.JSON(http.StatusOK, export)
+}
+
+// Erase godoc
+// @Summary Eliminar dados do titular
+// @Description Elimina notificações, participações em grupos, dispositivos e séries recorrentes do titular, anonimiza o ledger de consentimento e retorna um comprovante auditável (LGPD)
+// @Tags privacy
+// @Produce json
+// @Param cpf path string true "CPF do titular"
+// @Param reason query string false "Motivo ou número do protocolo da solicitação"
+// @Param X-Requested-By header string false "Responsável pela solicitação (quando não houver JWT)"
+// @Success 200 {object} entity.ErasureReceipt
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /privacy/subjects/{cpf} [delete]
+func (h *PrivacyHandler) Erase(c *gin.Context) {
+	requestedBy := c.GetHeader("X-Requested-By")
+	if userInfo, exists := auth.GetUserInfo(c); exists && userInfo.Sub != "" {
+		requestedBy = userInfo.Sub
+	}
+
+	receipt, err := h.service.EraseSubject(c.Param("cpf"), requestedBy, c.Query("reason"))
+	if err != nil {
+		if validation.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, receipt)
+}
+
+// ListReceipts godoc
+// @Summary Listar comprovantes de eliminação do titular
+// @Description Retorna os comprovantes de eliminação emitidos para o CPF
+// @Tags privacy
+// @Produce json
+// @Param cpf path string true "CPF do titular"
+// @Success 200 {array} entity.ErasureReceipt
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /privacy/subjects/{cpf}/receipts [get]
+func (h *PrivacyHandler) ListReceipts(c *gin.Context) {
+	receipts, err := h.service.GetReceiptsBySubject(c.Param("cpf"))
+	if err != nil {
+		if validation.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, receipts)
+}
+
+// GetReceipt godoc
+// @Summary Buscar comprovante de eliminação
+// @Description Retorna um comprovante de eliminação pelo ID
+// @Tags privacy
+// @Produce json
+// @Param id path string true "ID do comprovante"
+// @Success 200 {object} entity.ErasureReceipt
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /privacy/receipts/{id} [get]
+func (h *PrivacyHandler) GetReceipt(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid receipt ID"})
+		return
+	}
+
+	receipt, err := h.service.GetReceipt(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "receipt not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, receipt)
+}
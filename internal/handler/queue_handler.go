@@ -11,17 +11,29 @@ type QueueMonitor interface {
 	PurgeQueue() error
 }
 
+// WorkerMonitor descreve os pools de workers (implementado por worker.Supervisor)
+type WorkerMonitor interface {
+	Stats() map[string]interface{}
+}
+
+// OutboxMonitor descreve o outbox (implementado por outbox.Relay)
+type OutboxMonitor interface {
+	Stats() map[string]interface{}
+}
+
 type QueueHandler struct {
 	monitor QueueMonitor
+	workers WorkerMonitor
+	outbox  OutboxMonitor
 }
 
-func NewQueueHandler(monitor QueueMonitor) *QueueHandler {
-	return &QueueHandler{monitor: monitor}
+func NewQueueHandler(monitor QueueMonitor, workers WorkerMonitor, outbox OutboxMonitor) *QueueHandler {
+	return &QueueHandler{monitor: monitor, workers: workers, outbox: outbox}
 }
 
 // GetStats godoc
 // @Summary Obter estatísticas da fila
-// @Description Retorna estatísticas em tempo real da fila de notificações
+// @Description Retorna estatísticas em tempo real da fila de notificações, do outbox (mensagens pendentes e separadas após esgotar as tentativas) e dos pools de workers desta instância
 // @Tags queue
 // @Produce json
 // @Success 200 {object} map[string]interface{}
@@ -33,6 +45,12 @@ func (h *QueueHandler) GetStats(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if h.workers != nil {
+		stats["workers"] = h.workers.Stats()
+	}
+	if h.outbox != nil {
+		stats["outbox"] = h.outbox.Stats()
+	}
 
 	c.JSON(http.StatusOK, stats)
 }
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/service"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RecipientHandler struct {
+	service service.RecipientService
+}
+
+func NewRecipientHandler(service service.RecipientService) *RecipientHandler {
+	return &RecipientHandler{service: service}
+}
+
+// Get godoc
+// @Summary Buscar destinatário por ID
+// @Description Retorna um destinatário com todos os seus identificadores e dispositivos vinculados
+// @Tags recipients
+// @Produce json
+// @Param id path string true "ID do destinatário"
+// @Success 200 {object} entity.Recipient
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /recipients/{id} [get]
+func (h *RecipientHandler) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipient ID"})
+		return
+	}
+
+	recipient, err := h.service.GetRecipient(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recipient not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, recipient)
+}
+
+// Lookup godoc
+// @Summary Buscar destinatário por identificador
+// @Description Retorna o destinatário vinculado a um CPF, telefone ou email
+// @Tags recipients
+// @Produce json
+// @Param cpf query string false "CPF do usuário"
+// @Param phone query string false "Telefone do usuário"
+// @Param email query string false "Email do usuário"
+// @Success 200 {object} entity.Recipient
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /recipients/lookup [get]
+func (h *RecipientHandler) Lookup(c *gin.Context) {
+	cpf := c.Query("cpf")
+	phone := c.Query("phone")
+	email := c.Query("email")
+	if cpf == "" && phone == "" && email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cpf, phone or email is required"})
+		return
+	}
+
+	recipient, err := h.service.FindRecipient(cpf, phone, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipient not found"})
+			return
+		}
+		if validation.IsValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, recipient)
+}
+
+type RecipientTimezoneRequest struct {
+	Timezone string `json:"timezone"` // nome IANA (ex: Europe/Lisbon); vazio volta ao padrão America/Sao_Paulo
+}
+
+// SetTimezone godoc
+// @Summary Definir fuso do destinatário
+// @Description Define o fuso usado nos agendamentos em horário local (local_time). Vazio volta ao padrão America/Sao_Paulo.
+// @Tags recipients
+// @Accept json
+// @Produce json
+// @Param id path string true "ID do destinatário"
+// @Param timezone body RecipientTimezoneRequest true "Fuso IANA"
+// @Success 200 {object} entity.Recipient
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /recipients/{id}/timezone [put]
+func (h *RecipientHandler) SetTimezone(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipient ID"})
+		return
+	}
+
+	var req RecipientTimezoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recipient, err := h.service.SetTimezone(id, req.Timezone)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipient not found"})
+		case errors.Is(err, service.ErrInvalidTimezone):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, recipient)
+}
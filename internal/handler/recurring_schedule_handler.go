@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxUpcomingOccurrences limita a listagem de próximas ocorrências
+const maxUpcomingOccurrences = 100
+
+type RecurringScheduleHandler struct {
+	service service.RecurringScheduleService
+}
+
+func NewRecurringScheduleHandler(service service.RecurringScheduleService) *RecurringScheduleHandler {
+	return &RecurringScheduleHandler{service: service}
+}
+
+type RecurringScheduleRequest struct {
+	Name           string         `json:"name,omitempty"`
+	Kind           string         `json:"kind" binding:"required"`       // cron ou rrule
+	Expression     string         `json:"expression" binding:"required"` // ex: "0 9 * * 1" ou "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9;BYMINUTE=0"
+	Timezone       string         `json:"timezone,omitempty"`            // padrão: America/Sao_Paulo
+	StartAt        *time.Time     `json:"start_at,omitempty"`            // padrão: agora
+	EndAt          *time.Time     `json:"end_at,omitempty"`
+	MaxOccurrences *int           `json:"max_occurrences,omitempty"`
+	Target         string         `json:"target" binding:"required"` // user, group ou broadcast
+	GroupID        *uuid.UUID     `json:"group_id,omitempty"`
+	CPF            string         `json:"cpf,omitempty"`
+	Phone          string         `json:"phone,omitempty"`
+	Email          string         `json:"email,omitempty"`
+	Title          string         `json:"title" binding:"required"`
+	Message        string         `json:"message" binding:"required"`
+	Type           string         `json:"type" binding:"required"`
+	Purpose        string         `json:"purpose,omitempty"`
+	Priority       string         `json:"priority,omitempty"`
+	Data           map[string]any `json:"data,omitempty"`
+	IsHTML         bool           `json:"is_html,omitempty"`
+}
+
+// UpcomingOccurrencesResponse lista as próximas ocorrências no fuso da série
+type UpcomingOccurrencesResponse struct {
+	ScheduleID  uuid.UUID   `json:"schedule_id"`
+	Timezone    string      `json:"timezone"`
+	Occurrences []time.Time `json:"occurrences"`
+}
+
+func (r RecurringScheduleRequest) toEntity() *entity.RecurringSchedule {
+	schedule := &entity.RecurringSchedule{
+		Name:           r.Name,
+		Kind:           r.Kind,
+		Expression:     r.Expression,
+		Timezone:       r.Timezone,
+		EndAt:          r.EndAt,
+		MaxOccurrences: r.MaxOccurrences,
+		Target:         entity.RecurringTarget(r.Target),
+		GroupID:        r.GroupID,
+		Title:          r.Title,
+		Message:        r.Message,
+		Type:           entity.NotificationType(r.Type),
+		Purpose:        r.Purpose,
+		Priority:       entity.NotificationPriority(r.Priority),
+		Data:           r.Data,
+		IsHTML:         r.IsHTML,
+	}
+	if r.StartAt != nil {
+		schedule.StartAt = *r.StartAt
+	}
+	if r.CPF != "" {
+		schedule.UserCPF = &r.CPF
+	}
+	if r.Phone != "" {
+		schedule.UserPhone = &r.Phone
+	}
+	if r.Email != "" {
+		schedule.UserEmail = &r.Email
+	}
+	return schedule
+}
+
+// Create godoc
+// @Summary Criar notificação recorrente
+// @Description Cria uma série que envia uma notificação a cada ocorrência de uma expressão cron (5 campos) ou RRULE do iCalendar, no fuso informado, entre start_at e end_at e até max_occurrences
+// @Tags recurring-schedules
+// @Accept json
+// @Produce json
+// @Param schedule body RecurringScheduleRequest true "Regra, destino e conteúdo da série"
+// @Success 201 {object} entity.RecurringSchedule
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /recurring-schedules [post]
+func (h *RecurringScheduleHandler) Create(c *gin.Context) {
+	var req RecurringScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule := req.toEntity()
+	if err := h.service.CreateSchedule(schedule); err != nil {
+		c.JSON(recurringErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// List godoc
+// @Summary Listar notificações recorrentes
+// @Tags recurring-schedules
+// @Produce json
+// @Param status query string false "Filtrar por status (active, paused ou completed)"
+// @Param limit query int false "Limite de resultados" default(20)
+// @Param offset query int false "Offset para paginação" default(0)
+// @Success 200 {array} entity.RecurringSchedule
+// @Failure 500 {object} map[string]string
+// @Router /recurring-schedules [get]
+func (h *RecurringScheduleHandler) List(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	schedules, err := h.service.ListSchedules(entity.RecurringStatus(c.Query("status")), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+// Get godoc
+// @Summary Buscar notificação recorrente
+// @Tags recurring-schedules
+// @Produce json
+// @Param id path string true "ID da série"
+// @Success 200 {object} entity.RecurringSchedule
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /recurring-schedules/{id} [get]
+func (h *RecurringScheduleHandler) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule ID"})
+		return
+	}
+
+	schedule, err := h.service.GetSchedule(id)
+	if err != nil {
+		c.JSON(recurringErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// Occurrences godoc
+// @Summary Próximas ocorrências
+// @Description Lista as próximas ocorrências da série no seu fuso. Séries pausadas mostram as ocorrências que teriam se fossem retomadas agora.
+// @Tags recurring-schedules
+// @Produce json
+// @Param id path string true "ID da série"
+// @Param limit query int false "Quantidade de ocorrências (máximo 100)" default(10)
+// @Success 200 {object} UpcomingOccurrencesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /recurring-schedules/{id}/occurrences [get]
+func (h *RecurringScheduleHandler) Occurrences(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule ID"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit <= 0 || limit > maxUpcomingOccurrences {
+		limit = maxUpcomingOccurrences
+	}
+
+	schedule, err := h.service.GetSchedule(id)
+	if err != nil {
+		c.JSON(recurringErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	occurrences, err := h.service.UpcomingOccurrences(id, limit)
+	if err != nil {
+		c.JSON(recurringErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, UpcomingOccurrencesResponse{
+		ScheduleID:  id,
+		Timezone:    schedule.Timezone,
+		Occurrences: occurrences,
+	})
+}
+
+// Pause godoc
+// @Summary Pausar notificação recorrente
+// @Description Suspende a série; ocorrências durante a pausa não são enviadas
+// @Tags recurring-schedules
+// @Produce json
+// @Param id path string true "ID da série"
+// @Success 200 {object} entity.RecurringSchedule
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /recurring-schedules/{id}/pause [post]
+func (h *RecurringScheduleHandler) Pause(c *gin.Context) {
+	h.transition(c, h.service.PauseSchedule)
+}
+
+// Resume godoc
+// @Summary Retomar notificação recorrente
+// @Description Reativa a série a partir da próxima ocorrência após agora
+// @Tags recurring-schedules
+// @Produce json
+// @Param id path string true "ID da série"
+// @Success 200 {object} entity.RecurringSchedule
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /recurring-schedules/{id}/resume [post]
+func (h *RecurringScheduleHandler) Resume(c *gin.Context) {
+	h.transition(c, h.service.ResumeSchedule)
+}
+
+// Delete godoc
+// @Summary Remover notificação recorrente
+// @Description Remove a série; as notificações já enviadas por ela são mantidas
+// @Tags recurring-schedules
+// @Param id path string true "ID da série"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /recurring-schedules/{id} [delete]
+func (h *RecurringScheduleHandler) Delete(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule ID"})
+		return
+	}
+
+	if err := h.service.DeleteSchedule(id); err != nil {
+		c.JSON(recurringErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// transition pausa ou retoma a série e responde com o estado atualizado
+func (h *RecurringScheduleHandler) transition(c *gin.Context, action func(uuid.UUID) error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule ID"})
+		return
+	}
+
+	if err := action(id); err != nil {
+		c.JSON(recurringErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := h.service.GetSchedule(id)
+	if err != nil {
+		c.JSON(recurringErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+func recurringErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrRecurringScheduleState):
+		return http.StatusConflict
+	case service.IsInvalidRecurringSchedule(err):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
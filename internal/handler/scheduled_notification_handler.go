@@ -1,20 +1,40 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
 	"github.com/prefeitura-rio/app-notification-core/internal/repository"
+	"github.com/prefeitura-rio/app-notification-core/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type ScheduledNotificationHandler struct {
-	repo repository.NotificationRepository
+	repo    repository.NotificationRepository
+	service service.NotificationService
 }
 
-func NewScheduledNotificationHandler(repo repository.NotificationRepository) *ScheduledNotificationHandler {
-	return &ScheduledNotificationHandler{repo: repo}
+func NewScheduledNotificationHandler(repo repository.NotificationRepository, service service.NotificationService) *ScheduledNotificationHandler {
+	return &ScheduledNotificationHandler{repo: repo, service: service}
+}
+
+// UpdateScheduledRequest traz os campos a alterar; os omitidos mantêm o valor atual
+type UpdateScheduledRequest struct {
+	Version      int            `json:"version" binding:"required"` // versão lida da notificação (concorrência otimista)
+	ScheduledFor *string        `json:"scheduled_for,omitempty"`    // RFC3339
+	LocalTime    *string        `json:"local_time,omitempty"`       // 2006-01-02T15:04:05, no fuso do destinatário
+	Title        *string        `json:"title,omitempty"`
+	Message      *string        `json:"message,omitempty"`
+	Type         *string        `json:"type,omitempty"` // canais: in-app, push, email, both ou all
+	Priority     *string        `json:"priority,omitempty"`
+	Data         map[string]any `json:"data,omitempty"`
+	IsHTML       *bool          `json:"is_html,omitempty"`
+	ExpiresAt    *time.Time     `json:"expires_at,omitempty"`
 }
 
 // ListScheduled godoc
@@ -64,3 +84,73 @@ func (h *ScheduledNotificationHandler) CancelScheduled(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "scheduled notification cancelled"})
 }
+
+// UpdateScheduled godoc
+// @Summary Editar notificação agendada
+// @Description Altera horário (scheduled_for ou local_time), conteúdo e canais de uma notificação ainda agendada, mantendo seu ID e histórico. Informe a version lida: se a notificação foi alterada ou reservada pelo scheduler desde então, responde 409.
+// @Tags scheduled-notifications
+// @Accept json
+// @Produce json
+// @Param id path string true "ID da notificação"
+// @Param changes body UpdateScheduledRequest true "Campos a alterar e versão atual"
+// @Success 200 {object} entity.Notification
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /scheduled-notifications/{id} [patch]
+func (h *ScheduledNotificationHandler) UpdateScheduled(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification ID"})
+		return
+	}
+
+	var req UpdateScheduledRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	changes := service.ScheduledChanges{
+		LocalTime: req.LocalTime,
+		Title:     req.Title,
+		Message:   req.Message,
+		Data:      req.Data,
+		IsHTML:    req.IsHTML,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if req.ScheduledFor != nil {
+		scheduledTime, err := time.Parse(time.RFC3339, *req.ScheduledFor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid scheduled_for format, use RFC3339"})
+			return
+		}
+		changes.ScheduledFor = &scheduledTime
+	}
+	if req.Type != nil {
+		notificationType := entity.NotificationType(*req.Type)
+		changes.Type = &notificationType
+	}
+	if req.Priority != nil {
+		priority := entity.NotificationPriority(*req.Priority)
+		changes.Priority = &priority
+	}
+
+	notification, err := h.service.UpdateScheduled(id, req.Version, changes)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+		case errors.Is(err, service.ErrNotScheduled), errors.Is(err, service.ErrVersionConflict):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidScheduledChanges):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(sendErrorStatus(err), gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
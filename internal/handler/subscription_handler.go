@@ -5,20 +5,24 @@ import (
 
 	"github.com/prefeitura-rio/app-notification-core/internal/entity"
 	"github.com/prefeitura-rio/app-notification-core/internal/repository"
+	"github.com/prefeitura-rio/app-notification-core/internal/service"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
 	"github.com/gin-gonic/gin"
 )
 
 type SubscriptionHandler struct {
-	repo repository.SubscriptionRepository
+	repo       repository.SubscriptionRepository
+	recipients service.RecipientService
 }
 
-func NewSubscriptionHandler(repo repository.SubscriptionRepository) *SubscriptionHandler {
-	return &SubscriptionHandler{repo: repo}
+func NewSubscriptionHandler(repo repository.SubscriptionRepository, recipients service.RecipientService) *SubscriptionHandler {
+	return &SubscriptionHandler{repo: repo, recipients: recipients}
 }
 
 type SubscribeRequest struct {
 	UserCPF   string `json:"user_cpf,omitempty"`
 	UserPhone string `json:"user_phone,omitempty"`
+	UserEmail string `json:"user_email,omitempty"`
 	Endpoint  string `json:"endpoint" binding:"required"`
 	P256dh    string `json:"p256dh" binding:"required"`
 	Auth      string `json:"auth" binding:"required"`
@@ -42,14 +46,31 @@ func (h *SubscriptionHandler) Subscribe(c *gin.Context) {
 		return
 	}
 
+	cpf, phone, email, err := validation.NormalizeIdentity(req.UserCPF, req.UserPhone, req.UserEmail)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	subscription := &entity.Subscription{
-		UserCPF:   req.UserCPF,
-		UserPhone: req.UserPhone,
+		UserCPF:   cpf,
+		UserPhone: phone,
+		UserEmail: email,
 		Endpoint:  req.Endpoint,
 		P256dh:    req.P256dh,
 		Auth:      req.Auth,
 	}
 
+	// Vincular o dispositivo ao destinatário dono dos identificadores
+	if cpf != "" || phone != "" || email != "" {
+		recipient, err := h.recipients.Resolve(cpf, phone, email, "")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		subscription.RecipientID = &recipient.ID
+	}
+
 	if err := h.repo.Create(subscription); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
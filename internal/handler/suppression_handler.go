@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SuppressionHandler struct {
+	service service.SuppressionService
+}
+
+func NewSuppressionHandler(service service.SuppressionService) *SuppressionHandler {
+	return &SuppressionHandler{service: service}
+}
+
+type SuppressionRequest struct {
+	Channel   string     `json:"channel" binding:"required"` // email ou phone
+	Address   string     `json:"address" binding:"required"`
+	Reason    string     `json:"reason,omitempty"` // hard_bounce, complaint, do_not_contact ou manual
+	Source    string     `json:"source,omitempty"`
+	Note      string     `json:"note,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // ausente = permanente
+}
+
+type ImportSuppressionsRequest struct {
+	Entries []SuppressionRequest `json:"entries" binding:"required"`
+}
+
+func (r SuppressionRequest) toEntity() entity.Suppression {
+	return entity.Suppression{
+		Channel:   entity.SuppressionChannel(r.Channel),
+		Address:   r.Address,
+		Reason:    entity.SuppressionReason(r.Reason),
+		Source:    r.Source,
+		Note:      r.Note,
+		ExpiresAt: r.ExpiresAt,
+	}
+}
+
+// Create godoc
+// @Summary Suprimir endereço
+// @Description Adiciona um email ou telefone à lista global de supressão. Se o endereço já estiver na lista, motivo e validade são atualizados.
+// @Tags suppressions
+// @Accept json
+// @Produce json
+// @Param suppression body SuppressionRequest true "Endereço a suprimir"
+// @Success 201 {object} entity.Suppression
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /suppressions [post]
+func (h *SuppressionHandler) Create(c *gin.Context) {
+	var req SuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	suppression := req.toEntity()
+	if err := h.service.Suppress(&suppression); err != nil {
+		if service.IsInvalidSuppression(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, suppression)
+}
+
+// Import godoc
+// @Summary Importar lista de supressão
+// @Description Importa endereços em lote, em JSON ({"entries": [...]}) ou CSV (text/csv) com cabeçalho channel,address,reason,expires_at,note. Linhas inválidas são reportadas sem interromper a importação.
+// @Tags suppressions
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param entries body ImportSuppressionsRequest true "Endereços a suprimir"
+// @Param source query string false "Origem aplicada às linhas sem origem" default(import)
+// @Success 200 {object} service.SuppressionImportResult
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /suppressions/import [post]
+func (h *SuppressionHandler) Import(c *gin.Context) {
+	var entries []SuppressionRequest
+	if strings.HasPrefix(c.ContentType(), "text/csv") {
+		var err error
+		if entries, err = parseSuppressionCSV(c.Request.Body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		var req ImportSuppressionsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		entries = req.Entries
+	}
+
+	source := c.DefaultQuery("source", "import")
+	suppressions := make([]entity.Suppression, len(entries))
+	for i, entry := range entries {
+		suppressions[i] = entry.toEntity()
+		if suppressions[i].Source == "" {
+			suppressions[i].Source = source
+		}
+	}
+
+	result, err := h.service.ImportSuppressions(suppressions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// List godoc
+// @Summary Listar supressões
+// @Description Lista os endereços da lista de supressão, incluindo os já expirados
+// @Tags suppressions
+// @Produce json
+// @Param channel query string false "Filtrar por canal (email ou phone)"
+// @Param limit query int false "Limite de resultados" default(20)
+// @Param offset query int false "Offset para paginação" default(0)
+// @Success 200 {array} entity.Suppression
+// @Failure 500 {object} map[string]string
+// @Router /suppressions [get]
+func (h *SuppressionHandler) List(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	suppressions, err := h.service.ListSuppressions(entity.SuppressionChannel(c.Query("channel")), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, suppressions)
+}
+
+// Check godoc
+// @Summary Verificar supressão
+// @Description Indica se o endereço possui uma supressão vigente no canal
+// @Tags suppressions
+// @Produce json
+// @Param channel query string true "Canal (email ou phone)"
+// @Param address query string true "Email ou telefone"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /suppressions/check [get]
+func (h *SuppressionHandler) Check(c *gin.Context) {
+	channel := entity.SuppressionChannel(c.Query("channel"))
+	address := c.Query("address")
+	if channel == "" || address == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "channel and address are required"})
+		return
+	}
+
+	suppressed, err := h.service.IsSuppressed(channel, address)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"channel": channel, "address": address, "suppressed": suppressed})
+}
+
+// Get godoc
+// @Summary Buscar supressão
+// @Tags suppressions
+// @Produce json
+// @Param id path string true "ID da supressão"
+// @Success 200 {object} entity.Suppression
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /suppressions/{id} [get]
+func (h *SuppressionHandler) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid suppression ID"})
+		return
+	}
+
+	suppression, err := h.service.GetSuppression(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "suppression not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, suppression)
+}
+
+// Delete godoc
+// @Summary Remover supressão
+// @Description Remove o endereço da lista, permitindo novos contatos
+// @Tags suppressions
+// @Param id path string true "ID da supressão"
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /suppressions/{id} [delete]
+func (h *SuppressionHandler) Delete(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid suppression ID"})
+		return
+	}
+
+	if err := h.service.RemoveSuppression(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// parseSuppressionCSV lê o CSV de importação; as colunas são identificadas pelo cabeçalho
+func parseSuppressionCSV(body io.Reader) ([]SuppressionRequest, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("missing CSV header")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["channel"]; !ok {
+		return nil, errors.New("CSV header must include channel and address")
+	}
+	if _, ok := columns["address"]; !ok {
+		return nil, errors.New("CSV header must include channel and address")
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var entries []SuppressionRequest
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entry := SuppressionRequest{
+			Channel: field(record, "channel"),
+			Address: field(record, "address"),
+			Reason:  field(record, "reason"),
+			Source:  field(record, "source"),
+			Note:    field(record, "note"),
+		}
+		if value := field(record, "expires_at"); value != "" {
+			expiresAt, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, errors.New("invalid expires_at " + strconv.Quote(value) + ", expected RFC3339")
+			}
+			entry.ExpiresAt = &expiresAt
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -1,11 +1,16 @@
 package handler
 
 import (
+	"errors"
+	"log"
 	"net/http"
 
+	"github.com/prefeitura-rio/app-notification-core/internal/service"
 	"github.com/prefeitura-rio/app-notification-core/internal/websocket"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
 	"github.com/gin-gonic/gin"
 	ws "github.com/gorilla/websocket"
+	"gorm.io/gorm"
 )
 
 var upgrader = ws.Upgrader{
@@ -17,11 +22,12 @@ var upgrader = ws.Upgrader{
 }
 
 type WebSocketHandler struct {
-	hub *websocket.Hub
+	hub        *websocket.Hub
+	recipients service.RecipientService
 }
 
-func NewWebSocketHandler(hub *websocket.Hub) *WebSocketHandler {
-	return &WebSocketHandler{hub: hub}
+func NewWebSocketHandler(hub *websocket.Hub, recipients service.RecipientService) *WebSocketHandler {
+	return &WebSocketHandler{hub: hub, recipients: recipients}
 }
 
 // ServeWS godoc
@@ -39,12 +45,31 @@ func (h *WebSocketHandler) ServeWS(c *gin.Context) {
 		return
 	}
 
+	// A conexão não é autenticada: só busca o destinatário, sem criá-lo. Identificadores ainda
+	// sem destinatário conectam pelo próprio identificador normalizado.
+	var clientID string
+	recipient, err := h.recipients.LookupIdentifier(userID)
+	switch {
+	case err == nil:
+		clientID = recipient.ID.String()
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		cpf, phone, email, _ := validation.ClassifyIdentifier(userID)
+		clientID = cpf + phone + email
+	case validation.IsValidationError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	default:
+		log.Printf("WebSocket: Failed to look up recipient for %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to look up user"})
+		return
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		return
 	}
 
-	client := websocket.NewClient(h.hub, conn, userID)
+	client := websocket.NewClient(h.hub, conn, clientID)
 	h.hub.Register(client)
 
 	go client.WritePump()
@@ -0,0 +1,404 @@
+package outbox
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/repository"
+	"github.com/prefeitura-rio/app-notification-core/pkg/queue"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPollInterval = 1 * time.Second
+	defaultBatchSize    = 100
+	defaultRetention    = 24 * time.Hour
+	defaultMaxPending   = 10000
+	defaultMaxAttempts  = 20
+
+	maxBackoff    = 30 * time.Second
+	pruneInterval = time.Hour
+
+	// claimLease é o prazo da reserva de um lote; vencido (relay derrubado), outro relay o retoma
+	claimLease = time.Minute
+	// maxMessageBackoff limita o adiamento de uma mensagem que falhou
+	maxMessageBackoff = 5 * time.Minute
+	// publishConcurrency é o número de mensagens do lote publicadas em paralelo, para que a
+	// confirmação de cada uma não espere a da anterior (ver RABBITMQ_PUBLISH_CHANNELS)
+	publishConcurrency = 8
+
+	// capacityInterval é o intervalo entre as verificações da profundidade da fila e do
+	// outbox; enquanto a fila está saturada, é também o intervalo entre os ciclos
+	capacityInterval = 2 * time.Second
+	// saturatedRetryAfter é o tempo sugerido aos clientes cujos envios foram recusados
+	saturatedRetryAfter = 10 * time.Second
+)
+
+// Publisher publica a notificação na fila e informa se ela está saturada (implementado por
+// queue.Queue)
+type Publisher interface {
+	PublishNotification(notification *entity.Notification) error
+	Saturated() (bool, error)
+}
+
+// Relay publica na fila as mensagens pendentes do outbox. Roda em todas as instâncias: cada
+// lote é reservado com SKIP LOCKED e um lease curto, numa transação que termina antes da
+// publicação, então cada mensagem é publicada por um único relay sem manter locks durante a
+// publicação. As mensagens do lote são publicadas em paralelo, sem ordem entre elas. Se o
+// processo cair entre a publicação e a marcação, a mensagem é publicada de novo quando o lease
+// vencer (entrega ao menos uma vez).
+//
+// Uma mensagem que falha é adiada com backoff e o lote segue, para que ela não trave as
+// demais; depois de maxAttempts tentativas ela é separada (parked_at) e não é mais publicada.
+//
+// Com a fila acima da marca de saturação o relay deixa de publicar e as mensagens aguardam no
+// outbox; acumuladas mais de maxPending, Saturated passa a recusar novos envios.
+type Relay struct {
+	outboxRepo       repository.OutboxRepository
+	notificationRepo repository.NotificationRepository
+	publisher        Publisher
+	pollInterval     time.Duration
+	batchSize        int
+	retention        time.Duration
+	maxPending       int64
+	maxAttempts      int
+	owner            string
+
+	mu        sync.Mutex
+	queueFull bool
+	pending   int64
+	checkedAt time.Time
+
+	wake     chan struct{}
+	stopChan chan struct{}
+	done     chan struct{}
+}
+
+func NewRelay(
+	outboxRepo repository.OutboxRepository,
+	notificationRepo repository.NotificationRepository,
+	publisher Publisher,
+	pollInterval time.Duration,
+	batchSize int,
+	retention time.Duration,
+	maxPending int,
+	maxAttempts int,
+) *Relay {
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if retention <= 0 {
+		retention = defaultRetention
+	}
+	if maxPending <= 0 {
+		maxPending = defaultMaxPending
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	return &Relay{
+		outboxRepo:       outboxRepo,
+		notificationRepo: notificationRepo,
+		publisher:        publisher,
+		pollInterval:     pollInterval,
+		batchSize:        batchSize,
+		retention:        retention,
+		maxPending:       int64(maxPending),
+		maxAttempts:      maxAttempts,
+		owner:            relayID(),
+		wake:             make(chan struct{}, 1),
+		stopChan:         make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+}
+
+// Start inicia o relay em background
+func (r *Relay) Start() {
+	log.Println("📮 Outbox relay started")
+	go r.run()
+}
+
+// Stop para o relay, aguardando o lote em andamento
+func (r *Relay) Stop() {
+	close(r.stopChan)
+	<-r.done
+	log.Println("📮 Outbox relay stopped")
+}
+
+// Wake antecipa o próximo ciclo; chamado após gravar novas mensagens no outbox
+func (r *Relay) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Saturated indica se novos envios devem ser recusados, com o tempo sugerido para nova
+// tentativa: a fila está acima da marca de saturação ou o outbox acumulou maxPending mensagens
+func (r *Relay) Saturated() (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.queueFull || r.pending >= r.maxPending {
+		return saturatedRetryAfter, true
+	}
+	return 0, false
+}
+
+func (r *Relay) run() {
+	defer close(r.done)
+
+	wait := r.pollInterval
+	lastPrune := time.Time{}
+	for {
+		if r.checkCapacity() {
+			// Fila saturada: as mensagens aguardam no outbox até os workers a esvaziarem
+			wait = capacityInterval
+		} else if err := r.dispatch(); errors.Is(err, queue.ErrQueueSaturated) {
+			r.setQueueFull(true)
+			wait = capacityInterval
+		} else if err != nil {
+			// Fila indisponível: espaçar as tentativas para não sobrecarregar o broker e o log
+			log.Printf("⚠️ Outbox relay: %v (retrying in %s)", err, wait)
+			wait *= 2
+			if wait > maxBackoff {
+				wait = maxBackoff
+			}
+		} else {
+			wait = r.pollInterval
+		}
+
+		if time.Since(lastPrune) >= pruneInterval {
+			r.prune()
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-r.stopChan:
+			return
+		case <-r.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Stats descreve o outbox para o GET /queue/stats: mensagens aguardando publicação e
+// mensagens separadas depois de esgotar as tentativas
+func (r *Relay) Stats() map[string]interface{} {
+	stats := map[string]interface{}{"max_attempts": r.maxAttempts}
+	if pending, err := r.outboxRepo.CountPending(); err != nil {
+		stats["error"] = err.Error()
+	} else {
+		stats["pending"] = pending
+	}
+	if parked, err := r.outboxRepo.CountParked(); err != nil {
+		stats["error"] = err.Error()
+	} else {
+		stats["parked"] = parked
+	}
+	return stats
+}
+
+// checkCapacity atualiza a profundidade da fila e do outbox, no máximo a cada
+// capacityInterval, e indica se a fila está saturada
+func (r *Relay) checkCapacity() bool {
+	r.mu.Lock()
+	queueFull, checkedAt := r.queueFull, r.checkedAt
+	r.mu.Unlock()
+	if time.Since(checkedAt) < capacityInterval {
+		return queueFull
+	}
+
+	if pending, err := r.outboxRepo.CountPending(); err != nil {
+		log.Printf("⚠️ Outbox relay: failed to count pending messages: %v", err)
+	} else {
+		r.mu.Lock()
+		r.pending = pending
+		r.mu.Unlock()
+	}
+
+	saturated, err := r.publisher.Saturated()
+	if err != nil {
+		// Sem a profundidade, a publicação segue e uma recusa da fila marca a saturação
+		log.Printf("⚠️ Outbox relay: %v", err)
+		saturated = queueFull
+	}
+	r.setQueueFull(saturated)
+
+	r.mu.Lock()
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+	return saturated
+}
+
+func (r *Relay) setQueueFull(full bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if full != r.queueFull {
+		if full {
+			log.Printf("🚦 Outbox relay: queue saturated, holding %d message(s) in the outbox", r.pending)
+		} else {
+			log.Println("🚦 Outbox relay: queue below the high-water mark, resuming")
+		}
+	}
+	r.queueFull = full
+}
+
+// dispatch publica lotes até esvaziar o outbox ou ocorrer um erro
+func (r *Relay) dispatch() error {
+	for {
+		messages, err := r.outboxRepo.ClaimPending(r.owner, claimLease, r.batchSize)
+		if err != nil {
+			return err
+		}
+		dispatched, err := r.dispatchBatch(messages)
+		if dispatched > 0 {
+			log.Printf("📮 Outbox relay: %d notification(s) published", dispatched)
+		}
+		if err != nil {
+			return err
+		}
+		if len(messages) < r.batchSize {
+			return nil
+		}
+
+		select {
+		case <-r.stopChan:
+			return nil
+		default:
+		}
+	}
+}
+
+// dispatchBatch publica o lote reservado, publishConcurrency mensagens por vez. A falha de uma
+// mensagem a adia e o lote segue; com a fila saturada ou duas falhas seguidas (fila
+// provavelmente indisponível), as mensagens ainda não publicadas são devolvidas sem contar
+// tentativa e o erro é retornado.
+func (r *Relay) dispatchBatch(messages []entity.OutboxMessage) (int, error) {
+	dispatched, consecutive := 0, 0
+	for start := 0; start < len(messages); start += publishConcurrency {
+		chunk := messages[start:min(start+publishConcurrency, len(messages))]
+		results := r.publishAll(chunk)
+
+		// Os resultados são tratados na ordem do lote; mensagens já publicadas são marcadas
+		// mesmo depois de a saturação interromper o lote
+		var unpublished []entity.OutboxMessage
+		var stopErr error
+		for i, message := range chunk {
+			publishErr := results[i]
+			switch {
+			case publishErr == nil:
+				consecutive = 0
+				if err := r.outboxRepo.MarkDispatched(message.ID, r.owner); err != nil {
+					return dispatched, r.release(messages[start+i+1:], err)
+				}
+				dispatched++
+			case stopErr != nil || errors.Is(publishErr, queue.ErrQueueSaturated):
+				unpublished = append(unpublished, message)
+				if stopErr == nil {
+					stopErr = publishErr
+				}
+			default:
+				consecutive++
+				r.fail(message, publishErr)
+				if consecutive >= 2 {
+					stopErr = fmt.Errorf("outbox message %s: %w", message.ID, publishErr)
+				}
+			}
+		}
+		if stopErr != nil {
+			return dispatched, r.release(append(unpublished, messages[start+len(chunk):]...), stopErr)
+		}
+	}
+	return dispatched, nil
+}
+
+// publishAll publica as mensagens em paralelo e retorna o erro de cada uma, na mesma ordem
+func (r *Relay) publishAll(messages []entity.OutboxMessage) []error {
+	results := make([]error, len(messages))
+	var wg sync.WaitGroup
+	for i := range messages {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = r.publish(messages[i])
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// fail adia a mensagem com backoff exponencial ou, esgotadas as tentativas, a separa
+func (r *Relay) fail(message entity.OutboxMessage, cause error) {
+	attempts := message.Attempts + 1
+	backoff := time.Second << min(attempts, 10)
+	if backoff > maxMessageBackoff {
+		backoff = maxMessageBackoff
+	}
+	parked, err := r.outboxRepo.MarkFailed(message.ID, r.owner, cause, time.Now().Add(backoff), r.maxAttempts)
+	if err != nil {
+		log.Printf("⚠️ Outbox relay: failed to record failure of message %s: %v", message.ID, err)
+		return
+	}
+	if parked {
+		log.Printf("❌ Outbox relay: message %s parked after %d attempt(s), notification %s marked as failed: %v",
+			message.ID, attempts, message.NotificationID, cause)
+		return
+	}
+	log.Printf("⚠️ Outbox relay: message %s failed (attempt %d of %d), retrying in %s: %v",
+		message.ID, attempts, r.maxAttempts, backoff, cause)
+}
+
+// release devolve as mensagens não publicadas e retorna cause
+func (r *Relay) release(messages []entity.OutboxMessage, cause error) error {
+	ids := make([]uuid.UUID, len(messages))
+	for i := range messages {
+		ids[i] = messages[i].ID
+	}
+	if err := r.outboxRepo.ReleaseClaims(ids, r.owner); err != nil {
+		log.Printf("⚠️ Outbox relay: failed to release %d message(s): %v", len(ids), err)
+	}
+	return cause
+}
+
+func (r *Relay) publish(message entity.OutboxMessage) error {
+	notification, err := r.notificationRepo.FindByID(message.NotificationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Notificação removida antes da publicação: nada a enviar
+		log.Printf("Outbox relay: Notification %s no longer exists, skipping", message.NotificationID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return r.publisher.PublishNotification(notification)
+}
+
+// prune remove as mensagens já publicadas há mais tempo que a retenção
+func (r *Relay) prune() {
+	deleted, err := r.outboxRepo.DeleteDispatched(time.Now().Add(-r.retention))
+	if err != nil {
+		log.Printf("⚠️ Outbox relay: failed to prune dispatched messages: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("📮 Outbox relay: %d dispatched message(s) pruned", deleted)
+	}
+}
+
+// relayID identifica a instância nas reservas do outbox
+func relayID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "relay"
+	}
+	return host + "-" + uuid.NewString()[:8]
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ConsentRepository expõe apenas inserção e consulta: o ledger de consentimento é append-only
+type ConsentRepository interface {
+	Create(consent *entity.Consent) error
+	FindByRecipient(recipientID uuid.UUID, limit, offset int) ([]entity.Consent, error)
+	FindLatest(recipientID uuid.UUID, channel entity.ConsentChannel, purpose string) (*entity.Consent, error)
+	FindCurrent(recipientID uuid.UUID) ([]entity.Consent, error)
+}
+
+type consentRepository struct {
+	db *gorm.DB
+}
+
+func NewConsentRepository(db *gorm.DB) ConsentRepository {
+	return &consentRepository{db: db}
+}
+
+func (r *consentRepository) Create(consent *entity.Consent) error {
+	return r.db.Create(consent).Error
+}
+
+// FindByRecipient retorna o histórico completo de consentimentos do destinatário
+func (r *consentRepository) FindByRecipient(recipientID uuid.UUID, limit, offset int) ([]entity.Consent, error) {
+	var consents []entity.Consent
+	err := r.db.Where("recipient_id = ?", recipientID).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&consents).Error
+	return consents, err
+}
+
+// FindLatest retorna o registro mais recente para o canal e finalidade
+func (r *consentRepository) FindLatest(recipientID uuid.UUID, channel entity.ConsentChannel, purpose string) (*entity.Consent, error) {
+	var consent entity.Consent
+	err := r.db.Where("recipient_id = ? AND channel = ? AND purpose = ?", recipientID, channel, purpose).
+		Order("created_at DESC").
+		First(&consent).Error
+	return &consent, err
+}
+
+// FindCurrent retorna o estado atual (registro mais recente) de cada canal/finalidade
+func (r *consentRepository) FindCurrent(recipientID uuid.UUID) ([]entity.Consent, error) {
+	var consents []entity.Consent
+	err := r.db.Raw(`SELECT DISTINCT ON (channel, purpose) * FROM consents
+		WHERE recipient_id = ?
+		ORDER BY channel, purpose, created_at DESC`, recipientID).
+		Scan(&consents).Error
+	return consents, err
+}
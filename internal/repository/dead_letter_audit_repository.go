@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"gorm.io/gorm"
+)
+
+type DeadLetterAuditRepository interface {
+	Create(audit *entity.DeadLetterAudit) error
+	FindAll(limit, offset int) ([]entity.DeadLetterAudit, error)
+}
+
+type deadLetterAuditRepository struct {
+	db *gorm.DB
+}
+
+func NewDeadLetterAuditRepository(db *gorm.DB) DeadLetterAuditRepository {
+	return &deadLetterAuditRepository{db: db}
+}
+
+func (r *deadLetterAuditRepository) Create(audit *entity.DeadLetterAudit) error {
+	return r.db.Create(audit).Error
+}
+
+func (r *deadLetterAuditRepository) FindAll(limit, offset int) ([]entity.DeadLetterAudit, error) {
+	var audits []entity.DeadLetterAudit
+	err := r.db.Order("created_at DESC").Limit(limit).Offset(offset).Find(&audits).Error
+	return audits, err
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type DeliveryRepository interface {
+	Create(delivery *entity.Delivery) error
+	FindByNotification(notificationID uuid.UUID) ([]entity.Delivery, error)
+	FindByProviderMessageID(channel entity.DeliveryChannel, messageID string) ([]entity.Delivery, error)
+	UpdateStatus(id uuid.UUID, status entity.DeliveryStatus, detail string) error
+}
+
+type deliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewDeliveryRepository(db *gorm.DB) DeliveryRepository {
+	return &deliveryRepository{db: db}
+}
+
+func (r *deliveryRepository) Create(delivery *entity.Delivery) error {
+	return r.db.Create(delivery).Error
+}
+
+func (r *deliveryRepository) FindByNotification(notificationID uuid.UUID) ([]entity.Delivery, error) {
+	var deliveries []entity.Delivery
+	err := r.db.Where("notification_id = ?", notificationID).Order("created_at ASC").Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *deliveryRepository) FindByProviderMessageID(channel entity.DeliveryChannel, messageID string) ([]entity.Delivery, error) {
+	var deliveries []entity.Delivery
+	err := r.db.Where("channel = ? AND provider_message_id = ?", channel, messageID).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *deliveryRepository) UpdateStatus(id uuid.UUID, status entity.DeliveryStatus, detail string) error {
+	return r.db.Model(&entity.Delivery{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status": status,
+		"error":  detail,
+	}).Error
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/prefeitura-rio/app-notification-core/internal/entity"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type NotificationRepository interface {
@@ -15,14 +16,29 @@ type NotificationRepository interface {
 	FindByCPF(cpf string, limit, offset int) ([]entity.Notification, error)
 	FindByPhone(phone string, limit, offset int) ([]entity.Notification, error)
 	FindByEmail(email string, limit, offset int) ([]entity.Notification, error)
+	FindByRecipient(recipient *entity.Recipient, limit, offset int) ([]entity.Notification, error)
 	FindByGroupID(groupID uuid.UUID, limit, offset int) ([]entity.Notification, error)
 	Update(notification *entity.Notification) error
 	Delete(id uuid.UUID) error
 	MarkAsRead(id uuid.UUID) error
 	UpdateStatus(id uuid.UUID, status entity.NotificationStatus) error
-	FindScheduledReady(before time.Time) ([]entity.Notification, error)
+	// ClaimScheduledReady reserva até limit notificações agendadas cujo horário chegou para a
+	// instância owner, por lease. Reservas vencidas (instância derrubada) são retomadas.
+	ClaimScheduledReady(owner string, now time.Time, lease time.Duration, limit int) ([]entity.Notification, error)
 	FindScheduled(limit, offset int) ([]entity.Notification, error)
 	CancelScheduled(id uuid.UUID) error
+	// UpdateScheduled grava as alterações de uma notificação ainda agendada, desde que ela esteja
+	// na versão version, e incrementa a versão. Retorna false se a notificação foi alterada,
+	// reservada pelo scheduler ou cancelada nesse meio tempo.
+	UpdateScheduled(notification *entity.Notification, version int) (bool, error)
+	// CompleteGroupJob encerra o envio agendado a grupo reservado por owner com o status
+	// informado (expanded ou expired)
+	CompleteGroupJob(id uuid.UUID, owner string, status entity.NotificationStatus) (bool, error)
+	// ExtendClaim prorroga até until a reserva de owner. Retorna false se a reserva venceu e
+	// foi retomada por outra instância.
+	ExtendClaim(id uuid.UUID, owner string, until time.Time) (bool, error)
+	// ExistsForParent indica se o envio agendado a grupo parentID já gerou a notificação do destinatário
+	ExistsForParent(parentID, recipientID uuid.UUID) (bool, error)
 }
 
 type notificationRepository struct {
@@ -79,6 +95,28 @@ func (r *notificationRepository) FindByEmail(email string, limit, offset int) ([
 	return notifications, err
 }
 
+// FindByRecipient busca a caixa de entrada do destinatário, incluindo notificações
+// antigas endereçadas diretamente a qualquer um dos seus identificadores
+func (r *notificationRepository) FindByRecipient(recipient *entity.Recipient, limit, offset int) ([]entity.Notification, error) {
+	var notifications []entity.Notification
+	query := r.db.Where("recipient_id = ? OR broadcast = ?", recipient.ID, true)
+	if recipient.CPF != nil {
+		query = query.Or("user_cpf = ?", *recipient.CPF)
+	}
+	if recipient.Phone != nil {
+		query = query.Or("user_phone = ?", *recipient.Phone)
+	}
+	if recipient.Email != nil {
+		query = query.Or("user_email = ?", *recipient.Email)
+	}
+
+	err := query.Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&notifications).Error
+	return notifications, err
+}
+
 func (r *notificationRepository) FindByGroupID(groupID uuid.UUID, limit, offset int) ([]entity.Notification, error) {
 	var notifications []entity.Notification
 	err := r.db.Where("group_id = ?", groupID).
@@ -113,14 +151,49 @@ func (r *notificationRepository) UpdateStatus(id uuid.UUID, status entity.Notifi
 		Update("status", status).Error
 }
 
-// FindScheduledReady busca notificações agendadas prontas para envio
-func (r *notificationRepository) FindScheduledReady(before time.Time) ([]entity.Notification, error) {
+// ClaimScheduledReady trava as linhas com SKIP LOCKED, então instâncias concorrentes reservam
+// notificações diferentes
+func (r *notificationRepository) ClaimScheduledReady(owner string, now time.Time, lease time.Duration, limit int) ([]entity.Notification, error) {
 	var notifications []entity.Notification
-	err := r.db.Where("is_scheduled = ? AND scheduled_for <= ? AND status = ?",
-		true, before, entity.StatusScheduled).
-		Order("scheduled_for ASC").
-		Find(&notifications).Error
-	return notifications, err
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND is_scheduled = ? AND scheduled_for <= ?) OR (status = ? AND claim_expires_at <= ?)",
+				entity.StatusScheduled, true, now, entity.StatusClaimed, now).
+			Order("scheduled_for ASC").
+			Limit(limit).
+			Find(&notifications).Error
+		if err != nil || len(notifications) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(notifications))
+		for i := range notifications {
+			ids[i] = notifications[i].ID
+		}
+		expiresAt := now.Add(lease)
+		err = tx.Model(&entity.Notification{}).
+			Where("id IN ?", ids).
+			Updates(map[string]any{
+				"status":           entity.StatusClaimed,
+				"claimed_by":       owner,
+				"claim_expires_at": expiresAt,
+				"version":          gorm.Expr("version + 1"),
+			}).Error
+		if err != nil {
+			return err
+		}
+		for i := range notifications {
+			notifications[i].Status = entity.StatusClaimed
+			notifications[i].ClaimedBy = &owner
+			notifications[i].ClaimExpiresAt = &expiresAt
+			notifications[i].Version++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
 }
 
 // FindScheduled lista todas as notificações agendadas
@@ -141,5 +214,57 @@ func (r *notificationRepository) CancelScheduled(id uuid.UUID) error {
 		Updates(map[string]interface{}{
 			"status":      entity.StatusCancelled,
 			"is_scheduled": false,
+			"version":     gorm.Expr("version + 1"),
 		}).Error
 }
+
+func (r *notificationRepository) UpdateScheduled(notification *entity.Notification, version int) (bool, error) {
+	result := r.db.Model(&entity.Notification{}).
+		Where("id = ? AND status = ? AND version = ?", notification.ID, entity.StatusScheduled, version).
+		Updates(map[string]any{
+			"title":         notification.Title,
+			"message":       notification.Message,
+			"type":          notification.Type,
+			"priority":      notification.Priority,
+			"data":          notification.Data,
+			"is_html":       notification.IsHTML,
+			"scheduled_for": notification.ScheduledFor,
+			"local_time":    notification.LocalTime,
+			"timezone":      notification.Timezone,
+			"expires_at":    notification.ExpiresAt,
+			"version":       gorm.Expr("version + 1"),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	notification.Version = version + 1
+	return true, nil
+}
+
+func (r *notificationRepository) CompleteGroupJob(id uuid.UUID, owner string, status entity.NotificationStatus) (bool, error) {
+	result := r.db.Model(&entity.Notification{}).
+		Where("id = ? AND status = ? AND claimed_by = ?", id, entity.StatusClaimed, owner).
+		Updates(map[string]any{
+			"status":           status,
+			"is_scheduled":     false,
+			"claimed_by":       nil,
+			"claim_expires_at": nil,
+			"version":          gorm.Expr("version + 1"),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *notificationRepository) ExtendClaim(id uuid.UUID, owner string, until time.Time) (bool, error) {
+	result := r.db.Model(&entity.Notification{}).
+		Where("id = ? AND status = ? AND claimed_by = ?", id, entity.StatusClaimed, owner).
+		Update("claim_expires_at", until)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *notificationRepository) ExistsForParent(parentID, recipientID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&entity.Notification{}).
+		Where("parent_id = ? AND recipient_id = ?", parentID, recipientID).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository interface {
+	// CreateNotification grava a notificação e a mensagem do outbox na mesma transação
+	CreateNotification(notification *entity.Notification) error
+	// ReleaseScheduled passa a notificação reservada por owner para pending e grava a mensagem
+	// do outbox na mesma transação. Retorna false se ela já foi liberada ou se a reserva venceu
+	// e passou para outra instância.
+	ReleaseScheduled(id uuid.UUID, owner string) (bool, error)
+	// ClaimPending reserva para owner, por lease, até limit mensagens pendentes (SKIP LOCKED,
+	// para que vários relays não publiquem a mesma mensagem). A transação termina antes da
+	// publicação; reservas de um relay que caiu vencem e as mensagens são retomadas.
+	ClaimPending(owner string, lease time.Duration, limit int) ([]entity.OutboxMessage, error)
+	// MarkDispatched marca como publicada a mensagem reservada por owner
+	MarkDispatched(id uuid.UUID, owner string) error
+	// MarkFailed registra a falha da mensagem reservada por owner e a adia até retryAt. Com
+	// maxAttempts tentativas, a mensagem é separada e deixa de ser publicada, e a notificação
+	// ainda pendente passa para failed na mesma transação; retorna se a mensagem foi separada.
+	MarkFailed(id uuid.UUID, owner string, cause error, retryAt time.Time, maxAttempts int) (bool, error)
+	// ReleaseClaims devolve as mensagens reservadas por owner que não chegaram a ser publicadas
+	ReleaseClaims(ids []uuid.UUID, owner string) error
+	CountPending() (int64, error)
+	CountParked() (int64, error)
+	DeleteDispatched(before time.Time) (int64, error)
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) CreateNotification(notification *entity.Notification) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(notification).Error; err != nil {
+			return err
+		}
+		return tx.Create(&entity.OutboxMessage{NotificationID: notification.ID}).Error
+	})
+}
+
+func (r *outboxRepository) ReleaseScheduled(id uuid.UUID, owner string) (bool, error) {
+	released := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.Notification{}).
+			Where("id = ? AND status = ? AND claimed_by = ?", id, entity.StatusClaimed, owner).
+			Updates(map[string]any{
+				"status":           entity.StatusPending,
+				"is_scheduled":     false,
+				"claimed_by":       nil,
+				"claim_expires_at": nil,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		released = true
+		return tx.Create(&entity.OutboxMessage{NotificationID: id}).Error
+	})
+	return released, err
+}
+
+func (r *outboxRepository) ClaimPending(owner string, lease time.Duration, limit int) ([]entity.OutboxMessage, error) {
+	var messages []entity.OutboxMessage
+	now := time.Now()
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("dispatched_at IS NULL AND parked_at IS NULL").
+			Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+			Where("claim_expires_at IS NULL OR claim_expires_at <= ?", now).
+			Order("created_at ASC").
+			Limit(limit).
+			Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(messages))
+		for i := range messages {
+			ids[i] = messages[i].ID
+		}
+		expiresAt := now.Add(lease)
+		return tx.Model(&entity.OutboxMessage{}).
+			Where("id IN ?", ids).
+			Updates(map[string]any{
+				"claimed_by":       owner,
+				"claim_expires_at": expiresAt,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *outboxRepository) MarkDispatched(id uuid.UUID, owner string) error {
+	return r.db.Model(&entity.OutboxMessage{}).
+		Where("id = ? AND claimed_by = ?", id, owner).
+		Updates(map[string]any{
+			"attempts":         gorm.Expr("attempts + 1"),
+			"last_error":       "",
+			"claimed_by":       nil,
+			"claim_expires_at": nil,
+			"dispatched_at":    time.Now(),
+		}).Error
+}
+
+func (r *outboxRepository) MarkFailed(id uuid.UUID, owner string, cause error, retryAt time.Time, maxAttempts int) (bool, error) {
+	var message entity.OutboxMessage
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&message).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "notification_id"}, {Name: "parked_at"}}}).
+			Where("id = ? AND claimed_by = ?", id, owner).
+			Updates(map[string]any{
+				"attempts":         gorm.Expr("attempts + 1"),
+				"last_error":       cause.Error(),
+				"claimed_by":       nil,
+				"claim_expires_at": nil,
+				"next_attempt_at":  retryAt,
+				"parked_at":        gorm.Expr("CASE WHEN attempts + 1 >= ? THEN ?::timestamptz END", maxAttempts, time.Now()),
+			})
+		if result.Error != nil || result.RowsAffected == 0 || message.ParkedAt == nil {
+			return result.Error
+		}
+		return tx.Model(&entity.Notification{}).
+			Where("id = ? AND status = ?", message.NotificationID, entity.StatusPending).
+			Update("status", entity.StatusFailed).Error
+	})
+	return err == nil && message.ParkedAt != nil, err
+}
+
+func (r *outboxRepository) ReleaseClaims(ids []uuid.UUID, owner string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&entity.OutboxMessage{}).
+		Where("id IN ? AND claimed_by = ?", ids, owner).
+		Updates(map[string]any{
+			"claimed_by":       nil,
+			"claim_expires_at": nil,
+		}).Error
+}
+
+func (r *outboxRepository) CountPending() (int64, error) {
+	var count int64
+	err := r.db.Model(&entity.OutboxMessage{}).Where("dispatched_at IS NULL AND parked_at IS NULL").Count(&count).Error
+	return count, err
+}
+
+func (r *outboxRepository) CountParked() (int64, error) {
+	var count int64
+	err := r.db.Model(&entity.OutboxMessage{}).Where("parked_at IS NOT NULL").Count(&count).Error
+	return count, err
+}
+
+func (r *outboxRepository) DeleteDispatched(before time.Time) (int64, error) {
+	result := r.db.Where("dispatched_at < ?", before).Delete(&entity.OutboxMessage{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SubjectIdentifiers reúne todos os identificadores conhecidos de um titular de dados
+type SubjectIdentifiers struct {
+	RecipientID *uuid.UUID
+	CPF         string
+	Phone       string
+	Email       string
+}
+
+// PrivacyRepository concentra as operações de direitos do titular (LGPD) que
+// atravessam várias tabelas e precisam ser executadas na mesma transação
+type PrivacyRepository interface {
+	FindNotifications(subject SubjectIdentifiers) ([]entity.Notification, error)
+	FindMemberships(subject SubjectIdentifiers) ([]entity.Member, error)
+	FindSubscriptions(subject SubjectIdentifiers) ([]entity.Subscription, error)
+	FindRecurringSchedules(subject SubjectIdentifiers) ([]entity.RecurringSchedule, error)
+	Erase(subject SubjectIdentifiers, receipt *entity.ErasureReceipt) error
+	FindReceiptByID(id uuid.UUID) (*entity.ErasureReceipt, error)
+	FindReceiptsBySubject(subjectHashes ...string) ([]entity.ErasureReceipt, error)
+}
+
+type privacyRepository struct {
+	db *gorm.DB
+}
+
+func NewPrivacyRepository(db *gorm.DB) PrivacyRepository {
+	return &privacyRepository{db: db}
+}
+
+// FindNotifications retorna as notificações endereçadas ao titular (broadcasts não são dados pessoais)
+func (r *privacyRepository) FindNotifications(subject SubjectIdentifiers) ([]entity.Notification, error) {
+	var notifications []entity.Notification
+	err := subjectScope(r.db, subject, "recipient_id", "user_cpf", "user_phone", "user_email").
+		Order("created_at DESC").
+		Find(&notifications).Error
+	return notifications, err
+}
+
+func (r *privacyRepository) FindMemberships(subject SubjectIdentifiers) ([]entity.Member, error) {
+	var members []entity.Member
+	err := subjectScope(r.db, subject, "", "cpf", "phone", "email").Find(&members).Error
+	return members, err
+}
+
+func (r *privacyRepository) FindSubscriptions(subject SubjectIdentifiers) ([]entity.Subscription, error) {
+	var subscriptions []entity.Subscription
+	err := subjectScope(r.db, subject, "recipient_id", "user_cpf", "user_phone", "user_email").Find(&subscriptions).Error
+	return subscriptions, err
+}
+
+// FindRecurringSchedules retorna as séries recorrentes endereçadas ao titular (séries de grupo e
+// broadcast não guardam dados pessoais)
+func (r *privacyRepository) FindRecurringSchedules(subject SubjectIdentifiers) ([]entity.RecurringSchedule, error) {
+	var schedules []entity.RecurringSchedule
+	err := r.db.Where("target = ?", entity.RecurringTargetUser).
+		Where(subjectScope(r.db.Session(&gorm.Session{NewDB: true}), subject, "", "user_cpf", "user_phone", "user_email")).
+		Order("created_at DESC").
+		Find(&schedules).Error
+	return schedules, err
+}
+
+// Erase elimina notificações (e seus resultados de entrega), séries recorrentes, participações em grupos, dispositivos e o destinatário do titular,
+// anonimiza o ledger de consentimento (mantido como prova da base legal) e grava o comprovante
+func (r *privacyRepository) Erase(subject SubjectIdentifiers, receipt *entity.ErasureReceipt) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Os resultados de entrega guardam o endereço usado e saem junto com as notificações
+		notifications := subjectScope(tx.Session(&gorm.Session{NewDB: true}).Model(&entity.Notification{}), subject,
+			"recipient_id", "user_cpf", "user_phone", "user_email").Select("id")
+		if err := tx.Where("notification_id IN (?)", notifications).Delete(&entity.Delivery{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("notification_id IN (?)", notifications).Delete(&entity.OutboxMessage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("notification_id IN (?)", notifications).Delete(&entity.ProcessingEntry{}).Error; err != nil {
+			return err
+		}
+
+		result := subjectScope(tx, subject, "recipient_id", "user_cpf", "user_phone", "user_email").
+			Delete(&entity.Notification{})
+		if result.Error != nil {
+			return result.Error
+		}
+		receipt.NotificationsDeleted = result.RowsAffected
+
+		result = subjectScope(tx, subject, "", "cpf", "phone", "email").Delete(&entity.Member{})
+		if result.Error != nil {
+			return result.Error
+		}
+		receipt.MembershipsDeleted = result.RowsAffected
+
+		result = subjectScope(tx, subject, "recipient_id", "user_cpf", "user_phone", "user_email").
+			Delete(&entity.Subscription{})
+		if result.Error != nil {
+			return result.Error
+		}
+		receipt.SubscriptionsDeleted = result.RowsAffected
+
+		// As notificações já enviadas pela série saíram acima; a série deixa de existir
+		result = tx.Where("target = ?", entity.RecurringTargetUser).
+			Where(subjectScope(tx.Session(&gorm.Session{NewDB: true}), subject, "", "user_cpf", "user_phone", "user_email")).
+			Delete(&entity.RecurringSchedule{})
+		if result.Error != nil {
+			return result.Error
+		}
+		receipt.RecurringSchedulesDeleted = result.RowsAffected
+
+		if subject.RecipientID != nil {
+			result = tx.Session(&gorm.Session{SkipHooks: true}).Model(&entity.Consent{}).
+				Where("recipient_id = ?", *subject.RecipientID).
+				Updates(map[string]interface{}{
+					"identifier": "",
+					"ip_address": "",
+					"user_agent": "",
+					"evidence":   nil,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			receipt.ConsentsAnonymised = result.RowsAffected
+
+			result = tx.Delete(&entity.Recipient{}, "id = ?", *subject.RecipientID)
+			if result.Error != nil {
+				return result.Error
+			}
+			receipt.RecipientDeleted = result.RowsAffected > 0
+		}
+
+		return tx.Create(receipt).Error
+	})
+}
+
+func (r *privacyRepository) FindReceiptByID(id uuid.UUID) (*entity.ErasureReceipt, error) {
+	var receipt entity.ErasureReceipt
+	err := r.db.First(&receipt, "id = ?", id).Error
+	return &receipt, err
+}
+
+func (r *privacyRepository) FindReceiptsBySubject(subjectHashes ...string) ([]entity.ErasureReceipt, error) {
+	var receipts []entity.ErasureReceipt
+	err := r.db.Where("subject_hash IN ?", subjectHashes).Order("created_at DESC").Find(&receipts).Error
+	return receipts, err
+}
+
+// subjectScope filtra os registros que pertencem ao titular. Telefone e email podem ser
+// compartilhados (ex: telefone da família), então só identificam registros sem outro CPF.
+func subjectScope(db *gorm.DB, subject SubjectIdentifiers, recipientColumn, cpfColumn, phoneColumn, emailColumn string) *gorm.DB {
+	query := db.Where("1 = 0")
+	if recipientColumn != "" && subject.RecipientID != nil {
+		query = query.Or(recipientColumn+" = ?", *subject.RecipientID)
+	}
+	if subject.CPF != "" {
+		query = query.Or(cpfColumn+" = ?", subject.CPF)
+	}
+
+	var conditions []string
+	var args []interface{}
+	if subject.Phone != "" {
+		conditions = append(conditions, phoneColumn+" = ?")
+		args = append(args, subject.Phone)
+	}
+	if subject.Email != "" {
+		conditions = append(conditions, emailColumn+" = ?")
+		args = append(args, subject.Email)
+	}
+	if len(conditions) > 0 {
+		condition := "(" + strings.Join(conditions, " OR ") + ")"
+		if subject.CPF != "" {
+			condition += " AND (" + cpfColumn + " IS NULL OR " + cpfColumn + " = '' OR " + cpfColumn + " = ?)"
+			args = append(args, subject.CPF)
+		}
+		query = query.Or(condition, args...)
+	}
+	return query
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"gorm.io/gorm"
+)
+
+type ProcessedCommandRepository interface {
+	Create(command *entity.ProcessedCommand) error
+	// FindByID retorna gorm.ErrRecordNotFound se o comando ainda não foi processado
+	FindByID(commandID string) (*entity.ProcessedCommand, error)
+}
+
+type processedCommandRepository struct {
+	db *gorm.DB
+}
+
+func NewProcessedCommandRepository(db *gorm.DB) ProcessedCommandRepository {
+	return &processedCommandRepository{db: db}
+}
+
+func (r *processedCommandRepository) Create(command *entity.ProcessedCommand) error {
+	return r.db.Create(command).Error
+}
+
+func (r *processedCommandRepository) FindByID(commandID string) (*entity.ProcessedCommand, error) {
+	var command entity.ProcessedCommand
+	err := r.db.First(&command, "command_id = ?", commandID).Error
+	return &command, err
+}
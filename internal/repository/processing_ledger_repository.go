@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProcessingLedgerRepository interface {
+	// Enqueue registra as etapas publicadas nas filas dos canais. Etapas já registradas
+	// (reentrega da notificação) mantêm o estado atual.
+	Enqueue(notificationID uuid.UUID, legs []entity.DeliveryChannel) error
+	// Claim reserva a etapa por lease de forma atômica. A reserva só é concedida se a etapa
+	// é nova, está na fila, falhou antes ou está com a reserva vencida; caso contrário
+	// retorna false e o estado atual da etapa.
+	Claim(notificationID uuid.UUID, leg entity.DeliveryChannel, owner string, lease time.Duration) (bool, entity.ProcessingState, error)
+	// Complete e Fail só alteram a etapa em processamento reservada por owner; retornam false
+	// se a reserva venceu e foi retomada por outro worker
+	Complete(notificationID uuid.UUID, leg entity.DeliveryChannel, owner string) (bool, error)
+	Fail(notificationID uuid.UUID, leg entity.DeliveryChannel, owner string, cause error) (bool, error)
+	// CountIncomplete conta as etapas da notificação que ainda não foram concluídas
+	CountIncomplete(notificationID uuid.UUID) (int64, error)
+}
+
+type processingLedgerRepository struct {
+	db *gorm.DB
+}
+
+func NewProcessingLedgerRepository(db *gorm.DB) ProcessingLedgerRepository {
+	return &processingLedgerRepository{db: db}
+}
+
+func (r *processingLedgerRepository) Enqueue(notificationID uuid.UUID, legs []entity.DeliveryChannel) error {
+	if len(legs) == 0 {
+		return nil
+	}
+	entries := make([]entity.ProcessingEntry, 0, len(legs))
+	for _, leg := range legs {
+		entries = append(entries, entity.ProcessingEntry{
+			NotificationID: notificationID,
+			Leg:            leg,
+			State:          entity.ProcessingQueued,
+		})
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entries).Error
+}
+
+func (r *processingLedgerRepository) Claim(notificationID uuid.UUID, leg entity.DeliveryChannel, owner string, lease time.Duration) (bool, entity.ProcessingState, error) {
+	now := time.Now()
+	lockedUntil := now.Add(lease)
+	entry := entity.ProcessingEntry{
+		NotificationID: notificationID,
+		Leg:            leg,
+		State:          entity.ProcessingInProgress,
+		Attempts:       1,
+		LockedUntil:    &lockedUntil,
+		LockedBy:       &owner,
+	}
+
+	// INSERT ... ON CONFLICT DO UPDATE ... WHERE: uma única instrução, então dois workers
+	// com a mesma mensagem nunca reservam a mesma etapa
+	result := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "notification_id"}, {Name: "leg"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"state":        entity.ProcessingInProgress,
+			"attempts":     gorm.Expr("processing_entries.attempts + 1"),
+			"locked_until": lockedUntil,
+			"locked_by":    owner,
+			"error":        "",
+			"updated_at":   now,
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{gorm.Expr(
+			"processing_entries.state IN ? OR (processing_entries.state = ? AND processing_entries.locked_until < ?)",
+			[]entity.ProcessingState{entity.ProcessingQueued, entity.ProcessingFailed}, entity.ProcessingInProgress, now,
+		)}},
+	}).Create(&entry)
+	if result.Error != nil {
+		return false, "", result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, entity.ProcessingInProgress, nil
+	}
+
+	var current entity.ProcessingEntry
+	err := r.db.First(&current, "notification_id = ? AND leg = ?", notificationID, leg).Error
+	return false, current.State, err
+}
+
+func (r *processingLedgerRepository) Complete(notificationID uuid.UUID, leg entity.DeliveryChannel, owner string) (bool, error) {
+	return r.release(notificationID, leg, owner, map[string]any{
+		"state":        entity.ProcessingCompleted,
+		"locked_until": nil,
+		"locked_by":    nil,
+		"error":        "",
+		"completed_at": time.Now(),
+	})
+}
+
+func (r *processingLedgerRepository) Fail(notificationID uuid.UUID, leg entity.DeliveryChannel, owner string, cause error) (bool, error) {
+	return r.release(notificationID, leg, owner, map[string]any{
+		"state":        entity.ProcessingFailed,
+		"locked_until": nil,
+		"locked_by":    nil,
+		"error":        cause.Error(),
+	})
+}
+
+// release encerra a reserva de owner; uma etapa já concluída, com falha registrada ou
+// retomada por outro worker não é alterada
+func (r *processingLedgerRepository) release(notificationID uuid.UUID, leg entity.DeliveryChannel, owner string, updates map[string]any) (bool, error) {
+	result := r.db.Model(&entity.ProcessingEntry{}).
+		Where("notification_id = ? AND leg = ? AND state = ? AND locked_by = ?", notificationID, leg, entity.ProcessingInProgress, owner).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *processingLedgerRepository) CountIncomplete(notificationID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&entity.ProcessingEntry{}).
+		Where("notification_id = ? AND state <> ?", notificationID, entity.ProcessingCompleted).
+		Count(&count).Error
+	return count, err
+}
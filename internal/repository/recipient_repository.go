@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RecipientRepository interface {
+	Create(recipient *entity.Recipient) error
+	FindByID(id uuid.UUID) (*entity.Recipient, error)
+	FindByIdentifiers(cpf, phone, email string) ([]entity.Recipient, error)
+	Update(recipient *entity.Recipient) error
+	Merge(primary *entity.Recipient, duplicates []uuid.UUID) error
+}
+
+type recipientRepository struct {
+	db *gorm.DB
+}
+
+func NewRecipientRepository(db *gorm.DB) RecipientRepository {
+	return &recipientRepository{db: db}
+}
+
+func (r *recipientRepository) Create(recipient *entity.Recipient) error {
+	return r.db.Create(recipient).Error
+}
+
+func (r *recipientRepository) FindByID(id uuid.UUID) (*entity.Recipient, error) {
+	var recipient entity.Recipient
+	err := r.db.Preload("Subscriptions").First(&recipient, "id = ?", id).Error
+	return &recipient, err
+}
+
+// FindByIdentifiers busca todos os destinatários que possuem qualquer um dos identificadores informados
+func (r *recipientRepository) FindByIdentifiers(cpf, phone, email string) ([]entity.Recipient, error) {
+	var recipients []entity.Recipient
+	if cpf == "" && phone == "" && email == "" {
+		return recipients, nil
+	}
+
+	query := r.db.Where("1 = 0")
+	if cpf != "" {
+		query = query.Or("cpf = ?", cpf)
+	}
+	if phone != "" {
+		query = query.Or("phone = ?", phone)
+	}
+	if email != "" {
+		query = query.Or("email = ?", email)
+	}
+
+	err := query.Order("created_at ASC").Find(&recipients).Error
+	return recipients, err
+}
+
+func (r *recipientRepository) Update(recipient *entity.Recipient) error {
+	return r.db.Omit("Subscriptions").Save(recipient).Error
+}
+
+// Merge move notificações, dispositivos e consentimentos dos destinatários duplicados para o principal
+// e remove os duplicados, tudo na mesma transação
+func (r *recipientRepository) Merge(primary *entity.Recipient, duplicates []uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if len(duplicates) > 0 {
+			// Cópias do mesmo envio a grupo entregues a identidades que agora se unificam deixam de
+			// apontar para o envio, respeitando o índice único (parent_id, recipient_id)
+			if err := tx.Exec(`UPDATE notifications n SET parent_id = NULL
+				WHERE n.recipient_id IN ? AND n.parent_id IS NOT NULL AND EXISTS (
+					SELECT 1 FROM notifications o
+					WHERE o.parent_id = n.parent_id AND o.id <> n.id
+					AND (o.recipient_id = ? OR (o.recipient_id IN ? AND o.id < n.id)))`,
+				duplicates, primary.ID, duplicates).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&entity.Notification{}).
+				Where("recipient_id IN ?", duplicates).
+				Update("recipient_id", primary.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&entity.Subscription{}).
+				Where("recipient_id IN ?", duplicates).
+				Update("recipient_id", primary.ID).Error; err != nil {
+				return err
+			}
+			// O ledger de consentimento é imutável, mas acompanha a unificação de identidade
+			if err := tx.Session(&gorm.Session{SkipHooks: true}).Model(&entity.Consent{}).
+				Where("recipient_id IN ?", duplicates).
+				Update("recipient_id", primary.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&entity.Recipient{}, "id IN ?", duplicates).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Omit("Subscriptions").Save(primary).Error
+	})
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RecurringScheduleRepository interface {
+	Create(schedule *entity.RecurringSchedule) error
+	FindByID(id uuid.UUID) (*entity.RecurringSchedule, error)
+	FindAll(status entity.RecurringStatus, limit, offset int) ([]entity.RecurringSchedule, error)
+	FindDue(now time.Time, limit int) ([]entity.RecurringSchedule, error)
+	Advance(id uuid.UUID, occurrence time.Time, next *time.Time, status entity.RecurringStatus) (bool, error)
+	Rewind(id uuid.UUID, occurrence time.Time, next *time.Time, status entity.RecurringStatus, lastRunAt *time.Time, message string) (bool, error)
+	Transition(id uuid.UUID, from, to entity.RecurringStatus, next *time.Time) (bool, error)
+	SetLastError(id uuid.UUID, message string) error
+	Delete(id uuid.UUID) error
+}
+
+type recurringScheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewRecurringScheduleRepository(db *gorm.DB) RecurringScheduleRepository {
+	return &recurringScheduleRepository{db: db}
+}
+
+func (r *recurringScheduleRepository) Create(schedule *entity.RecurringSchedule) error {
+	return r.db.Create(schedule).Error
+}
+
+func (r *recurringScheduleRepository) FindByID(id uuid.UUID) (*entity.RecurringSchedule, error) {
+	var schedule entity.RecurringSchedule
+	err := r.db.First(&schedule, "id = ?", id).Error
+	return &schedule, err
+}
+
+func (r *recurringScheduleRepository) FindAll(status entity.RecurringStatus, limit, offset int) ([]entity.RecurringSchedule, error) {
+	var schedules []entity.RecurringSchedule
+	query := r.db.Order("created_at DESC").Limit(limit).Offset(offset)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&schedules).Error
+	return schedules, err
+}
+
+// FindDue busca as séries ativas cuja próxima ocorrência já chegou
+func (r *recurringScheduleRepository) FindDue(now time.Time, limit int) ([]entity.RecurringSchedule, error) {
+	var schedules []entity.RecurringSchedule
+	err := r.db.
+		Where("status = ? AND next_run_at <= ?", entity.RecurringActive, now).
+		Order("next_run_at ASC").
+		Limit(limit).
+		Find(&schedules).Error
+	return schedules, err
+}
+
+// Advance registra a ocorrência e agenda a próxima, desde que a série ainda esteja ativa e
+// na ocorrência esperada. Retorna false se outra instância já a registrou ou se a série foi
+// pausada ou removida nesse meio tempo.
+func (r *recurringScheduleRepository) Advance(id uuid.UUID, occurrence time.Time, next *time.Time, status entity.RecurringStatus) (bool, error) {
+	result := r.db.Model(&entity.RecurringSchedule{}).
+		Where("id = ? AND status = ? AND next_run_at = ?", id, entity.RecurringActive, occurrence).
+		Updates(map[string]any{
+			"occurrences": gorm.Expr("occurrences + 1"),
+			"last_run_at": occurrence,
+			"next_run_at": next,
+			"status":      status,
+			"last_error":  "",
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Rewind desfaz o Advance de uma ocorrência que não foi enviada, para que o próximo ciclo a
+// tente de novo. Só vale se a série continua como o Advance a deixou: uma série pausada,
+// retomada ou removida nesse meio tempo não é alterada.
+func (r *recurringScheduleRepository) Rewind(id uuid.UUID, occurrence time.Time, next *time.Time, status entity.RecurringStatus, lastRunAt *time.Time, message string) (bool, error) {
+	result := r.db.Model(&entity.RecurringSchedule{}).
+		Where("id = ? AND status = ? AND last_run_at = ? AND next_run_at IS NOT DISTINCT FROM ?", id, status, occurrence, next).
+		Updates(map[string]any{
+			"occurrences": gorm.Expr("occurrences - 1"),
+			"last_run_at": lastRunAt,
+			"next_run_at": occurrence,
+			"status":      entity.RecurringActive,
+			"last_error":  message,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Transition muda o status da série (ex: pausar, retomar) se ela estiver no status from
+func (r *recurringScheduleRepository) Transition(id uuid.UUID, from, to entity.RecurringStatus, next *time.Time) (bool, error) {
+	result := r.db.Model(&entity.RecurringSchedule{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]any{"status": to, "next_run_at": next})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *recurringScheduleRepository) SetLastError(id uuid.UUID, message string) error {
+	return r.db.Model(&entity.RecurringSchedule{}).Where("id = ?", id).Update("last_error", message).Error
+}
+
+func (r *recurringScheduleRepository) Delete(id uuid.UUID) error {
+	return r.db.Delete(&entity.RecurringSchedule{}, "id = ?", id).Error
+}
@@ -11,6 +11,7 @@ type SubscriptionRepository interface {
 	FindByEndpoint(endpoint string) (*entity.Subscription, error)
 	FindByCPF(cpf string) ([]entity.Subscription, error)
 	FindByPhone(phone string) ([]entity.Subscription, error)
+	FindByRecipient(recipient *entity.Recipient) ([]entity.Subscription, error)
 	Delete(id uuid.UUID) error
 	DeleteByEndpoint(endpoint string) error
 }
@@ -45,6 +46,25 @@ func (r *subscriptionRepository) FindByPhone(phone string) ([]entity.Subscriptio
 	return subscriptions, err
 }
 
+// FindByRecipient busca os dispositivos do destinatário, incluindo os cadastrados
+// apenas com CPF, telefone ou email antes da unificação de identidade
+func (r *subscriptionRepository) FindByRecipient(recipient *entity.Recipient) ([]entity.Subscription, error) {
+	var subscriptions []entity.Subscription
+	query := r.db.Where("recipient_id = ?", recipient.ID)
+	if recipient.CPF != nil {
+		query = query.Or("user_cpf = ?", *recipient.CPF)
+	}
+	if recipient.Phone != nil {
+		query = query.Or("user_phone = ?", *recipient.Phone)
+	}
+	if recipient.Email != nil {
+		query = query.Or("user_email = ?", *recipient.Email)
+	}
+
+	err := query.Find(&subscriptions).Error
+	return subscriptions, err
+}
+
 func (r *subscriptionRepository) Delete(id uuid.UUID) error {
 	return r.db.Delete(&entity.Subscription{}, "id = ?", id).Error
 }
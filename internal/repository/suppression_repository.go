@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SuppressionRepository interface {
+	Upsert(suppression *entity.Suppression) error
+	UpsertBatch(suppressions []entity.Suppression) error
+	FindByID(id uuid.UUID) (*entity.Suppression, error)
+	FindAll(channel entity.SuppressionChannel, limit, offset int) ([]entity.Suppression, error)
+	FindActive(channel entity.SuppressionChannel, address string, now time.Time) (*entity.Suppression, error)
+	Delete(id uuid.UUID) error
+}
+
+type suppressionRepository struct {
+	db *gorm.DB
+}
+
+func NewSuppressionRepository(db *gorm.DB) SuppressionRepository {
+	return &suppressionRepository{db: db}
+}
+
+// upsertClause atualiza motivo, origem e validade quando o endereço já está na lista
+var upsertClause = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "channel"}, {Name: "address"}},
+	DoUpdates: clause.AssignmentColumns([]string{"reason", "source", "note", "expires_at", "updated_at"}),
+}
+
+// Upsert grava a supressão e a recarrega com a linha resultante (RETURNING): quando o endereço
+// já estava na lista, o ID e o created_at são os do registro existente, não os gerados aqui
+func (r *suppressionRepository) Upsert(suppression *entity.Suppression) error {
+	return r.db.Clauses(upsertClause, clause.Returning{}).Create(suppression).Error
+}
+
+func (r *suppressionRepository) UpsertBatch(suppressions []entity.Suppression) error {
+	if len(suppressions) == 0 {
+		return nil
+	}
+	return r.db.Clauses(upsertClause, clause.Returning{}).CreateInBatches(suppressions, 500).Error
+}
+
+func (r *suppressionRepository) FindByID(id uuid.UUID) (*entity.Suppression, error) {
+	var suppression entity.Suppression
+	err := r.db.First(&suppression, "id = ?", id).Error
+	return &suppression, err
+}
+
+func (r *suppressionRepository) FindAll(channel entity.SuppressionChannel, limit, offset int) ([]entity.Suppression, error) {
+	var suppressions []entity.Suppression
+	query := r.db.Order("created_at DESC").Limit(limit).Offset(offset)
+	if channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	err := query.Find(&suppressions).Error
+	return suppressions, err
+}
+
+// FindActive busca a supressão vigente do endereço (sem validade ou ainda não expirada)
+func (r *suppressionRepository) FindActive(channel entity.SuppressionChannel, address string, now time.Time) (*entity.Suppression, error) {
+	var suppression entity.Suppression
+	err := r.db.Where("channel = ? AND address = ? AND (expires_at IS NULL OR expires_at > ?)", channel, address, now).
+		First(&suppression).Error
+	return &suppression, err
+}
+
+func (r *suppressionRepository) Delete(id uuid.UUID) error {
+	return r.db.Delete(&entity.Suppression{}, "id = ?", id).Error
+}
@@ -1,47 +1,86 @@
 package scheduler
 
 import (
+	"context"
 	"log"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/prefeitura-rio/app-notification-core/internal/entity"
 	"github.com/prefeitura-rio/app-notification-core/internal/repository"
 	"github.com/prefeitura-rio/app-notification-core/internal/service"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultBatchSize  = 100
+	defaultClaimLease = 5 * time.Minute
 )
 
 type NotificationScheduler struct {
 	notificationRepo repository.NotificationRepository
 	notificationService service.NotificationService
+	recurringService service.RecurringScheduleService
+	// owner identifica esta instância nas reservas; batchSize e lease limitam cada reserva
+	owner     string
+	batchSize int
+	lease     time.Duration
 	ticker *time.Ticker
 	stopChan chan bool
+	// ctx é passado aos envios e cancelado em Stop, interrompendo as esperas por capacidade
+	ctx    context.Context
+	cancel context.CancelFunc
+	// done é fechado quando o loop termina; pending acompanha os envios em andamento
+	done    chan struct{}
+	pending sync.WaitGroup
 }
 
 func NewNotificationScheduler(
 	repo repository.NotificationRepository,
 	service service.NotificationService,
+	recurring service.RecurringScheduleService,
+	batchSize int,
+	lease time.Duration,
 ) *NotificationScheduler {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if lease <= 0 {
+		lease = defaultClaimLease
+	}
 	return &NotificationScheduler{
 		notificationRepo: repo,
 		notificationService: service,
+		recurringService: recurring,
+		owner: instanceID(),
+		batchSize: batchSize,
+		lease: lease,
 		stopChan: make(chan bool),
+		done: make(chan struct{}),
 	}
 }
 
-// Start inicia o scheduler que verifica notificações agendadas a cada minuto
-func (s *NotificationScheduler) Start() {
+// Start inicia o scheduler que verifica notificações agendadas e séries recorrentes a cada
+// minuto. Os envios usam um contexto derivado de ctx.
+func (s *NotificationScheduler) Start(ctx context.Context) {
 	log.Println("📅 Notification Scheduler started")
+	s.ctx, s.cancel = context.WithCancel(ctx)
 
 	// Processar imediatamente ao iniciar
 	s.processScheduledNotifications()
+	s.processRecurringSchedules()
 
 	// Processar a cada 1 minuto
 	s.ticker = time.NewTicker(1 * time.Minute)
 
 	go func() {
+		defer close(s.done)
 		for {
 			select {
 			case <-s.ticker.C:
 				s.processScheduledNotifications()
+				s.processRecurringSchedules()
 			case <-s.stopChan:
 				log.Println("📅 Notification Scheduler stopped")
 				return
@@ -50,33 +89,86 @@ func (s *NotificationScheduler) Start() {
 	}()
 }
 
-// Stop para o scheduler
-func (s *NotificationScheduler) Stop() {
+// Stop para o scheduler e aguarda a verificação e os envios em andamento até o prazo de ctx.
+// Envios interrompidos mantêm a reserva e são retomados quando o lease vencer.
+func (s *NotificationScheduler) Stop(ctx context.Context) error {
 	if s.ticker != nil {
 		s.ticker.Stop()
 	}
 	close(s.stopChan)
+	s.cancel()
+
+	finished := make(chan struct{})
+	go func() {
+		<-s.done
+		s.pending.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// processScheduledNotifications busca e processa notificações agendadas que estão prontas para envio
+// processScheduledNotifications reserva e libera, em lotes, as notificações agendadas cujo
+// horário chegou. Cada lote é reservado com SKIP LOCKED, então réplicas concorrentes enviam
+// notificações diferentes; se a instância cair com um lote reservado, outra o retoma quando
+// o lease vencer.
 func (s *NotificationScheduler) processScheduledNotifications() {
-	now := time.Now()
+	for {
+		notifications, err := s.notificationRepo.ClaimScheduledReady(s.owner, time.Now(), s.lease, s.batchSize)
+		if err != nil {
+			log.Printf("❌ Error claiming scheduled notifications: %v", err)
+			return
+		}
+
+		if len(notifications) == 0 {
+			return
+		}
+
+		log.Printf("📅 Claimed %d scheduled notification(s) ready to send", len(notifications))
+
+		var batch sync.WaitGroup
+		for i := range notifications {
+			batch.Add(1)
+			go func(notification *entity.Notification) {
+				defer batch.Done()
+				s.sendScheduledNotification(notification)
+			}(&notifications[i])
+		}
+		batch.Wait()
 
-	// Buscar notificações agendadas cuja hora já passou
-	notifications, err := s.notificationRepo.FindScheduledReady(now)
+		// Lote incompleto: não há mais notificações vencidas
+		if len(notifications) < s.batchSize || s.stopping() {
+			return
+		}
+	}
+}
+
+// processRecurringSchedules envia as ocorrências vencidas das séries recorrentes
+func (s *NotificationScheduler) processRecurringSchedules() {
+	schedules, err := s.recurringService.FindDue(time.Now())
 	if err != nil {
-		log.Printf("❌ Error fetching scheduled notifications: %v", err)
+		log.Printf("❌ Error fetching recurring schedules: %v", err)
 		return
 	}
 
-	if len(notifications) == 0 {
+	if len(schedules) == 0 {
 		return
 	}
 
-	log.Printf("📅 Found %d scheduled notification(s) ready to send", len(notifications))
+	log.Printf("🔁 Found %d recurring schedule(s) with a due occurrence", len(schedules))
 
-	for _, notification := range notifications {
-		go s.sendScheduledNotification(&notification)
+	for i := range schedules {
+		s.pending.Add(1)
+		go func(schedule *entity.RecurringSchedule) {
+			defer s.pending.Done()
+			if err := s.recurringService.RunOccurrence(s.ctx, schedule, time.Now()); err != nil {
+				log.Printf("❌ Failed to run recurring schedule %s: %v", schedule.ID, err)
+			}
+		}(&schedules[i])
 	}
 }
 
@@ -84,20 +176,32 @@ func (s *NotificationScheduler) processScheduledNotifications() {
 func (s *NotificationScheduler) sendScheduledNotification(notification *entity.Notification) {
 	log.Printf("📤 Sending scheduled notification: %s (ID: %s)", notification.Title, notification.ID)
 
-	// Atualizar status para pending (vai ser processado pelo worker)
-	notification.Status = entity.StatusPending
-	notification.IsScheduled = false
-	if err := s.notificationRepo.Update(notification); err != nil {
-		log.Printf("❌ Failed to update scheduled notification %s: %v", notification.ID, err)
+	// Status pending e mensagem do outbox na mesma transação (o relay publica na fila)
+	if err := s.notificationService.EnqueueScheduled(s.ctx, notification); err != nil {
+		// A reserva é mantida e a notificação volta a ser processada quando o lease vencer
+		log.Printf("❌ Failed to enqueue scheduled notification %s: %v", notification.ID, err)
 		return
 	}
 
-	// Enviar notificação (será processada pela fila)
-	if err := s.notificationService.SendNotification(notification); err != nil {
-		log.Printf("❌ Failed to send scheduled notification %s: %v", notification.ID, err)
-		s.notificationRepo.UpdateStatus(notification.ID, entity.StatusFailed)
-		return
+	log.Printf("✅ Scheduled notification sent: %s", notification.ID)
+}
+
+// stopping indica se Stop foi chamado
+func (s *NotificationScheduler) stopping() bool {
+	select {
+	case <-s.stopChan:
+		return true
+	default:
+		return false
 	}
+}
 
-	log.Printf("✅ Scheduled notification sent: %s", notification.ID)
+// instanceID identifica a réplica nas reservas: o hostname (nome do pod) e um sufixo
+// aleatório, para distinguir reinícios do mesmo pod
+func instanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "scheduler"
+	}
+	return host + "-" + uuid.NewString()[:8]
 }
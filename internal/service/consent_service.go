@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ConsentService interface {
+	RecordConsent(consent *entity.Consent) error
+	GetHistory(recipientID uuid.UUID, limit, offset int) ([]entity.Consent, error)
+	GetCurrent(recipientID uuid.UUID) ([]entity.Consent, error)
+	CanDeliver(recipientID uuid.UUID, channel entity.ConsentChannel, purpose string) (bool, error)
+	// RequiresOptIn indica se a finalidade exige consentimento explícito
+	RequiresOptIn(purpose string) bool
+}
+
+type consentService struct {
+	repo             repository.ConsentRepository
+	requiredPurposes map[string]bool
+}
+
+func NewConsentService(repo repository.ConsentRepository, requiredPurposes []string) ConsentService {
+	required := make(map[string]bool, len(requiredPurposes))
+	for _, purpose := range requiredPurposes {
+		required[purpose] = true
+	}
+	return &consentService{repo: repo, requiredPurposes: required}
+}
+
+func (s *consentService) RecordConsent(consent *entity.Consent) error {
+	if consent.RecipientID == uuid.Nil {
+		return errors.New("recipient is required")
+	}
+	if !entity.IsValidConsentChannel(consent.Channel) {
+		return errors.New("invalid consent channel")
+	}
+	if consent.Purpose == "" {
+		return errors.New("purpose is required")
+	}
+	if consent.Action != entity.ConsentGranted && consent.Action != entity.ConsentRevoked {
+		return errors.New("action must be granted or revoked")
+	}
+	return s.repo.Create(consent)
+}
+
+func (s *consentService) GetHistory(recipientID uuid.UUID, limit, offset int) ([]entity.Consent, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	return s.repo.FindByRecipient(recipientID, limit, offset)
+}
+
+func (s *consentService) GetCurrent(recipientID uuid.UUID) ([]entity.Consent, error) {
+	return s.repo.FindCurrent(recipientID)
+}
+
+func (s *consentService) RequiresOptIn(purpose string) bool {
+	if purpose == "" {
+		purpose = entity.PurposeTransactional
+	}
+	return s.requiredPurposes[purpose]
+}
+
+// CanDeliver indica se o canal pode ser usado para a finalidade. Vale sempre o registro
+// mais recente; sem registro, apenas finalidades que não exigem opt-in são permitidas.
+func (s *consentService) CanDeliver(recipientID uuid.UUID, channel entity.ConsentChannel, purpose string) (bool, error) {
+	if purpose == "" {
+		purpose = entity.PurposeTransactional
+	}
+
+	latest, err := s.repo.FindLatest(recipientID, channel, purpose)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return !s.requiredPurposes[purpose], nil
+		}
+		return false, err
+	}
+
+	return latest.Action == entity.ConsentGranted, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"log"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/repository"
+	"github.com/prefeitura-rio/app-notification-core/pkg/queue"
+)
+
+// ErrNoDeadLetterSelection evita que uma requisição sem IDs afete a DLQ inteira por engano
+var ErrNoDeadLetterSelection = errors.New("message_ids is required unless all is true")
+
+// DeadLetterQueue é implementada pelo cliente da fila
+type DeadLetterQueue interface {
+	PeekDeadLetters(offset, limit int) ([]queue.DeadLetter, error)
+	ReplayDeadLetters(messageIDs []string) ([]string, error)
+	DiscardDeadLetters(messageIDs []string) ([]string, error)
+}
+
+type DeadLetterService interface {
+	List(offset, limit int) ([]queue.DeadLetter, error)
+	Replay(messageIDs []string, all bool, requestedBy, reason string) (*entity.DeadLetterAudit, error)
+	Discard(messageIDs []string, all bool, requestedBy, reason string) (*entity.DeadLetterAudit, error)
+	ListAudit(limit, offset int) ([]entity.DeadLetterAudit, error)
+}
+
+type deadLetterService struct {
+	queue            DeadLetterQueue
+	auditRepo        repository.DeadLetterAuditRepository
+	notificationRepo repository.NotificationRepository
+}
+
+func NewDeadLetterService(
+	queue DeadLetterQueue,
+	auditRepo repository.DeadLetterAuditRepository,
+	notificationRepo repository.NotificationRepository,
+) DeadLetterService {
+	return &deadLetterService{queue: queue, auditRepo: auditRepo, notificationRepo: notificationRepo}
+}
+
+func (s *deadLetterService) List(offset, limit int) ([]queue.DeadLetter, error) {
+	letters, err := s.queue.PeekDeadLetters(offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// As mensagens trazem apenas o ID da notificação: completar o resumo com o banco
+	for i := range letters {
+		summary := letters[i].Notification
+		if summary == nil || summary.Title != "" {
+			continue
+		}
+		notification, err := s.notificationRepo.FindByID(summary.ID)
+		if err != nil {
+			continue
+		}
+		summary.Title = notification.Title
+		summary.Purpose = notification.Purpose
+		summary.RecipientID = notification.RecipientID
+		summary.Broadcast = notification.Broadcast
+		summary.CreatedAt = notification.CreatedAt
+	}
+	return letters, nil
+}
+
+func (s *deadLetterService) Replay(messageIDs []string, all bool, requestedBy, reason string) (*entity.DeadLetterAudit, error) {
+	return s.apply(entity.DeadLetterReplay, s.queue.ReplayDeadLetters, messageIDs, all, requestedBy, reason)
+}
+
+func (s *deadLetterService) Discard(messageIDs []string, all bool, requestedBy, reason string) (*entity.DeadLetterAudit, error) {
+	return s.apply(entity.DeadLetterDiscard, s.queue.DiscardDeadLetters, messageIDs, all, requestedBy, reason)
+}
+
+func (s *deadLetterService) ListAudit(limit, offset int) ([]entity.DeadLetterAudit, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	return s.auditRepo.FindAll(limit, offset)
+}
+
+// apply executa a ação e grava a auditoria mesmo quando ela falha no meio, já que parte
+// das mensagens pode ter sido processada
+func (s *deadLetterService) apply(
+	action entity.DeadLetterAction,
+	run func([]string) ([]string, error),
+	messageIDs []string,
+	all bool,
+	requestedBy, reason string,
+) (*entity.DeadLetterAudit, error) {
+	if all {
+		messageIDs = nil
+	} else if len(messageIDs) == 0 {
+		return nil, ErrNoDeadLetterSelection
+	}
+
+	processed, runErr := run(messageIDs)
+
+	audit := &entity.DeadLetterAudit{
+		Action:      action,
+		All:         all,
+		Requested:   messageIDs,
+		Processed:   processed,
+		RequestedBy: requestedBy,
+		Reason:      reason,
+	}
+	if audit.Processed == nil {
+		audit.Processed = []string{}
+	}
+	if runErr != nil {
+		audit.Error = runErr.Error()
+	}
+
+	if err := s.auditRepo.Create(audit); err != nil {
+		log.Printf("DLQ: Failed to record %s audit (%d message(s) processed): %v", action, len(processed), err)
+		if runErr == nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("DLQ: %s of %d message(s) by %q", action, len(processed), requestedBy)
+	return audit, runErr
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"log"
+	"strings"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/repository"
+)
+
+type EmailEventType string
+
+const (
+	EmailEventDelivery  EmailEventType = "delivery"
+	EmailEventBounce    EmailEventType = "bounce"
+	EmailEventComplaint EmailEventType = "complaint"
+)
+
+// EmailEvent é um evento do provedor de email já convertido do formato de origem (relay ou SES)
+type EmailEvent struct {
+	Type      EmailEventType
+	MessageID string
+	Address   string
+	// Permanent indica hard bounce; bounces temporários apenas atualizam o status da entrega
+	Permanent bool
+	Detail    string
+	Source    string
+}
+
+type EmailEventResult struct {
+	Processed  int `json:"processed"`
+	Unmatched  int `json:"unmatched"`
+	Suppressed int `json:"suppressed"`
+}
+
+type EmailEventService interface {
+	ProcessEvents(events []EmailEvent) (*EmailEventResult, error)
+}
+
+type emailEventService struct {
+	deliveryRepo repository.DeliveryRepository
+	suppressions SuppressionService
+}
+
+func NewEmailEventService(deliveryRepo repository.DeliveryRepository, suppressions SuppressionService) EmailEventService {
+	return &emailEventService{deliveryRepo: deliveryRepo, suppressions: suppressions}
+}
+
+// ProcessEvents atualiza as entregas de email relacionadas a cada evento e suprime
+// os endereços com hard bounce ou reclamação de spam
+func (s *emailEventService) ProcessEvents(events []EmailEvent) (*EmailEventResult, error) {
+	result := &EmailEventResult{}
+	for _, event := range events {
+		matched, err := s.updateDeliveries(event)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			result.Processed++
+		} else {
+			log.Printf("EmailEvents: No delivery found for %s event (message %q, address %q)", event.Type, event.MessageID, event.Address)
+			result.Unmatched++
+		}
+
+		suppressed, err := s.suppress(event)
+		if err != nil {
+			return nil, err
+		}
+		if suppressed {
+			result.Suppressed++
+		}
+	}
+	return result, nil
+}
+
+func (s *emailEventService) updateDeliveries(event EmailEvent) (bool, error) {
+	if event.MessageID == "" {
+		return false, nil
+	}
+
+	deliveries, err := s.deliveryRepo.FindByProviderMessageID(entity.DeliveryChannelEmail, event.MessageID)
+	if err != nil {
+		return false, err
+	}
+
+	status := deliveryStatusFor(event.Type)
+	matched := false
+	for _, delivery := range deliveries {
+		if event.Address != "" && !strings.EqualFold(delivery.Target, event.Address) {
+			continue
+		}
+		matched = true
+
+		// Uma confirmação de entrega tardia não apaga um bounce ou reclamação já recebidos
+		if status == entity.DeliveryDelivered &&
+			(delivery.Status == entity.DeliveryBounced || delivery.Status == entity.DeliveryComplained) {
+			continue
+		}
+		if err := s.deliveryRepo.UpdateStatus(delivery.ID, status, event.Detail); err != nil {
+			return false, err
+		}
+		log.Printf("EmailEvents: Delivery %s of notification %s marked as %s", delivery.ID, delivery.NotificationID, status)
+	}
+	return matched, nil
+}
+
+func (s *emailEventService) suppress(event EmailEvent) (bool, error) {
+	if event.Address == "" {
+		return false, nil
+	}
+
+	var reason entity.SuppressionReason
+	switch {
+	case event.Type == EmailEventBounce && event.Permanent:
+		reason = entity.SuppressionHardBounce
+	case event.Type == EmailEventComplaint:
+		reason = entity.SuppressionComplaint
+	default:
+		return false, nil
+	}
+
+	suppression := &entity.Suppression{
+		Channel: entity.SuppressionChannelEmail,
+		Address: event.Address,
+		Reason:  reason,
+		Source:  event.Source,
+		Note:    event.Detail,
+	}
+	if err := s.suppressions.Suppress(suppression); err != nil {
+		if IsInvalidSuppression(err) {
+			log.Printf("EmailEvents: Ignoring invalid address %q: %v", event.Address, err)
+			return false, nil
+		}
+		return false, err
+	}
+	log.Printf("EmailEvents: %s suppressed (%s)", suppression.Address, reason)
+	return true, nil
+}
+
+func deliveryStatusFor(eventType EmailEventType) entity.DeliveryStatus {
+	switch eventType {
+	case EmailEventBounce:
+		return entity.DeliveryBounced
+	case EmailEventComplaint:
+		return entity.DeliveryComplained
+	}
+	return entity.DeliveryDelivered
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/prefeitura-rio/app-notification-core/internal/entity"
 	"github.com/prefeitura-rio/app-notification-core/internal/repository"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
 	"github.com/google/uuid"
 )
 
@@ -58,6 +59,9 @@ func (s *groupService) DeleteGroup(id uuid.UUID) error {
 }
 
 func (s *groupService) AddMemberToGroup(member *entity.Member) error {
+	if err := normalizeMember(member); err != nil {
+		return err
+	}
 	if member.CPF == "" && member.Phone == "" {
 		return errors.New("either CPF or phone is required")
 	}
@@ -77,8 +81,21 @@ func (s *groupService) GetMember(id uuid.UUID) (*entity.Member, error) {
 }
 
 func (s *groupService) UpdateMember(member *entity.Member) error {
+	if err := normalizeMember(member); err != nil {
+		return err
+	}
 	if member.CPF == "" && member.Phone == "" && member.Email == "" {
 		return errors.New("at least one of CPF, phone, or email is required")
 	}
 	return s.repo.UpdateMember(member)
 }
+
+// normalizeMember valida e normaliza os identificadores do membro
+func normalizeMember(member *entity.Member) error {
+	cpf, phone, email, err := validation.NormalizeIdentity(member.CPF, member.Phone, member.Email)
+	if err != nil {
+		return err
+	}
+	member.CPF, member.Phone, member.Email = cpf, phone, email
+	return nil
+}
@@ -1,14 +1,19 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/prefeitura-rio/app-notification-core/internal/entity"
 	"github.com/prefeitura-rio/app-notification-core/internal/repository"
 	"github.com/prefeitura-rio/app-notification-core/internal/websocket"
 	"github.com/prefeitura-rio/app-notification-core/pkg/utils"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type NotificationService interface {
@@ -18,56 +23,181 @@ type NotificationService interface {
 	GetNotificationsByCPF(cpf string, limit, offset int) ([]entity.Notification, error)
 	GetNotificationsByPhone(phone string, limit, offset int) ([]entity.Notification, error)
 	GetNotificationsByEmail(email string, limit, offset int) ([]entity.Notification, error)
+	GetNotificationsByRecipient(recipient *entity.Recipient, limit, offset int) ([]entity.Notification, error)
 	UpdateNotification(notification *entity.Notification) error
 	DeleteNotification(id uuid.UUID) error
 	MarkAsRead(id uuid.UUID) error
+	GetDeliveries(id uuid.UUID) ([]entity.Delivery, error)
 	SendNotification(notification *entity.Notification) error
-	ProcessNotification(notification *entity.Notification) error
+	EnqueueScheduled(ctx context.Context, notification *entity.Notification) error
+	UpdateScheduled(id uuid.UUID, version int, changes ScheduledChanges) (*entity.Notification, error)
+	FanOut(id uuid.UUID) error
+	DeliverLeg(id uuid.UUID, channel entity.DeliveryChannel, finalAttempt bool) error
 	SendToUser(cpf, phone, email string, notification *entity.Notification) error
-	SendToGroup(groupID uuid.UUID, notification *entity.Notification) error
+	SendToGroup(ctx context.Context, groupID uuid.UUID, notification *entity.Notification) error
 	SendBroadcast(notification *entity.Notification) error
+	AwaitCapacity(ctx context.Context) error
 }
 
 type notificationService struct {
 	notificationRepo   repository.NotificationRepository
 	groupRepo          repository.GroupRepository
 	subscriptionRepo   repository.SubscriptionRepository
+	recipients         RecipientService
+	consents           ConsentService
+	suppressions       SuppressionService
+	deliveryRepo       repository.DeliveryRepository
+	ledger             repository.ProcessingLedgerRepository
 	hub                *websocket.Hub
 	mailman            *utils.MailmanClient
 	webPush            *utils.WebPushClient
-	queue              QueuePublisher
+	outboxRepo         repository.OutboxRepository
+	outbox             OutboxSignal
+	legs               LegPublisher
 }
 
-// QueuePublisher interface para publicar mensagens na fila
-type QueuePublisher interface {
-	PublishNotification(notification *entity.Notification) error
+// OutboxSignal acorda o relay do outbox após novas mensagens serem gravadas e informa se a
+// fila está saturada, com o tempo sugerido para nova tentativa
+type OutboxSignal interface {
+	Wake()
+	Saturated() (time.Duration, bool)
+}
+
+// LegPublisher publica as etapas de entrega nas filas dos canais (implementado por queue.Queue)
+type LegPublisher interface {
+	PublishLeg(notification *entity.Notification, channel entity.DeliveryChannel) error
 }
 
 func NewNotificationService(
 	notificationRepo repository.NotificationRepository,
 	groupRepo repository.GroupRepository,
 	subscriptionRepo repository.SubscriptionRepository,
+	recipients RecipientService,
+	consents ConsentService,
+	suppressions SuppressionService,
+	deliveryRepo repository.DeliveryRepository,
+	ledger repository.ProcessingLedgerRepository,
 	hub *websocket.Hub,
 	mailman *utils.MailmanClient,
 	webPush *utils.WebPushClient,
-	queue QueuePublisher,
+	outboxRepo repository.OutboxRepository,
+	outbox OutboxSignal,
+	legs LegPublisher,
 ) NotificationService {
 	return &notificationService{
 		notificationRepo:   notificationRepo,
 		groupRepo:          groupRepo,
 		subscriptionRepo:   subscriptionRepo,
+		recipients:         recipients,
+		consents:           consents,
+		suppressions:       suppressions,
+		deliveryRepo:       deliveryRepo,
+		ledger:             ledger,
 		hub:                hub,
 		mailman:            mailman,
 		webPush:            webPush,
-		queue:              queue,
+		outboxRepo:         outboxRepo,
+		outbox:             outbox,
+		legs:               legs,
 	}
 }
 
+// ErrInvalidPriority é retornado quando a prioridade não é high, normal ou low
+var ErrInvalidPriority = errors.New("priority must be high, normal or low")
+
+// ErrInvalidExpiry é retornado quando expires_at já passou ou é anterior ao agendamento
+var ErrInvalidExpiry = errors.New("expires_at must be in the future and after scheduled_for")
+
+// ErrInvalidLocalTime é retornado quando local_time é inválido, já passou no fuso do
+// destinatário ou foi usado sem destinatários individuais (broadcast)
+var ErrInvalidLocalTime = errors.New("invalid local_time")
+
+// ErrNotScheduled é retornado ao editar uma notificação que não está mais agendada (já
+// reservada pelo scheduler, enviada ou cancelada)
+var ErrNotScheduled = errors.New("notification is no longer scheduled")
+
+// ErrVersionConflict é retornado quando a notificação agendada foi alterada desde a versão
+// informada; o cliente deve recarregá-la e repetir a edição
+var ErrVersionConflict = errors.New("scheduled notification was modified, reload it and retry")
+
+// ErrBroadcastRequiresConsent é retornado ao enviar um broadcast com uma finalidade que exige
+// opt-in: o broadcast não tem destinatários individuais cujo consentimento possa ser verificado
+var ErrBroadcastRequiresConsent = errors.New("purpose requires explicit consent and cannot be broadcast")
+
+// ErrClaimLost é retornado quando a reserva de um envio agendado a grupo venceu durante a
+// expansão e foi retomada por outra instância, que continua o envio
+var ErrClaimLost = errors.New("scheduled notification claim was taken over by another instance")
+
+// ErrInvalidScheduledChanges é retornado quando a edição de uma notificação agendada é inválida
+var ErrInvalidScheduledChanges = errors.New("invalid scheduled notification changes")
+
+// ScheduledChanges são os campos editáveis de uma notificação agendada; campos nil mantêm o
+// valor atual. ScheduledFor e LocalTime são exclusivos: um substitui o outro.
+type ScheduledChanges struct {
+	ScheduledFor *time.Time
+	LocalTime    *string
+	Title        *string
+	Message      *string
+	Type         *entity.NotificationType
+	Priority     *entity.NotificationPriority
+	Data         map[string]any
+	IsHTML       *bool
+	ExpiresAt    *time.Time
+}
+
+// ErrQueueSaturated indica que a fila de notificações está saturada e o envio imediato foi
+// recusado; QueueSaturatedError traz o tempo sugerido para nova tentativa
+var ErrQueueSaturated = errors.New("notification queue is saturated, retry later")
+
+// QueueSaturatedError é retornado por SendNotification enquanto a fila está saturada
+type QueueSaturatedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *QueueSaturatedError) Error() string {
+	return ErrQueueSaturated.Error()
+}
+
+func (e *QueueSaturatedError) Unwrap() error {
+	return ErrQueueSaturated
+}
+
+// capacityWait é quanto AwaitCapacity espera a fila deixar de estar saturada
+const capacityWait = 2 * time.Minute
+
+// ErrLegInProgress indica que outro worker está enviando a mesma etapa; a mensagem volta
+// para a fila e, na próxima tentativa, encontra a etapa concluída
+var ErrLegInProgress = errors.New("delivery leg is being processed by another worker")
+
+// processingLease é o prazo de uma reserva no ledger de processamento. Vencido o prazo sem
+// conclusão (worker derrubado), outra entrega da mensagem pode retomar a etapa.
+const processingLease = 5 * time.Minute
+
 func (s *notificationService) CreateNotification(notification *entity.Notification) error {
+	if err := validateNotification(notification); err != nil {
+		return err
+	}
+	return s.notificationRepo.Create(notification)
+}
+
+// validateNotification verifica os campos obrigatórios e aplica a prioridade padrão
+func validateNotification(notification *entity.Notification) error {
 	if notification.Title == "" || notification.Message == "" {
 		return errors.New("title and message are required")
 	}
-	return s.notificationRepo.Create(notification)
+	if notification.Priority == "" {
+		notification.Priority = entity.PriorityNormal
+	}
+	if !entity.IsValidPriority(notification.Priority) {
+		return ErrInvalidPriority
+	}
+	if notification.ExpiresAt != nil {
+		if notification.IsExpired(time.Now()) ||
+			(notification.ScheduledFor != nil && !notification.ExpiresAt.After(*notification.ScheduledFor)) {
+			return ErrInvalidExpiry
+		}
+	}
+	return nil
 }
 
 func (s *notificationService) GetNotification(id uuid.UUID) (*entity.Notification, error) {
@@ -85,6 +215,13 @@ func (s *notificationService) GetNotificationsByCPF(cpf string, limit, offset in
 	if limit <= 0 {
 		limit = 20
 	}
+	cpf, err := validation.NormalizeCPF(cpf)
+	if err != nil {
+		return nil, err
+	}
+	if recipient, err := s.recipients.FindRecipient(cpf, "", ""); err == nil {
+		return s.notificationRepo.FindByRecipient(recipient, limit, offset)
+	}
 	return s.notificationRepo.FindByCPF(cpf, limit, offset)
 }
 
@@ -92,6 +229,13 @@ func (s *notificationService) GetNotificationsByPhone(phone string, limit, offse
 	if limit <= 0 {
 		limit = 20
 	}
+	phone, err := validation.NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+	if recipient, err := s.recipients.FindRecipient("", phone, ""); err == nil {
+		return s.notificationRepo.FindByRecipient(recipient, limit, offset)
+	}
 	return s.notificationRepo.FindByPhone(phone, limit, offset)
 }
 
@@ -99,9 +243,23 @@ func (s *notificationService) GetNotificationsByEmail(email string, limit, offse
 	if limit <= 0 {
 		limit = 20
 	}
+	email, err := validation.NormalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if recipient, err := s.recipients.FindRecipient("", "", email); err == nil {
+		return s.notificationRepo.FindByRecipient(recipient, limit, offset)
+	}
 	return s.notificationRepo.FindByEmail(email, limit, offset)
 }
 
+func (s *notificationService) GetNotificationsByRecipient(recipient *entity.Recipient, limit, offset int) ([]entity.Notification, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	return s.notificationRepo.FindByRecipient(recipient, limit, offset)
+}
+
 func (s *notificationService) UpdateNotification(notification *entity.Notification) error {
 	if notification.Title == "" || notification.Message == "" {
 		return errors.New("title and message are required")
@@ -117,9 +275,17 @@ func (s *notificationService) MarkAsRead(id uuid.UUID) error {
 	return s.notificationRepo.MarkAsRead(id)
 }
 
+func (s *notificationService) GetDeliveries(id uuid.UUID) ([]entity.Delivery, error) {
+	return s.deliveryRepo.FindByNotification(id)
+}
+
 func (s *notificationService) SendNotification(notification *entity.Notification) error {
 	log.Printf("SendNotification: Creating notification with type=%s", notification.Type)
 
+	if notification.Broadcast && s.consents.RequiresOptIn(notification.Purpose) {
+		return fmt.Errorf("%w: %q", ErrBroadcastRequiresConsent, notification.Purpose)
+	}
+
 	// Verificar se é uma notificação agendada
 	if notification.IsScheduled && notification.ScheduledFor != nil {
 		notification.Status = entity.StatusScheduled
@@ -132,26 +298,154 @@ func (s *notificationService) SendNotification(notification *entity.Notification
 		return nil
 	}
 
-	// Notificação imediata
-	if err := s.CreateNotification(notification); err != nil {
+	// Notificação imediata: a notificação e a mensagem do outbox são gravadas na mesma
+	// transação e o relay do outbox publica na fila. Assim a notificação nunca fica pending
+	// sem ter sido (ou vir a ser) publicada.
+	if err := validateNotification(notification); err != nil {
+		return err
+	}
+	if retryAfter, saturated := s.outbox.Saturated(); saturated {
+		log.Printf("SendNotification: Queue saturated, rejecting notification")
+		return &QueueSaturatedError{RetryAfter: retryAfter}
+	}
+	if err := s.outboxRepo.CreateNotification(notification); err != nil {
 		log.Printf("SendNotification: Failed to create notification: %v", err)
 		return err
 	}
+	s.outbox.Wake()
+
+	log.Printf("SendNotification: Notification %s queued in outbox", notification.ID)
+	return nil
+}
 
-	// Publicar na fila RabbitMQ para processamento assíncrono
-	if err := s.queue.PublishNotification(notification); err != nil {
-		log.Printf("SendNotification: Failed to publish to queue: %v", err)
-		s.notificationRepo.UpdateStatus(notification.ID, entity.StatusFailed)
+// EnqueueScheduled libera uma notificação agendada reservada pelo scheduler: o status passa a
+// pending e a mensagem do outbox é gravada na mesma transação
+func (s *notificationService) EnqueueScheduled(ctx context.Context, notification *entity.Notification) error {
+	if notification.ClaimedBy == nil {
+		return fmt.Errorf("scheduled notification %s was not claimed", notification.ID)
+	}
+	if notification.GroupJob && notification.GroupID != nil {
+		return s.expandGroupJob(ctx, notification)
+	}
+	released, err := s.outboxRepo.ReleaseScheduled(notification.ID, *notification.ClaimedBy)
+	if err != nil {
 		return err
 	}
-
-	log.Printf("SendNotification: Notification %s published to queue", notification.ID)
+	if !released {
+		log.Printf("EnqueueScheduled: Notification %s is no longer claimed by this instance, skipping", notification.ID)
+		return nil
+	}
+	notification.Status = entity.StatusPending
+	notification.IsScheduled = false
+	notification.ClaimedBy = nil
+	notification.ClaimExpiresAt = nil
+	s.outbox.Wake()
 	return nil
 }
 
-// ProcessNotification processa a notificação (chamado pelos workers)
-func (s *notificationService) ProcessNotification(notification *entity.Notification) error {
-	log.Printf("ProcessNotification: Processing notification %s with type=%s", notification.ID, notification.Type)
+// UpdateScheduled edita horário, conteúdo e canais de uma notificação ainda agendada. A
+// gravação é condicional à versão informada e ao status scheduled, então uma edição não
+// concorre com outra nem com o scheduler reservando a notificação.
+func (s *notificationService) UpdateScheduled(id uuid.UUID, version int, changes ScheduledChanges) (*entity.Notification, error) {
+	notification, err := s.notificationRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if notification.Status != entity.StatusScheduled {
+		return nil, ErrNotScheduled
+	}
+	if notification.Version != version {
+		return nil, ErrVersionConflict
+	}
+
+	if changes.Title != nil {
+		notification.Title = *changes.Title
+	}
+	if changes.Message != nil {
+		notification.Message = *changes.Message
+	}
+	if changes.Type != nil {
+		switch *changes.Type {
+		case entity.TypeInApp, entity.TypePush, entity.TypeEmail, entity.TypeBoth, entity.TypeAll:
+		default:
+			return nil, fmt.Errorf("%w: unknown notification type %q", ErrInvalidScheduledChanges, *changes.Type)
+		}
+		notification.Type = *changes.Type
+	}
+	if changes.Priority != nil {
+		notification.Priority = *changes.Priority
+	}
+	if changes.Data != nil {
+		notification.Data = changes.Data
+	}
+	if changes.IsHTML != nil {
+		notification.IsHTML = *changes.IsHTML
+	}
+	if changes.ExpiresAt != nil {
+		notification.ExpiresAt = changes.ExpiresAt
+	}
+
+	switch {
+	case changes.ScheduledFor != nil && changes.LocalTime != nil:
+		return nil, fmt.Errorf("%w: use either scheduled_for or local_time", ErrInvalidScheduledChanges)
+	case changes.ScheduledFor != nil:
+		if !changes.ScheduledFor.After(time.Now()) {
+			return nil, fmt.Errorf("%w: scheduled_for must be in the future", ErrInvalidScheduledChanges)
+		}
+		notification.ScheduledFor = changes.ScheduledFor
+		notification.LocalTime = nil
+		notification.Timezone = nil
+	case changes.LocalTime != nil:
+		if notification.RecipientID == nil {
+			return nil, fmt.Errorf("%w: notifications without a recipient have no timezone, use scheduled_for", ErrInvalidLocalTime)
+		}
+		recipient, err := s.recipients.GetRecipient(*notification.RecipientID)
+		if err != nil {
+			return nil, err
+		}
+		notification.LocalTime = changes.LocalTime
+		if err := scheduleLocalTime(notification, recipient); err != nil {
+			return nil, err
+		}
+	}
+
+	if notification.Title == "" || notification.Message == "" {
+		return nil, fmt.Errorf("%w: title and message are required", ErrInvalidScheduledChanges)
+	}
+	if err := validateNotification(notification); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.notificationRepo.UpdateScheduled(notification, version)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		// Alterada ou reservada entre a leitura e a gravação
+		current, err := s.notificationRepo.FindByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if current.Status != entity.StatusScheduled {
+			return nil, ErrNotScheduled
+		}
+		return nil, ErrVersionConflict
+	}
+
+	log.Printf("UpdateScheduled: Notification %s updated to version %d, scheduled for %s",
+		notification.ID, notification.Version, notification.ScheduledFor.Format(time.RFC3339))
+	return notification, nil
+}
+
+// FanOut é o estágio das filas de prioridade: carrega a versão atual da notificação, aplica
+// consentimento e supressão e publica uma etapa na fila de cada canal a entregar. Notificações
+// removidas, canceladas ou expiradas desde a publicação são ignoradas.
+func (s *notificationService) FanOut(id uuid.UUID) error {
+	notification, err := s.loadQueued("FanOut", id)
+	if err != nil || notification == nil {
+		return err
+	}
+	log.Printf("FanOut: Processing notification %s with type=%s", notification.ID, notification.Type)
 
 	shouldSendInApp := notification.Type == entity.TypeInApp ||
 		notification.Type == entity.TypeBoth ||
@@ -164,83 +458,408 @@ func (s *notificationService) ProcessNotification(notification *entity.Notificat
 	shouldSendEmail := notification.Type == entity.TypeEmail ||
 		notification.Type == entity.TypeAll
 
-	log.Printf("ProcessNotification: shouldSendInApp=%v, shouldSendPush=%v, shouldSendEmail=%v", shouldSendInApp, shouldSendPush, shouldSendEmail)
+	log.Printf("FanOut: shouldSendInApp=%v, shouldSendPush=%v, shouldSendEmail=%v", shouldSendInApp, shouldSendPush, shouldSendEmail)
 
-	if shouldSendInApp {
-		log.Printf("ProcessNotification: Broadcasting notification via WebSocket")
-		s.hub.BroadcastNotification(notification)
+	// Canais sem consentimento para a finalidade da notificação não são usados
+	requested := shouldSendInApp || shouldSendPush || shouldSendEmail
+	if shouldSendInApp, err = s.hasConsent(notification, entity.ConsentChannelInApp, shouldSendInApp); err != nil {
+		return err
+	}
+	if shouldSendPush, err = s.hasConsent(notification, entity.ConsentChannelPush, shouldSendPush); err != nil {
+		return err
+	}
+	if shouldSendEmail, err = s.hasConsent(notification, entity.ConsentChannelEmail, shouldSendEmail); err != nil {
+		return err
 	}
 
-	if shouldSendPush {
-		log.Printf("ProcessNotification: Sending push notifications")
-		s.sendPushNotifications(notification)
+	if requested && !shouldSendInApp && !shouldSendPush && !shouldSendEmail {
+		log.Printf("FanOut: Notification %s blocked, no consent for purpose %q", notification.ID, notification.Purpose)
+		return s.notificationRepo.UpdateStatus(notification.ID, entity.StatusBlocked)
 	}
 
-	if shouldSendEmail && notification.UserEmail != nil && *notification.UserEmail != "" {
-		log.Printf("ProcessNotification: Sending email to %s", *notification.UserEmail)
-		mailReq := &utils.MailmanRequest{
-			ToAddresses: []string{*notification.UserEmail},
-			Subject:     notification.Title,
-			Body:        notification.Message,
-			IsHTMLBody:  notification.IsHTML,
+	// Endereços na lista de supressão nunca são contatados
+	emailAddress := s.emailAddress(notification)
+	emailSuppressed := false
+	if shouldSendEmail && emailAddress != "" {
+		if emailSuppressed, err = s.suppressions.IsSuppressed(entity.SuppressionChannelEmail, emailAddress); err != nil {
+			log.Printf("FanOut: Failed to check email suppression: %v", err)
+			return err
 		}
+	}
 
-		if err := s.mailman.SendEmail(mailReq); err != nil {
-			log.Printf("ProcessNotification: Failed to send email: %v", err)
-			s.notificationRepo.UpdateStatus(notification.ID, entity.StatusFailed)
+	// Push direcionado por telefone é o canal por telefone disponível hoje
+	phone := s.phoneNumber(notification)
+	phoneSuppressed := false
+	if shouldSendPush && phone != "" {
+		if phoneSuppressed, err = s.suppressions.IsSuppressed(entity.SuppressionChannelPhone, phone); err != nil {
+			log.Printf("FanOut: Failed to check phone suppression: %v", err)
 			return err
 		}
-		log.Printf("ProcessNotification: Email sent successfully")
 	}
 
+	if emailSuppressed {
+		log.Printf("FanOut: Email %s is suppressed, skipping", emailAddress)
+		s.recordDelivery(notification, entity.DeliveryChannelEmail, emailAddress, entity.DeliverySuppressed, nil)
+		shouldSendEmail = false
+	}
+	if phoneSuppressed {
+		log.Printf("FanOut: Phone %s is suppressed, skipping push", phone)
+		s.recordDelivery(notification, entity.DeliveryChannelPush, phone, entity.DeliverySuppressed, nil)
+		shouldSendPush = false
+	}
+
+	if (emailSuppressed || phoneSuppressed) && !shouldSendInApp && !shouldSendPush && !shouldSendEmail {
+		log.Printf("FanOut: Notification %s suppressed, every target is on the suppression list", notification.ID)
+		return s.notificationRepo.UpdateStatus(notification.ID, entity.StatusSuppressed)
+	}
+
+	var legs []entity.DeliveryChannel
+	if shouldSendInApp {
+		legs = append(legs, entity.DeliveryChannelInApp)
+	}
+	if shouldSendPush {
+		legs = append(legs, entity.DeliveryChannelPush)
+	}
+	if shouldSendEmail && emailAddress != "" {
+		legs = append(legs, entity.DeliveryChannelEmail)
+	}
+
+	if len(legs) == 0 {
+		log.Printf("FanOut: Notification %s has no channel to deliver", notification.ID)
+		return s.notificationRepo.UpdateStatus(notification.ID, entity.StatusSent)
+	}
+
+	// Todas as etapas são registradas antes da primeira publicação: a notificação só é
+	// marcada como enviada quando todas estiverem concluídas
+	if err := s.ledger.Enqueue(notification.ID, legs); err != nil {
+		log.Printf("FanOut: Failed to register legs of notification %s: %v", notification.ID, err)
+		return err
+	}
+	for _, leg := range legs {
+		// Em uma reentrega, etapas já publicadas são publicadas de novo; o ledger garante
+		// que cada uma seja enviada uma única vez
+		if err := s.legs.PublishLeg(notification, leg); err != nil {
+			log.Printf("FanOut: Failed to publish %s leg of notification %s: %v", leg, notification.ID, err)
+			return err
+		}
+	}
+
+	log.Printf("FanOut: Notification %s fanned out to %v", notification.ID, legs)
+	return nil
+}
+
+// DeliverLeg é o estágio das filas de canal: envia a etapa do canal e, quando todas as etapas
+// da notificação estiverem concluídas, marca a notificação como enviada. Uma falha volta para
+// o retry da fila; só a falha da última tentativa (finalAttempt) marca a notificação como
+// failed, então uma notificação entregue num retry não fica com status de falha.
+func (s *notificationService) DeliverLeg(id uuid.UUID, channel entity.DeliveryChannel, finalAttempt bool) error {
+	notification, err := s.loadQueued("DeliverLeg", id)
+	if err != nil || notification == nil {
+		return err
+	}
+
+	var send func() error
+	switch channel {
+	case entity.DeliveryChannelInApp:
+		send = func() error {
+			log.Printf("DeliverLeg: Broadcasting notification %s via WebSocket", notification.ID)
+			s.hub.BroadcastNotification(notification)
+			return nil
+		}
+	case entity.DeliveryChannelPush:
+		send = func() error {
+			log.Printf("DeliverLeg: Sending push notifications for %s", notification.ID)
+			return s.sendPushNotifications(notification)
+		}
+	case entity.DeliveryChannelEmail:
+		send = func() error {
+			return s.sendEmail(notification)
+		}
+	default:
+		return fmt.Errorf("unknown delivery channel %q", channel)
+	}
+
+	// Cada canal é uma etapa do ledger de processamento: reentregas da mensagem encontram
+	// a etapa concluída e não enviam de novo
+	if err := s.runLeg(notification, channel, send); err != nil {
+		if finalAttempt && !errors.Is(err, ErrLegInProgress) {
+			log.Printf("DeliverLeg: %s leg of notification %s failed on its last attempt", channel, notification.ID)
+			if statusErr := s.notificationRepo.UpdateStatus(notification.ID, entity.StatusFailed); statusErr != nil {
+				log.Printf("DeliverLeg: Failed to update status: %v", statusErr)
+			}
+		}
+		return err
+	}
+
+	incomplete, err := s.ledger.CountIncomplete(notification.ID)
+	if err != nil {
+		log.Printf("DeliverLeg: Failed to check pending legs of notification %s: %v", notification.ID, err)
+		return nil
+	}
+	if incomplete > 0 {
+		return nil
+	}
 	if err := s.notificationRepo.UpdateStatus(notification.ID, entity.StatusSent); err != nil {
-		log.Printf("ProcessNotification: Failed to update status: %v", err)
+		log.Printf("DeliverLeg: Failed to update status: %v", err)
+		return err
+	}
+
+	log.Printf("DeliverLeg: Notification %s delivered on every channel", notification.ID)
+	return nil
+}
+
+// loadQueued carrega a versão atual da notificação referenciada pela mensagem da fila.
+// Retorna nil, sem erro, para notificações removidas, canceladas ou expiradas; a mensagem é
+// confirmada sem envio.
+func (s *notificationService) loadQueued(stage string, id uuid.UUID) (*entity.Notification, error) {
+	notification, err := s.notificationRepo.FindByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("%s: Notification %s was deleted, skipping", stage, id)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case notification.Status == entity.StatusCancelled:
+		log.Printf("%s: Notification %s was cancelled, skipping", stage, id)
+		return nil, nil
+	case notification.IsExpired(time.Now()):
+		log.Printf("%s: Notification %s expired at %s, skipping", stage, id, notification.ExpiresAt.Format(time.RFC3339))
+		return nil, s.notificationRepo.UpdateStatus(id, entity.StatusExpired)
+	}
+	return notification, nil
+}
+
+// sendEmail envia a notificação ao email do destinatário pelo Mailman
+func (s *notificationService) sendEmail(notification *entity.Notification) error {
+	emailAddress := s.emailAddress(notification)
+	if emailAddress == "" {
+		// O email foi removido do destinatário depois do fan-out
+		log.Printf("DeliverLeg: Notification %s no longer has an email address, skipping", notification.ID)
+		return nil
+	}
+
+	log.Printf("DeliverLeg: Sending email to %s", emailAddress)
+	mailReq := &utils.MailmanRequest{
+		ToAddresses: []string{emailAddress},
+		Subject:     notification.Title,
+		Body:        notification.Message,
+		IsHTMLBody:  notification.IsHTML,
+	}
+
+	messageID, err := s.mailman.SendEmail(mailReq)
+	if err != nil {
+		log.Printf("DeliverLeg: Failed to send email: %v", err)
+		s.recordDelivery(notification, entity.DeliveryChannelEmail, emailAddress, entity.DeliveryFailed, err)
+		return err
+	}
+	delivery := newDelivery(notification, entity.DeliveryChannelEmail, emailAddress, entity.DeliverySent, nil)
+	delivery.ProviderMessageID = messageID
+	s.saveDelivery(delivery)
+	log.Printf("DeliverLeg: Email sent successfully")
+	return nil
+}
+
+// runLeg reserva a etapa no ledger e executa o envio. Etapas já concluídas são ignoradas;
+// se outro worker estiver com a etapa reservada, retorna ErrLegInProgress.
+func (s *notificationService) runLeg(notification *entity.Notification, leg entity.DeliveryChannel, send func() error) error {
+	owner := uuid.NewString()
+	claimed, state, err := s.ledger.Claim(notification.ID, leg, owner, processingLease)
+	if err != nil {
+		log.Printf("DeliverLeg: Failed to claim %s leg: %v", leg, err)
+		return err
+	}
+	if !claimed {
+		if state == entity.ProcessingCompleted {
+			log.Printf("DeliverLeg: %s leg of notification %s already completed, skipping", leg, notification.ID)
+			return nil
+		}
+		return fmt.Errorf("%w: %s leg of notification %s", ErrLegInProgress, leg, notification.ID)
+	}
+
+	if err := send(); err != nil {
+		if held, failErr := s.ledger.Fail(notification.ID, leg, owner, err); failErr != nil {
+			log.Printf("DeliverLeg: Failed to record %s leg failure: %v", leg, failErr)
+		} else if !held {
+			log.Printf("DeliverLeg: %s leg of notification %s was taken over by another worker, failure not recorded", leg, notification.ID)
+		}
 		return err
 	}
 
-	log.Printf("ProcessNotification: Notification %s processed successfully", notification.ID)
+	// O envio já foi feito: uma falha aqui não deve provocar um novo envio
+	if held, err := s.ledger.Complete(notification.ID, leg, owner); err != nil {
+		log.Printf("DeliverLeg: Failed to complete %s leg of notification %s: %v", leg, notification.ID, err)
+	} else if !held {
+		log.Printf("DeliverLeg: %s leg of notification %s was taken over by another worker after its lease expired", leg, notification.ID)
+	}
 	return nil
 }
 
-// sendPushNotifications envia push notifications para as subscriptions do usuário
-func (s *notificationService) sendPushNotifications(notification *entity.Notification) {
+// hasConsent verifica no ledger se o canal pode ser usado para a finalidade da notificação.
+// Broadcasts não possuem destinatário: só saem se a finalidade não exige opt-in (os que exigem
+// são recusados no envio; aqui cobre os já enfileirados antes de a finalidade passar a exigir).
+func (s *notificationService) hasConsent(notification *entity.Notification, channel entity.ConsentChannel, requested bool) (bool, error) {
+	if !requested {
+		return false, nil
+	}
+	if notification.RecipientID == nil {
+		if notification.Broadcast && s.consents.RequiresOptIn(notification.Purpose) {
+			log.Printf("FanOut: Skipping %s for broadcast %s, purpose %q requires explicit consent", channel, notification.ID, notification.Purpose)
+			return false, nil
+		}
+		return true, nil
+	}
+
+	allowed, err := s.consents.CanDeliver(*notification.RecipientID, channel, notification.Purpose)
+	if err != nil {
+		log.Printf("FanOut: Failed to check %s consent: %v", channel, err)
+		return false, err
+	}
+	if !allowed {
+		log.Printf("FanOut: Skipping %s for notification %s, no consent for purpose %q", channel, notification.ID, notification.Purpose)
+	}
+	return allowed, nil
+}
+
+// emailAddress retorna o email da notificação ou, se ausente, o email vinculado ao destinatário
+func (s *notificationService) emailAddress(notification *entity.Notification) string {
+	if notification.UserEmail != nil && *notification.UserEmail != "" {
+		return *notification.UserEmail
+	}
+	if notification.RecipientID == nil {
+		return ""
+	}
+	recipient, err := s.recipients.GetRecipient(*notification.RecipientID)
+	if err != nil || recipient.Email == nil {
+		return ""
+	}
+	return *recipient.Email
+}
+
+// phoneNumber retorna o telefone da notificação ou, se ausente, o telefone vinculado ao destinatário
+func (s *notificationService) phoneNumber(notification *entity.Notification) string {
+	if notification.UserPhone != nil && *notification.UserPhone != "" {
+		return *notification.UserPhone
+	}
+	if notification.RecipientID == nil {
+		return ""
+	}
+	recipient, err := s.recipients.GetRecipient(*notification.RecipientID)
+	if err != nil || recipient.Phone == nil {
+		return ""
+	}
+	return *recipient.Phone
+}
+
+// recordDelivery grava o resultado da entrega em um canal
+func (s *notificationService) recordDelivery(notification *entity.Notification, channel entity.DeliveryChannel, target string, status entity.DeliveryStatus, sendErr error) {
+	s.saveDelivery(newDelivery(notification, channel, target, status, sendErr))
+}
+
+// saveDelivery grava o resultado da entrega. Falhas ao gravar não interrompem o envio.
+func (s *notificationService) saveDelivery(delivery *entity.Delivery) {
+	if err := s.deliveryRepo.Create(delivery); err != nil {
+		log.Printf("Failed to record %s delivery for notification %s: %v", delivery.Channel, delivery.NotificationID, err)
+	}
+}
+
+func newDelivery(notification *entity.Notification, channel entity.DeliveryChannel, target string, status entity.DeliveryStatus, sendErr error) *entity.Delivery {
+	delivery := &entity.Delivery{
+		NotificationID: notification.ID,
+		Channel:        channel,
+		Target:         target,
+		Status:         status,
+	}
+	if sendErr != nil {
+		delivery.Error = sendErr.Error()
+	}
+	return delivery
+}
+
+// sendPushNotifications envia push notifications para as subscriptions do usuário. Retorna
+// erro quando as subscriptions não puderam ser carregadas ou quando nenhum push saiu e houve
+// falha temporária, para que a etapa volte ao retry; subscriptions recusadas pelo serviço de
+// push (utils.ErrPushRejected) não seriam aceitas numa nova tentativa.
+func (s *notificationService) sendPushNotifications(notification *entity.Notification) error {
 	var subscriptions []entity.Subscription
 	var err error
 
-	// Buscar subscriptions baseado no identificador disponível
-	if notification.UserCPF != nil && *notification.UserCPF != "" {
+	// Buscar subscriptions de todos os dispositivos do destinatário
+	if notification.RecipientID != nil {
+		recipient, err := s.recipients.GetRecipient(*notification.RecipientID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Destinatário removido (ex: eliminação de dados) depois do fan-out
+			log.Printf("Recipient %s no longer exists, skipping push", *notification.RecipientID)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find recipient %s: %w", *notification.RecipientID, err)
+		}
+		subscriptions, err = s.subscriptionRepo.FindByRecipient(recipient)
+		if err != nil {
+			return fmt.Errorf("failed to find subscriptions by recipient: %w", err)
+		}
+	} else if notification.UserCPF != nil && *notification.UserCPF != "" {
 		subscriptions, err = s.subscriptionRepo.FindByCPF(*notification.UserCPF)
 		if err != nil {
-			log.Printf("Failed to find subscriptions by CPF: %v", err)
-			return
+			return fmt.Errorf("failed to find subscriptions by CPF: %w", err)
 		}
 	} else if notification.UserPhone != nil && *notification.UserPhone != "" {
 		subscriptions, err = s.subscriptionRepo.FindByPhone(*notification.UserPhone)
 		if err != nil {
-			log.Printf("Failed to find subscriptions by phone: %v", err)
-			return
+			return fmt.Errorf("failed to find subscriptions by phone: %w", err)
 		}
 	}
 
 	if len(subscriptions) == 0 {
 		log.Printf("No subscriptions found for notification %s", notification.ID)
-		return
+		return nil
 	}
 
 	log.Printf("Found %d subscription(s), sending push notifications...", len(subscriptions))
 
 	// Enviar push notification para cada subscription
+	sent := 0
+	var temporaryErr error
 	for _, sub := range subscriptions {
 		if err := s.webPush.SendPush(&sub, notification); err != nil {
 			log.Printf("Failed to send push to subscription %s: %v", sub.ID, err)
+			s.recordDelivery(notification, entity.DeliveryChannelPush, sub.Endpoint, entity.DeliveryFailed, err)
+			if !errors.Is(err, utils.ErrPushRejected) && temporaryErr == nil {
+				temporaryErr = err
+			}
 			// Continuar enviando para outras subscriptions mesmo se uma falhar
 			continue
 		}
+		sent++
+		s.recordDelivery(notification, entity.DeliveryChannelPush, sub.Endpoint, entity.DeliverySent, nil)
 		log.Printf("Push sent successfully to subscription %s", sub.ID)
 	}
+
+	// Com algum push entregue, uma nova tentativa repetiria o envio aos demais dispositivos
+	if sent == 0 && temporaryErr != nil {
+		return fmt.Errorf("no push notification sent for %s: %w", notification.ID, temporaryErr)
+	}
+	return nil
 }
 
 func (s *notificationService) SendToUser(cpf, phone, email string, notification *entity.Notification) error {
+	cpf, phone, email, err := validation.NormalizeIdentity(cpf, phone, email)
+	if err != nil {
+		return err
+	}
+
+	recipient, err := s.recipients.Resolve(cpf, phone, email, "")
+	if err != nil {
+		return err
+	}
+	notification.RecipientID = &recipient.ID
+	if err := scheduleLocalTime(notification, recipient); err != nil {
+		return err
+	}
+
 	if cpf != "" {
 		notification.UserCPF = &cpf
 	}
@@ -254,17 +873,82 @@ func (s *notificationService) SendToUser(cpf, phone, email string, notification
 	return s.SendNotification(notification)
 }
 
-func (s *notificationService) SendToGroup(groupID uuid.UUID, notification *entity.Notification) error {
+// SendToGroup envia a notificação a cada membro do grupo. Envios agendados (scheduled_for) são
+// gravados como um único envio ao grupo, expandido pelo scheduler no horário: entram os membros
+// que aderiram ao grupo até lá e saem os que deixaram. Com local_time, os membros são
+// resolvidos no agendamento, pois cada um é agendado no horário do seu fuso.
+func (s *notificationService) SendToGroup(ctx context.Context, groupID uuid.UUID, notification *entity.Notification) error {
+	notification.GroupID = &groupID
+
+	if notification.IsScheduled && notification.ScheduledFor != nil && notification.LocalTime == nil {
+		if _, err := s.groupRepo.FindByID(groupID); err != nil {
+			return err
+		}
+		notification.RecipientID = nil
+		notification.GroupJob = true
+		return s.SendNotification(notification)
+	}
+
 	members, err := s.groupRepo.FindMembers(groupID)
 	if err != nil {
 		return err
 	}
+	_, err = s.sendToMembers(ctx, notification, members, nil)
+	return err
+}
 
-	notification.GroupID = &groupID
+// sendToMembers cria a notificação de cada membro a partir de notification. Falhas de um membro
+// não interrompem o envio aos demais, mas são reportadas. Envios imediatos aguardam a fila ter
+// espaço antes de cada membro, em vez de inundá-la; interrupted indica que a espera se esgotou
+// (ou ctx foi cancelado) e os membros restantes não foram enviados. Com ParentID, membros que já receberam a
+// notificação desse envio são ignorados; keepalive, se informado, é chamado antes de cada
+// membro e interrompe o envio se falhar.
+func (s *notificationService) sendToMembers(ctx context.Context, notification *entity.Notification, members []entity.Member, keepalive func() error) (interrupted bool, err error) {
+	var sent, failed int
+	var firstErr error
+	buckets := map[string]int{}
+	for i, member := range members {
+		if keepalive != nil {
+			if err := keepalive(); err != nil {
+				return true, err
+			}
+		}
+		if !notification.IsScheduled {
+			if err := s.AwaitCapacity(ctx); err != nil {
+				if sent == 0 {
+					return true, err
+				}
+				return true, fmt.Errorf("queue saturated after sending to %d of %d member(s), %d member(s) not sent: %v",
+					sent, len(members), len(members)-i, err)
+			}
+		}
 
-	for _, member := range members {
 		individualNotif := *notification
 		individualNotif.ID = uuid.Nil
+
+		recipient, err := s.recipients.Resolve(member.CPF, member.Phone, member.Email, member.Name)
+		if err != nil {
+			log.Printf("SendToGroup: Failed to resolve recipient for member %s: %v", member.ID, err)
+			continue
+		}
+		individualNotif.RecipientID = &recipient.ID
+		if notification.ParentID != nil {
+			exists, err := s.notificationRepo.ExistsForParent(*notification.ParentID, recipient.ID)
+			if err != nil {
+				return true, err
+			}
+			if exists {
+				continue
+			}
+		}
+		if err := scheduleLocalTime(&individualNotif, recipient); err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
 		if member.CPF != "" {
 			individualNotif.UserCPF = &member.CPF
 		}
@@ -276,14 +960,178 @@ func (s *notificationService) SendToGroup(groupID uuid.UUID, notification *entit
 		}
 
 		if err := s.SendNotification(&individualNotif); err != nil {
+			// Outra instância criou a notificação do membro para o mesmo envio a grupo
+			if notification.ParentID != nil && errors.Is(err, gorm.ErrDuplicatedKey) {
+				continue
+			}
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
 			continue
 		}
+		sent++
+		if individualNotif.Timezone != nil {
+			buckets[*individualNotif.Timezone]++
+		}
 	}
 
-	return nil
+	if len(buckets) > 0 {
+		log.Printf("SendToGroup: Group %s scheduled at %s local time in %d timezone bucket(s): %v",
+			*notification.GroupID, *notification.LocalTime, len(buckets), buckets)
+	}
+	if firstErr != nil {
+		return false, fmt.Errorf("failed to send to %d of %d member(s): %w", failed, len(members), firstErr)
+	}
+	return false, nil
+}
+
+// expandGroupJob libera um envio agendado a grupo: resolve os membros atuais e cria a
+// notificação de cada um, ligada ao envio por parent_id. Se a expansão for interrompida (fila
+// saturada, instância derrubada), a reserva é mantida e, quando vencer, outra tentativa retoma
+// sem repetir os membros que já receberam: o índice único (parent_id, recipient_id) impede a
+// segunda cópia mesmo se duas instâncias expandirem o mesmo envio ao mesmo tempo.
+func (s *notificationService) expandGroupJob(ctx context.Context, job *entity.Notification) error {
+	owner := *job.ClaimedBy
+	if job.IsExpired(time.Now()) {
+		log.Printf("EnqueueScheduled: Group send %s expired before its scheduled time, skipping", job.ID)
+		_, err := s.notificationRepo.CompleteGroupJob(job.ID, owner, entity.StatusExpired)
+		return err
+	}
+
+	members, err := s.groupRepo.FindMembers(*job.GroupID)
+	if err != nil {
+		return err
+	}
+
+	template := *job
+	template.ID = uuid.Nil
+	template.ParentID = &job.ID
+	template.GroupJob = false
+	template.Status = ""
+	template.IsScheduled = false
+	template.ClaimedBy = nil
+	template.ClaimExpiresAt = nil
+	template.Version = 0
+	template.CreatedAt = time.Time{}
+	template.UpdatedAt = time.Time{}
+
+	interrupted, sendErr := s.sendToMembers(ctx, &template, members, s.claimKeepalive(job))
+	if interrupted {
+		return sendErr
+	}
+
+	completed, err := s.notificationRepo.CompleteGroupJob(job.ID, owner, entity.StatusExpanded)
+	if err != nil {
+		return err
+	}
+	if !completed {
+		log.Printf("EnqueueScheduled: Group send %s is no longer claimed by this instance", job.ID)
+	}
+	job.Status = entity.StatusExpanded
+	job.IsScheduled = false
+
+	log.Printf("EnqueueScheduled: Group send %s expanded to %d member(s) of group %s", job.ID, len(members), *job.GroupID)
+	return sendErr
+}
+
+// claimKeepalive prorroga a reserva do envio agendado a grupo quando metade do lease já passou,
+// para que a expansão de grupos grandes não seja retomada em paralelo por outra instância, e
+// interrompe a expansão se a reserva já tiver sido perdida
+func (s *notificationService) claimKeepalive(job *entity.Notification) func() error {
+	if job.ClaimExpiresAt == nil {
+		return nil
+	}
+	owner := *job.ClaimedBy
+	expiresAt := *job.ClaimExpiresAt
+	lease := time.Until(expiresAt)
+	if lease < time.Minute {
+		lease = time.Minute
+	}
+	return func() error {
+		if time.Until(expiresAt) > lease/2 {
+			return nil
+		}
+		until := time.Now().Add(lease)
+		owned, err := s.notificationRepo.ExtendClaim(job.ID, owner, until)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return fmt.Errorf("%w: group send %s", ErrClaimLost, job.ID)
+		}
+		expiresAt = until
+		return nil
+	}
 }
 
 func (s *notificationService) SendBroadcast(notification *entity.Notification) error {
+	if notification.LocalTime != nil {
+		return fmt.Errorf("%w: broadcasts have no recipient timezone, use scheduled_for", ErrInvalidLocalTime)
+	}
 	notification.Broadcast = true
 	return s.SendNotification(notification)
 }
+
+// scheduleLocalTime converte local_time no instante correspondente no fuso do destinatário
+// (padrão America/Sao_Paulo). Destinatários do mesmo fuso formam um lote com o mesmo
+// scheduled_for, liberado pelo scheduler quando o horário chega nesse fuso.
+func scheduleLocalTime(notification *entity.Notification, recipient *entity.Recipient) error {
+	if notification.LocalTime == nil {
+		return nil
+	}
+
+	timezone := defaultTimezone
+	if recipient.Timezone != nil && *recipient.Timezone != "" {
+		timezone = *recipient.Timezone
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("%w: unknown timezone %q for recipient %s", ErrInvalidLocalTime, timezone, recipient.ID)
+	}
+	scheduledFor, err := time.ParseInLocation(entity.LocalTimeLayout, *notification.LocalTime, loc)
+	if err != nil {
+		return fmt.Errorf("%w: use the format %s, without offset", ErrInvalidLocalTime, entity.LocalTimeLayout)
+	}
+	if !scheduledFor.After(time.Now()) {
+		return fmt.Errorf("%w: %s has already passed in %s", ErrInvalidLocalTime, *notification.LocalTime, timezone)
+	}
+
+	notification.IsScheduled = true
+	notification.ScheduledFor = &scheduledFor
+	notification.Timezone = &timezone
+	return nil
+}
+
+// AwaitCapacity aguarda a fila deixar de estar saturada, até capacityWait ou o cancelamento
+// do contexto. Usado pelos envios em grupo e em lote para reduzir o ritmo conforme a
+// profundidade da fila; retorna QueueSaturatedError se a espera se esgotar.
+func (s *notificationService) AwaitCapacity(ctx context.Context) error {
+	deadline := time.Now().Add(capacityWait)
+	logged := false
+	for {
+		retryAfter, saturated := s.outbox.Saturated()
+		if !saturated {
+			return nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return &QueueSaturatedError{RetryAfter: retryAfter}
+		}
+		if !logged {
+			log.Printf("AwaitCapacity: Queue saturated, throttling send")
+			logged = true
+		}
+
+		// A saturação é reavaliada pelo relay a cada poucos segundos
+		wait := time.Second
+		if remaining < wait {
+			wait = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return &QueueSaturatedError{RetryAfter: retryAfter}
+		case <-time.After(wait):
+		}
+	}
+}
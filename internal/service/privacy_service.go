@@ -0,0 +1,170 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/repository"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SubjectExport é o arquivo com todos os dados mantidos sobre um titular (LGPD, art. 18, II e V)
+type SubjectExport struct {
+	FormatVersion      int                        `json:"format_version"`
+	GeneratedAt        time.Time                  `json:"generated_at"`
+	CPF                string                     `json:"cpf"`
+	Recipient          *entity.Recipient          `json:"recipient,omitempty"`
+	Notifications      []entity.Notification      `json:"notifications"`
+	Memberships        []entity.Member            `json:"memberships"`
+	Subscriptions      []entity.Subscription      `json:"push_subscriptions"`
+	RecurringSchedules []entity.RecurringSchedule `json:"recurring_schedules"`
+	Preferences        []entity.Consent           `json:"preferences"`
+	ConsentLedger      []entity.Consent           `json:"consent_ledger"`
+}
+
+type PrivacyService interface {
+	ExportSubject(cpf string) (*SubjectExport, error)
+	EraseSubject(cpf, requestedBy, reason string) (*entity.ErasureReceipt, error)
+	GetReceipt(id uuid.UUID) (*entity.ErasureReceipt, error)
+	GetReceiptsBySubject(cpf string) ([]entity.ErasureReceipt, error)
+}
+
+type privacyService struct {
+	repo        repository.PrivacyRepository
+	consentRepo repository.ConsentRepository
+	recipients  RecipientService
+	hashSecret  []byte
+}
+
+func NewPrivacyService(
+	repo repository.PrivacyRepository,
+	consentRepo repository.ConsentRepository,
+	recipients RecipientService,
+	hashSecret string,
+) PrivacyService {
+	return &privacyService{
+		repo:        repo,
+		consentRepo: consentRepo,
+		recipients:  recipients,
+		hashSecret:  []byte(hashSecret),
+	}
+}
+
+func (s *privacyService) ExportSubject(cpf string) (*SubjectExport, error) {
+	subject, recipient, err := s.subject(cpf)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &SubjectExport{
+		FormatVersion: 1,
+		GeneratedAt:   time.Now(),
+		CPF:           subject.CPF,
+		Recipient:     recipient,
+	}
+
+	if export.Notifications, err = s.repo.FindNotifications(subject); err != nil {
+		return nil, err
+	}
+	if export.Memberships, err = s.repo.FindMemberships(subject); err != nil {
+		return nil, err
+	}
+	if export.Subscriptions, err = s.repo.FindSubscriptions(subject); err != nil {
+		return nil, err
+	}
+	if export.RecurringSchedules, err = s.repo.FindRecurringSchedules(subject); err != nil {
+		return nil, err
+	}
+	if recipient != nil {
+		if export.Preferences, err = s.consentRepo.FindCurrent(recipient.ID); err != nil {
+			return nil, err
+		}
+		if export.ConsentLedger, err = s.consentRepo.FindByRecipient(recipient.ID, -1, -1); err != nil {
+			return nil, err
+		}
+	}
+
+	return export, nil
+}
+
+func (s *privacyService) EraseSubject(cpf, requestedBy, reason string) (*entity.ErasureReceipt, error) {
+	subject, _, err := s.subject(cpf)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := &entity.ErasureReceipt{
+		SubjectHash: s.subjectHash(subject.CPF),
+		RecipientID: subject.RecipientID,
+		RequestedBy: requestedBy,
+		Reason:      reason,
+	}
+	if err := s.repo.Erase(subject, receipt); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Privacy: Subject erased (receipt %s): %d notification(s), %d membership(s), %d subscription(s), %d recurring schedule(s), %d consent(s) anonymised",
+		receipt.ID, receipt.NotificationsDeleted, receipt.MembershipsDeleted, receipt.SubscriptionsDeleted,
+		receipt.RecurringSchedulesDeleted, receipt.ConsentsAnonymised)
+	return receipt, nil
+}
+
+func (s *privacyService) GetReceipt(id uuid.UUID) (*entity.ErasureReceipt, error) {
+	return s.repo.FindReceiptByID(id)
+}
+
+func (s *privacyService) GetReceiptsBySubject(cpf string) ([]entity.ErasureReceipt, error) {
+	cpf, err := validation.NormalizeCPF(cpf)
+	if err != nil {
+		return nil, err
+	}
+	// Comprovantes emitidos antes da chave existir guardam o SHA-256 puro do CPF
+	return s.repo.FindReceiptsBySubject(s.subjectHash(cpf), legacySubjectHash(cpf))
+}
+
+// subject reúne todos os identificadores do titular a partir do CPF
+func (s *privacyService) subject(cpf string) (repository.SubjectIdentifiers, *entity.Recipient, error) {
+	cpf, err := validation.NormalizeCPF(cpf)
+	if err != nil {
+		return repository.SubjectIdentifiers{}, nil, err
+	}
+
+	subject := repository.SubjectIdentifiers{CPF: cpf}
+	recipient, err := s.recipients.FindRecipient(cpf, "", "")
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return subject, nil, nil
+		}
+		return subject, nil, err
+	}
+
+	subject.RecipientID = &recipient.ID
+	if recipient.Phone != nil {
+		subject.Phone = *recipient.Phone
+	}
+	if recipient.Email != nil {
+		subject.Email = *recipient.Email
+	}
+	return subject, recipient, nil
+}
+
+// subjectHash identifica o titular nos comprovantes sem armazenar o CPF: HMAC-SHA256 com a
+// chave do servidor, que não pode ser revertido enumerando CPFs sem ela
+func (s *privacyService) subjectHash(cpf string) string {
+	mac := hmac.New(sha256.New, s.hashSecret)
+	mac.Write([]byte(cpf))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// legacySubjectHash é o hash dos comprovantes anteriores à chave
+func legacySubjectHash(cpf string) string {
+	sum := sha256.Sum256([]byte(cpf))
+	return hex.EncodeToString(sum[:])
+}
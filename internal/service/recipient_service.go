@@ -0,0 +1,247 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/repository"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RecipientService interface {
+	Resolve(cpf, phone, email, name string) (*entity.Recipient, error)
+	LookupIdentifier(identifier string) (*entity.Recipient, error)
+	FindRecipient(cpf, phone, email string) (*entity.Recipient, error)
+	GetRecipient(id uuid.UUID) (*entity.Recipient, error)
+	SetTimezone(id uuid.UUID, timezone string) (*entity.Recipient, error)
+}
+
+// ErrInvalidTimezone é retornado quando o fuso do destinatário não é um nome IANA conhecido
+var ErrInvalidTimezone = errors.New("unknown timezone, use an IANA name such as America/Sao_Paulo")
+
+type recipientService struct {
+	repo repository.RecipientRepository
+}
+
+func NewRecipientService(repo repository.RecipientRepository) RecipientService {
+	return &recipientService{repo: repo}
+}
+
+// Resolve retorna o destinatário dono dos identificadores informados, criando-o se
+// necessário, vinculando identificadores novos e unificando registros duplicados. Dois
+// primeiros envios simultâneos ao mesmo cidadão disputam a criação: quem perde recebe a
+// violação de unicidade e repete a busca, encontrando o registro criado pelo outro.
+func (s *recipientService) Resolve(cpf, phone, email, name string) (*entity.Recipient, error) {
+	recipient, err := s.resolve(cpf, phone, email, name)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return s.resolve(cpf, phone, email, name)
+	}
+	return recipient, err
+}
+
+func (s *recipientService) resolve(cpf, phone, email, name string) (*entity.Recipient, error) {
+	cpf, phone, email, err := validation.NormalizeIdentity(cpf, phone, email)
+	if err != nil {
+		return nil, err
+	}
+	if cpf == "" && phone == "" && email == "" {
+		return nil, errors.New("at least one of CPF, phone, or email is required")
+	}
+
+	matches, err := s.repo.FindByIdentifiers(cpf, phone, email)
+	if err != nil {
+		return nil, err
+	}
+
+	// O CPF é o identificador mais forte: se informado, o registro com esse CPF é o principal.
+	// Registros com outro CPF pertencem a outra pessoa e nunca são unificados.
+	primary := selectPrimary(matches, cpf)
+	if primary == nil {
+		recipient := &entity.Recipient{Name: name}
+		linkIdentifier(&recipient.CPF, &cpf)
+		if !ownedBy(matches, func(r entity.Recipient) *string { return r.Phone }, phone) {
+			linkIdentifier(&recipient.Phone, &phone)
+		}
+		if !ownedBy(matches, func(r entity.Recipient) *string { return r.Email }, email) {
+			linkIdentifier(&recipient.Email, &email)
+		}
+		if err := s.repo.Create(recipient); err != nil {
+			return nil, err
+		}
+		return recipient, nil
+	}
+
+	changed := linkIdentifier(&primary.CPF, &cpf)
+	var duplicates []uuid.UUID
+	var conflicting []entity.Recipient
+	for i := range matches {
+		other := &matches[i]
+		if other.ID == primary.ID {
+			continue
+		}
+
+		if other.CPF != nil && primary.CPF != nil && *other.CPF != *primary.CPF {
+			log.Printf("Recipient %s shares an identifier with recipient %s but has a different CPF", other.ID, primary.ID)
+			conflicting = append(conflicting, *other)
+			continue
+		}
+
+		duplicates = append(duplicates, other.ID)
+		changed = linkIdentifier(&primary.CPF, other.CPF) || changed
+		changed = linkIdentifier(&primary.Phone, other.Phone) || changed
+		changed = linkIdentifier(&primary.Email, other.Email) || changed
+		changed = linkIdentifier(&primary.Timezone, other.Timezone) || changed
+		if primary.Name == "" && other.Name != "" {
+			primary.Name = other.Name
+			changed = true
+		}
+	}
+
+	if phone != "" && !ownedBy(conflicting, func(r entity.Recipient) *string { return r.Phone }, phone) {
+		changed = linkIdentifier(&primary.Phone, &phone) || changed
+	}
+	if email != "" && !ownedBy(conflicting, func(r entity.Recipient) *string { return r.Email }, email) {
+		changed = linkIdentifier(&primary.Email, &email) || changed
+	}
+	if primary.Name == "" && name != "" {
+		primary.Name = name
+		changed = true
+	}
+
+	if len(duplicates) > 0 {
+		log.Printf("Merging %d duplicate recipient(s) into %s", len(duplicates), primary.ID)
+		if err := s.repo.Merge(primary, duplicates); err != nil {
+			return nil, err
+		}
+	} else if changed {
+		if err := s.repo.Update(primary); err != nil {
+			return nil, err
+		}
+	}
+
+	return primary, nil
+}
+
+// LookupIdentifier busca, sem criar, o destinatário de um identificador avulso (CPF, telefone
+// ou email), como o user_id recebido na conexão WebSocket. Retorna gorm.ErrRecordNotFound se
+// não existir.
+func (s *recipientService) LookupIdentifier(identifier string) (*entity.Recipient, error) {
+	cpf, phone, email, err := validation.ClassifyIdentifier(identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if recipient, err := s.FindRecipient(cpf, phone, email); err == nil {
+		return recipient, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Onze dígitos com CPF válido também podem ser um celular com DDD já cadastrado
+	if cpf != "" {
+		if asPhone, err := validation.NormalizePhone(identifier); err == nil {
+			return s.FindRecipient("", asPhone, "")
+		}
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
+
+// FindRecipient busca o destinatário sem criá-lo, retornando gorm.ErrRecordNotFound se não existir
+func (s *recipientService) FindRecipient(cpf, phone, email string) (*entity.Recipient, error) {
+	cpf, phone, email, err := validation.NormalizeIdentity(cpf, phone, email)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := s.repo.FindByIdentifiers(cpf, phone, email)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	for i := range matches {
+		if cpf != "" && matches[i].CPF != nil && *matches[i].CPF == cpf {
+			return &matches[i], nil
+		}
+	}
+	return &matches[0], nil
+}
+
+func (s *recipientService) GetRecipient(id uuid.UUID) (*entity.Recipient, error) {
+	return s.repo.FindByID(id)
+}
+
+// SetTimezone define o fuso usado nos agendamentos em horário local; vazio volta ao padrão
+// (America/Sao_Paulo)
+func (s *recipientService) SetTimezone(id uuid.UUID, timezone string) (*entity.Recipient, error) {
+	timezone = strings.TrimSpace(timezone)
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, ErrInvalidTimezone
+		}
+	}
+
+	recipient, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	recipient.Timezone = nil
+	if timezone != "" {
+		recipient.Timezone = &timezone
+	}
+	if err := s.repo.Update(recipient); err != nil {
+		return nil, err
+	}
+	return recipient, nil
+}
+
+// selectPrimary escolhe o registro principal entre os encontrados. Retorna nil quando
+// nenhum registro é compatível com o CPF informado.
+func selectPrimary(matches []entity.Recipient, cpf string) *entity.Recipient {
+	if cpf == "" {
+		if len(matches) == 0 {
+			return nil
+		}
+		return &matches[0]
+	}
+
+	var withoutCPF *entity.Recipient
+	for i := range matches {
+		if matches[i].CPF == nil {
+			if withoutCPF == nil {
+				withoutCPF = &matches[i]
+			}
+			continue
+		}
+		if *matches[i].CPF == cpf {
+			return &matches[i]
+		}
+	}
+	return withoutCPF
+}
+
+// linkIdentifier preenche o identificador do destinatário principal se ainda estiver vazio
+func linkIdentifier(target **string, value *string) bool {
+	if *target != nil || value == nil || *value == "" {
+		return false
+	}
+	v := *value
+	*target = &v
+	return true
+}
+
+func ownedBy(recipients []entity.Recipient, field func(entity.Recipient) *string, value string) bool {
+	for _, r := range recipients {
+		if v := field(r); v != nil && *v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/repository"
+	"github.com/prefeitura-rio/app-notification-core/pkg/recurrence"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidRecurringSchedule é retornado quando a regra, o destino ou o conteúdo da série
+// não são válidos
+var ErrInvalidRecurringSchedule = errors.New("invalid recurring schedule")
+
+// ErrRecurringScheduleState é retornado ao pausar uma série que não está ativa ou retomar
+// uma que não está pausada
+var ErrRecurringScheduleState = errors.New("recurring schedule is not in the required status")
+
+// defaultTimezone é o fuso das séries criadas sem timezone e dos destinatários sem fuso
+// cadastrado
+const defaultTimezone = "America/Sao_Paulo"
+
+// dueSchedulesBatch limita as séries processadas a cada verificação do scheduler
+const dueSchedulesBatch = 100
+
+type RecurringScheduleService interface {
+	CreateSchedule(schedule *entity.RecurringSchedule) error
+	GetSchedule(id uuid.UUID) (*entity.RecurringSchedule, error)
+	ListSchedules(status entity.RecurringStatus, limit, offset int) ([]entity.RecurringSchedule, error)
+	UpcomingOccurrences(id uuid.UUID, limit int) ([]time.Time, error)
+	PauseSchedule(id uuid.UUID) error
+	ResumeSchedule(id uuid.UUID) error
+	DeleteSchedule(id uuid.UUID) error
+	FindDue(now time.Time) ([]entity.RecurringSchedule, error)
+	RunOccurrence(ctx context.Context, schedule *entity.RecurringSchedule, now time.Time) error
+}
+
+type recurringScheduleService struct {
+	repo          repository.RecurringScheduleRepository
+	groupRepo     repository.GroupRepository
+	notifications NotificationService
+	consents      ConsentService
+}
+
+func NewRecurringScheduleService(
+	repo repository.RecurringScheduleRepository,
+	groupRepo repository.GroupRepository,
+	notifications NotificationService,
+	consents ConsentService,
+) RecurringScheduleService {
+	return &recurringScheduleService{repo: repo, groupRepo: groupRepo, notifications: notifications, consents: consents}
+}
+
+// CreateSchedule valida a série e calcula a primeira ocorrência a partir de agora
+func (s *recurringScheduleService) CreateSchedule(schedule *entity.RecurringSchedule) error {
+	if err := s.normalizeSchedule(schedule); err != nil {
+		return err
+	}
+	rule, err := scheduleRule(schedule)
+	if err != nil {
+		return err
+	}
+
+	schedule.Status = entity.RecurringActive
+	schedule.Occurrences = 0
+	schedule.NextRunAt = nextOccurrence(schedule, rule, time.Now(), 0)
+	if schedule.NextRunAt == nil {
+		return fmt.Errorf("%w: the rule has no occurrences between start_at and end_at", ErrInvalidRecurringSchedule)
+	}
+	return s.repo.Create(schedule)
+}
+
+func (s *recurringScheduleService) GetSchedule(id uuid.UUID) (*entity.RecurringSchedule, error) {
+	return s.repo.FindByID(id)
+}
+
+func (s *recurringScheduleService) ListSchedules(status entity.RecurringStatus, limit, offset int) ([]entity.RecurringSchedule, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	return s.repo.FindAll(status, limit, offset)
+}
+
+// UpcomingOccurrences lista as próximas ocorrências da série. Séries pausadas mostram as
+// ocorrências que teriam se fossem retomadas agora; séries concluídas não têm ocorrências.
+func (s *recurringScheduleService) UpcomingOccurrences(id uuid.UUID, limit int) ([]time.Time, error) {
+	schedule, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := scheduleRule(schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := []time.Time{}
+	var next *time.Time
+	switch schedule.Status {
+	case entity.RecurringActive:
+		next = schedule.NextRunAt
+	case entity.RecurringPaused:
+		next = nextOccurrence(schedule, rule, time.Now(), schedule.Occurrences)
+	}
+	count := schedule.Occurrences
+	for next != nil && len(occurrences) < limit {
+		occurrences = append(occurrences, next.In(rule.Location()))
+		count++
+		next = nextOccurrence(schedule, rule, *next, count)
+	}
+	return occurrences, nil
+}
+
+// PauseSchedule suspende a série; as ocorrências durante a pausa não são enviadas
+func (s *recurringScheduleService) PauseSchedule(id uuid.UUID) error {
+	schedule, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	ok, err := s.repo.Transition(id, entity.RecurringActive, entity.RecurringPaused, schedule.NextRunAt)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: only active schedules can be paused", ErrRecurringScheduleState)
+	}
+	return nil
+}
+
+// ResumeSchedule reativa a série a partir da próxima ocorrência após agora
+func (s *recurringScheduleService) ResumeSchedule(id uuid.UUID) error {
+	schedule, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if schedule.Status != entity.RecurringPaused {
+		return fmt.Errorf("%w: only paused schedules can be resumed", ErrRecurringScheduleState)
+	}
+	rule, err := scheduleRule(schedule)
+	if err != nil {
+		return err
+	}
+
+	next := nextOccurrence(schedule, rule, time.Now(), schedule.Occurrences)
+	status := entity.RecurringActive
+	if next == nil {
+		status = entity.RecurringCompleted
+	}
+	ok, err := s.repo.Transition(id, entity.RecurringPaused, status, next)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: only paused schedules can be resumed", ErrRecurringScheduleState)
+	}
+	return nil
+}
+
+// DeleteSchedule remove a série; as notificações já criadas por ela são mantidas
+func (s *recurringScheduleService) DeleteSchedule(id uuid.UUID) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+// FindDue busca as séries com ocorrência vencida
+func (s *recurringScheduleService) FindDue(now time.Time) ([]entity.RecurringSchedule, error) {
+	return s.repo.FindDue(now, dueSchedulesBatch)
+}
+
+// RunOccurrence registra a ocorrência vencida, agenda a próxima e envia a notificação da
+// ocorrência. O registro é condicional, então cada ocorrência é enviada por uma única
+// instância. Ocorrências perdidas enquanto o serviço esteve fora do ar não são repetidas:
+// envia-se apenas a vencida e a próxima é a primeira após agora.
+//
+// Cada envio grava uma única notificação: a ocorrência de um grupo vira um envio agendado ao
+// grupo para agora, expandido pelo scheduler sem repetir membros. Se a gravação falhar por um
+// erro temporário (ex: fila saturada, banco indisponível), o registro é desfeito e o próximo
+// ciclo tenta a ocorrência de novo; erros da própria série (ex: grupo removido) apenas ficam
+// em last_error.
+func (s *recurringScheduleService) RunOccurrence(ctx context.Context, schedule *entity.RecurringSchedule, now time.Time) error {
+	if schedule.NextRunAt == nil {
+		return nil
+	}
+	occurrence := *schedule.NextRunAt
+
+	rule, err := scheduleRule(schedule)
+	if err != nil {
+		// A regra foi validada na criação; se deixou de valer (ex: fuso removido do
+		// sistema), a série é pausada para não ser verificada a cada minuto
+		log.Printf("RunOccurrence: Invalid rule for recurring schedule %s: %v", schedule.ID, err)
+		s.repo.Transition(schedule.ID, entity.RecurringActive, entity.RecurringPaused, schedule.NextRunAt)
+		return s.repo.SetLastError(schedule.ID, err.Error())
+	}
+
+	next := nextOccurrence(schedule, rule, now, schedule.Occurrences+1)
+	status := entity.RecurringActive
+	if next == nil {
+		status = entity.RecurringCompleted
+	}
+	claimed, err := s.repo.Advance(schedule.ID, occurrence, next, status)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	notification := schedule.NewOccurrence()
+	switch schedule.Target {
+	case entity.RecurringTargetUser:
+		err = s.notifications.SendToUser(deref(schedule.UserCPF), deref(schedule.UserPhone), deref(schedule.UserEmail), notification)
+	case entity.RecurringTargetGroup:
+		notification.IsScheduled = true
+		notification.ScheduledFor = &now
+		err = s.notifications.SendToGroup(ctx, *schedule.GroupID, notification)
+	case entity.RecurringTargetBroadcast:
+		err = s.notifications.SendBroadcast(notification)
+	}
+	if err != nil {
+		if isPermanentOccurrenceError(err) {
+			s.repo.SetLastError(schedule.ID, err.Error())
+			return fmt.Errorf("occurrence %s: %w", occurrence.Format(time.RFC3339), err)
+		}
+		rewound, rewindErr := s.repo.Rewind(schedule.ID, occurrence, next, status, schedule.LastRunAt, err.Error())
+		if rewindErr != nil {
+			log.Printf("RunOccurrence: Failed to rewind recurring schedule %s, occurrence %s is lost: %v", schedule.ID, occurrence.Format(time.RFC3339), rewindErr)
+		} else if rewound {
+			log.Printf("RunOccurrence: Recurring schedule %s occurrence %s will be retried", schedule.ID, occurrence.Format(time.RFC3339))
+		}
+		return fmt.Errorf("occurrence %s: %w", occurrence.Format(time.RFC3339), err)
+	}
+
+	log.Printf("RunOccurrence: Recurring schedule %s occurrence %s sent (next: %s)", schedule.ID, occurrence.Format(time.RFC3339), formatNext(next))
+	return nil
+}
+
+// isPermanentOccurrenceError indica se a falha do envio se repetiria a cada tentativa
+func isPermanentOccurrenceError(err error) bool {
+	return IsInvalidRecurringSchedule(err) ||
+		errors.Is(err, gorm.ErrRecordNotFound) ||
+		errors.Is(err, ErrBroadcastRequiresConsent) ||
+		errors.Is(err, ErrInvalidExpiry) ||
+		errors.Is(err, ErrInvalidLocalTime)
+}
+
+// IsInvalidRecurringSchedule indica se o erro foi causado por dados inválidos da série
+func IsInvalidRecurringSchedule(err error) bool {
+	return errors.Is(err, ErrInvalidRecurringSchedule) ||
+		errors.Is(err, recurrence.ErrInvalidRule) ||
+		errors.Is(err, ErrInvalidPriority) ||
+		validation.IsValidationError(err)
+}
+
+func (s *recurringScheduleService) normalizeSchedule(schedule *entity.RecurringSchedule) error {
+	if schedule.Title == "" || schedule.Message == "" {
+		return fmt.Errorf("%w: title and message are required", ErrInvalidRecurringSchedule)
+	}
+	switch schedule.Type {
+	case entity.TypeInApp, entity.TypePush, entity.TypeEmail, entity.TypeBoth, entity.TypeAll:
+	default:
+		return fmt.Errorf("%w: unknown notification type %q", ErrInvalidRecurringSchedule, schedule.Type)
+	}
+	if schedule.Priority == "" {
+		schedule.Priority = entity.PriorityNormal
+	}
+	if !entity.IsValidPriority(schedule.Priority) {
+		return ErrInvalidPriority
+	}
+
+	schedule.Kind = strings.ToLower(strings.TrimSpace(schedule.Kind))
+	if schedule.Timezone == "" {
+		schedule.Timezone = defaultTimezone
+	}
+	if schedule.StartAt.IsZero() {
+		schedule.StartAt = time.Now()
+	}
+	if schedule.EndAt != nil && !schedule.EndAt.After(schedule.StartAt) {
+		return fmt.Errorf("%w: end_at must be after start_at", ErrInvalidRecurringSchedule)
+	}
+	if schedule.MaxOccurrences != nil && *schedule.MaxOccurrences <= 0 {
+		return fmt.Errorf("%w: max_occurrences must be positive", ErrInvalidRecurringSchedule)
+	}
+
+	switch schedule.Target {
+	case entity.RecurringTargetUser:
+		cpf, phone, email, err := validation.NormalizeIdentity(deref(schedule.UserCPF), deref(schedule.UserPhone), deref(schedule.UserEmail))
+		if err != nil {
+			return err
+		}
+		if cpf == "" && phone == "" && email == "" {
+			return fmt.Errorf("%w: cpf, phone or email is required for target user", ErrInvalidRecurringSchedule)
+		}
+		schedule.UserCPF, schedule.UserPhone, schedule.UserEmail = optional(cpf), optional(phone), optional(email)
+		schedule.GroupID = nil
+	case entity.RecurringTargetGroup:
+		if schedule.GroupID == nil {
+			return fmt.Errorf("%w: group_id is required for target group", ErrInvalidRecurringSchedule)
+		}
+		if _, err := s.groupRepo.FindByID(*schedule.GroupID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: group %s not found", ErrInvalidRecurringSchedule, *schedule.GroupID)
+			}
+			return err
+		}
+		schedule.UserCPF, schedule.UserPhone, schedule.UserEmail = nil, nil, nil
+	case entity.RecurringTargetBroadcast:
+		if s.consents.RequiresOptIn(schedule.Purpose) {
+			return fmt.Errorf("%w: purpose %q requires explicit consent and cannot be broadcast", ErrInvalidRecurringSchedule, schedule.Purpose)
+		}
+		schedule.UserCPF, schedule.UserPhone, schedule.UserEmail, schedule.GroupID = nil, nil, nil, nil
+	default:
+		return fmt.Errorf("%w: target must be user, group or broadcast", ErrInvalidRecurringSchedule)
+	}
+	return nil
+}
+
+func scheduleRule(schedule *entity.RecurringSchedule) (recurrence.Rule, error) {
+	return recurrence.Parse(recurrence.Kind(schedule.Kind), schedule.Expression, schedule.Timezone, schedule.StartAt)
+}
+
+// nextOccurrence é a primeira ocorrência após t, ou nil se a série terminou (end_at ou
+// max_occurrences, contando as occurrences já enviadas)
+func nextOccurrence(schedule *entity.RecurringSchedule, rule recurrence.Rule, t time.Time, occurrences int) *time.Time {
+	if schedule.MaxOccurrences != nil && occurrences >= *schedule.MaxOccurrences {
+		return nil
+	}
+	next := rule.Next(t)
+	if next.IsZero() || (schedule.EndAt != nil && next.After(*schedule.EndAt)) {
+		return nil
+	}
+	return &next
+}
+
+func formatNext(next *time.Time) string {
+	if next == nil {
+		return "none, series completed"
+	}
+	return next.Format(time.RFC3339)
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
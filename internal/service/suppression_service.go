@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/prefeitura-rio/app-notification-core/internal/repository"
+	"github.com/prefeitura-rio/app-notification-core/pkg/validation"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidSuppression = errors.New("invalid suppression")
+
+// SuppressionImportError descreve uma linha rejeitada na importação em lote
+type SuppressionImportError struct {
+	Line    int    `json:"line"`
+	Address string `json:"address"`
+	Error   string `json:"error"`
+}
+
+type SuppressionImportResult struct {
+	Imported int                      `json:"imported"`
+	Rejected []SuppressionImportError `json:"rejected"`
+}
+
+type SuppressionService interface {
+	Suppress(suppression *entity.Suppression) error
+	ImportSuppressions(suppressions []entity.Suppression) (*SuppressionImportResult, error)
+	IsSuppressed(channel entity.SuppressionChannel, address string) (bool, error)
+	GetSuppression(id uuid.UUID) (*entity.Suppression, error)
+	ListSuppressions(channel entity.SuppressionChannel, limit, offset int) ([]entity.Suppression, error)
+	RemoveSuppression(id uuid.UUID) error
+}
+
+type suppressionService struct {
+	repo repository.SuppressionRepository
+}
+
+func NewSuppressionService(repo repository.SuppressionRepository) SuppressionService {
+	return &suppressionService{repo: repo}
+}
+
+// Suppress adiciona o endereço à lista ou atualiza a supressão existente
+func (s *suppressionService) Suppress(suppression *entity.Suppression) error {
+	if err := normalizeSuppression(suppression); err != nil {
+		return err
+	}
+	return s.repo.Upsert(suppression)
+}
+
+// ImportSuppressions grava as entradas válidas e reporta as rejeitadas sem interromper a importação
+func (s *suppressionService) ImportSuppressions(suppressions []entity.Suppression) (*SuppressionImportResult, error) {
+	result := &SuppressionImportResult{Rejected: []SuppressionImportError{}}
+
+	// Endereços repetidos no mesmo lote quebrariam o ON CONFLICT; vale a última ocorrência
+	valid := make([]entity.Suppression, 0, len(suppressions))
+	seen := make(map[string]int, len(suppressions))
+	for i := range suppressions {
+		suppression := suppressions[i]
+		if err := normalizeSuppression(&suppression); err != nil {
+			result.Rejected = append(result.Rejected, SuppressionImportError{
+				Line:    i + 1,
+				Address: suppressions[i].Address,
+				Error:   err.Error(),
+			})
+			continue
+		}
+		key := string(suppression.Channel) + ":" + suppression.Address
+		if idx, ok := seen[key]; ok {
+			valid[idx] = suppression
+			continue
+		}
+		seen[key] = len(valid)
+		valid = append(valid, suppression)
+	}
+
+	if err := s.repo.UpsertBatch(valid); err != nil {
+		return nil, err
+	}
+	result.Imported = len(valid)
+	return result, nil
+}
+
+// IsSuppressed indica se o endereço possui uma supressão vigente no canal
+func (s *suppressionService) IsSuppressed(channel entity.SuppressionChannel, address string) (bool, error) {
+	address, err := normalizeSuppressionAddress(channel, address)
+	if err != nil {
+		// Endereços inválidos não podem estar na lista
+		return false, nil
+	}
+
+	_, err = s.repo.FindActive(channel, address, time.Now())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *suppressionService) GetSuppression(id uuid.UUID) (*entity.Suppression, error) {
+	return s.repo.FindByID(id)
+}
+
+func (s *suppressionService) ListSuppressions(channel entity.SuppressionChannel, limit, offset int) ([]entity.Suppression, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	return s.repo.FindAll(channel, limit, offset)
+}
+
+func (s *suppressionService) RemoveSuppression(id uuid.UUID) error {
+	return s.repo.Delete(id)
+}
+
+// IsInvalidSuppression indica se o erro foi causado por dados inválidos, e não por falha ao gravar
+func IsInvalidSuppression(err error) bool {
+	return errors.Is(err, ErrInvalidSuppression) || validation.IsValidationError(err)
+}
+
+func normalizeSuppression(suppression *entity.Suppression) error {
+	address, err := normalizeSuppressionAddress(suppression.Channel, suppression.Address)
+	if err != nil {
+		return err
+	}
+	suppression.Address = address
+
+	switch suppression.Reason {
+	case "":
+		suppression.Reason = entity.SuppressionManual
+	case entity.SuppressionHardBounce, entity.SuppressionComplaint, entity.SuppressionDoNotContact, entity.SuppressionManual:
+	default:
+		return fmt.Errorf("%w: unknown reason %q", ErrInvalidSuppression, suppression.Reason)
+	}
+
+	if suppression.Source == "" {
+		suppression.Source = "api"
+	}
+	return nil
+}
+
+func normalizeSuppressionAddress(channel entity.SuppressionChannel, address string) (string, error) {
+	switch channel {
+	case entity.SuppressionChannelEmail:
+		return validation.NormalizeEmail(address)
+	case entity.SuppressionChannelPhone:
+		return validation.NormalizePhone(address)
+	}
+	return "", fmt.Errorf("%w: unknown channel %q", ErrInvalidSuppression, channel)
+}
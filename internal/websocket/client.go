@@ -17,10 +17,14 @@ type Client struct {
 	hub    *Hub
 	conn   *websocket.Conn
 	send   chan []byte
-	userID string
+	userID string // ID do destinatário (entity.Recipient)
+	// closeFrame é o corpo do close frame enviado quando o hub fecha send
+	closeFrame []byte
 }
 
 func NewClient(hub *Hub, conn *websocket.Conn, userID string) *Client {
+	// O WritePump, iniciado logo após o registro, sinaliza o hub ao terminar
+	hub.writers.Add(1)
 	return &Client{
 		hub:    hub,
 		conn:   conn,
@@ -31,7 +35,10 @@ func NewClient(hub *Hub, conn *websocket.Conn, userID string) *Client {
 
 func (c *Client) ReadPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		c.conn.Close()
 	}()
 
@@ -57,6 +64,7 @@ func (c *Client) WritePump() {
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		c.hub.writers.Done()
 	}()
 
 	for {
@@ -64,7 +72,11 @@ func (c *Client) WritePump() {
 		case message, ok := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeFrame := c.closeFrame
+				if closeFrame == nil {
+					closeFrame = []byte{}
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeFrame)
 				return
 			}
 
@@ -1,8 +1,10 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/gorilla/websocket"
 	"sync"
 )
 
@@ -12,6 +14,13 @@ type Hub struct {
 	register   chan *Client
 	unregister chan *Client
 	mu         sync.RWMutex
+
+	// shutdown pede o encerramento; done é fechado quando Run termina
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	done         chan struct{}
+	// writers acompanha os WritePump ativos, para aguardar o envio dos close frames
+	writers sync.WaitGroup
 }
 
 func NewHub() *Hub {
@@ -20,12 +29,23 @@ func NewHub() *Hub {
 		broadcast:  make(chan *entity.Notification, 256),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		shutdown:   make(chan struct{}),
+		done:       make(chan struct{}),
 	}
 }
 
+// goingAway é o close frame enviado aos clientes no encerramento do servidor; o cliente
+// deve reconectar (em outra instância)
+var goingAway = websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+
 func (h *Hub) Run() {
+	defer close(h.done)
 	for {
 		select {
+		case <-h.shutdown:
+			h.closeClients()
+			return
+
 		case client := <-h.register:
 			h.mu.Lock()
 			if h.clients[client.userID] == nil {
@@ -76,14 +96,9 @@ func (h *Hub) sendNotification(notification *entity.Notification) {
 		return
 	}
 
-	var targetUserID string
-	if notification.UserCPF != nil {
-		targetUserID = *notification.UserCPF
-	} else if notification.UserPhone != nil {
-		targetUserID = *notification.UserPhone
-	}
-
-	if targetUserID != "" {
+	// Clientes são indexados pelo ID do destinatário, independente do identificador usado na
+	// conexão; quem conectou antes de o destinatário existir é indexado pelo identificador
+	for _, targetUserID := range targetUserIDs(notification) {
 		if clients, ok := h.clients[targetUserID]; ok {
 			for client := range clients {
 				select {
@@ -97,10 +112,71 @@ func (h *Hub) sendNotification(notification *entity.Notification) {
 	}
 }
 
+// targetUserIDs lista as chaves dos clientes que recebem a notificação
+func targetUserIDs(notification *entity.Notification) []string {
+	var ids []string
+	if notification.RecipientID != nil {
+		ids = append(ids, notification.RecipientID.String())
+	}
+	for _, identifier := range []*string{notification.UserCPF, notification.UserPhone, notification.UserEmail} {
+		if identifier != nil && *identifier != "" {
+			ids = append(ids, *identifier)
+		}
+	}
+	return ids
+}
+
+// closeClients encerra todas as conexões com o close frame de encerramento
+func (h *Hub) closeClients() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for userID, clients := range h.clients {
+		for client := range clients {
+			client.closeFrame = goingAway
+			close(client.send)
+		}
+		delete(h.clients, userID)
+	}
+}
+
+// Shutdown envia o close frame a todos os clientes e aguarda o envio até o prazo do
+// contexto. Após o Shutdown, novas conexões são fechadas imediatamente.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.shutdownOnce.Do(func() {
+		close(h.shutdown)
+	})
+
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	sent := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (h *Hub) BroadcastNotification(notification *entity.Notification) {
-	h.broadcast <- notification
+	select {
+	case h.broadcast <- notification:
+	case <-h.done:
+	}
 }
 
 func (h *Hub) Register(client *Client) {
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.done:
+		client.closeFrame = goingAway
+		close(client.send)
+	}
 }
@@ -0,0 +1,318 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/pkg/queue"
+)
+
+const (
+	defaultScaleInterval    = 10 * time.Second
+	defaultBacklogPerWorker = 100
+	defaultMaxLatency       = 2 * time.Second
+
+	// scaleDownTicks é a quantidade de avaliações seguidas com a fila curta antes de remover
+	// um worker, para o pool não oscilar a cada pico
+	scaleDownTicks = 3
+	// restartDelay é a pausa antes de reiniciar um worker que caiu
+	restartDelay = time.Second
+)
+
+// DepthSource informa as mensagens prontas de cada fila (implementado por queue.Queue)
+type DepthSource interface {
+	Depths() (map[string]int, error)
+}
+
+// Pool descreve o pool de workers de uma fila. Name é a chave da fila em Depths (a
+// prioridade ou o canal); Consume registra um consumer na fila e Handle processa cada
+// mensagem.
+type Pool struct {
+	Name    string
+	Min     int
+	Max     int
+	Consume func(ctx context.Context, handler func(*queue.NotificationMessage) error) error
+	Handle  func(*queue.NotificationMessage) error
+}
+
+// Supervisor mantém os pools de workers das filas. A cada intervalo compara a profundidade
+// da fila e o tempo médio de processamento de cada pool e adiciona ou remove workers entre
+// Min e Max; workers que caem (inclusive por panic) são reiniciados.
+type Supervisor struct {
+	depths           DepthSource
+	interval         time.Duration
+	backlogPerWorker int
+	maxLatency       time.Duration
+
+	pools   []*pool
+	workers sync.WaitGroup
+}
+
+type pool struct {
+	Pool
+
+	mu        sync.Mutex
+	active    []*worker
+	nextID    int
+	depth     int
+	lowTicks  int
+	restarts  int64
+	latency   time.Duration
+	rate      float64
+	scaledAt  time.Time
+	lastScale string
+
+	// Acumulados desde a última avaliação, para o tempo médio de processamento
+	windowCount int64
+	windowNanos int64
+}
+
+type worker struct {
+	id        int
+	cancel    context.CancelFunc
+	startedAt time.Time
+	processed int64
+	failed    int64
+	// windowProcessed é zerado a cada avaliação; rate é a vazão da última janela
+	windowProcessed int64
+	rate            float64
+}
+
+func NewSupervisor(depths DepthSource, interval time.Duration, backlogPerWorker int, maxLatency time.Duration) *Supervisor {
+	if interval <= 0 {
+		interval = defaultScaleInterval
+	}
+	if backlogPerWorker <= 0 {
+		backlogPerWorker = defaultBacklogPerWorker
+	}
+	if maxLatency <= 0 {
+		maxLatency = defaultMaxLatency
+	}
+	return &Supervisor{
+		depths:           depths,
+		interval:         interval,
+		backlogPerWorker: backlogPerWorker,
+		maxLatency:       maxLatency,
+	}
+}
+
+// AddPool registra um pool; deve ser chamado antes de Run. Max abaixo de Min fixa o pool
+// em Min workers.
+func (s *Supervisor) AddPool(spec Pool) {
+	if spec.Min < 0 {
+		spec.Min = 0
+	}
+	if spec.Max < spec.Min {
+		spec.Max = spec.Min
+	}
+	s.pools = append(s.pools, &pool{Pool: spec})
+}
+
+// Run inicia os workers de cada pool com o mínimo configurado e ajusta os pools até o
+// contexto ser cancelado. Cancelado, os workers deixam de receber mensagens e Run retorna
+// quando todos concluírem a que estão processando.
+func (s *Supervisor) Run(ctx context.Context) {
+	for _, p := range s.pools {
+		log.Printf("Starting %d %s workers (max %d)...", p.Min, p.Name, p.Max)
+		p.mu.Lock()
+		for i := 0; i < p.Min; i++ {
+			s.spawn(ctx, p)
+		}
+		p.mu.Unlock()
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.workers.Wait()
+			log.Println("👷 Worker supervisor stopped")
+			return
+		case <-ticker.C:
+			s.scale(ctx)
+		}
+	}
+}
+
+// scale avalia cada pool: cresce quando a fila passa de backlogPerWorker mensagens por
+// worker ou quando, com mensagens na fila, o processamento está mais lento que maxLatency;
+// encolhe um worker por vez após scaleDownTicks avaliações com a fila curta
+func (s *Supervisor) scale(ctx context.Context) {
+	depths, err := s.depths.Depths()
+	if err != nil {
+		log.Printf("⚠️ Worker supervisor: %v", err)
+	}
+
+	for _, p := range s.pools {
+		p.mu.Lock()
+		count := atomic.SwapInt64(&p.windowCount, 0)
+		nanos := atomic.SwapInt64(&p.windowNanos, 0)
+		p.latency = 0
+		if count > 0 {
+			p.latency = time.Duration(nanos / count)
+		}
+		p.rate = float64(count) / s.interval.Seconds()
+		for _, w := range p.active {
+			w.rate = float64(atomic.SwapInt64(&w.windowProcessed, 0)) / s.interval.Seconds()
+		}
+
+		// Sem a profundidade das filas os pools mantêm o tamanho atual
+		if err != nil {
+			p.mu.Unlock()
+			continue
+		}
+
+		p.depth = depths[p.Name]
+		current := len(p.active)
+		target := current
+		switch {
+		case p.depth > current*s.backlogPerWorker:
+			target = (p.depth + s.backlogPerWorker - 1) / s.backlogPerWorker
+			p.lowTicks = 0
+		case p.depth > 0 && p.latency > s.maxLatency:
+			target = current + 1
+			p.lowTicks = 0
+		case p.depth < s.backlogPerWorker:
+			p.lowTicks++
+			if p.lowTicks >= scaleDownTicks {
+				target = current - 1
+				p.lowTicks = 0
+			}
+		default:
+			p.lowTicks = 0
+		}
+		if target > p.Max {
+			target = p.Max
+		}
+		if target < p.Min {
+			target = p.Min
+		}
+
+		if target != current {
+			p.lastScale = fmt.Sprintf("%d -> %d (depth %d, latency %s)", current, target, p.depth, p.latency.Round(time.Millisecond))
+			p.scaledAt = time.Now()
+			log.Printf("👷 Worker supervisor: %s pool %s", p.Name, p.lastScale)
+		}
+		for len(p.active) < target {
+			s.spawn(ctx, p)
+		}
+		for len(p.active) > target {
+			// Remove o worker mais novo; ele conclui a mensagem em andamento e para
+			last := p.active[len(p.active)-1]
+			p.active = p.active[:len(p.active)-1]
+			last.cancel()
+		}
+		p.mu.Unlock()
+	}
+}
+
+// spawn inicia um worker no pool. Deve ser chamado com p.mu travado.
+func (s *Supervisor) spawn(ctx context.Context, p *pool) {
+	p.nextID++
+	workerCtx, cancel := context.WithCancel(ctx)
+	w := &worker{id: p.nextID, cancel: cancel, startedAt: time.Now()}
+	p.active = append(p.active, w)
+
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		defer cancel()
+		log.Printf("Worker %s-%d started", p.Name, w.id)
+		for {
+			err := s.consume(workerCtx, p, w)
+			if workerCtx.Err() != nil {
+				log.Printf("Worker %s-%d stopped", p.Name, w.id)
+				return
+			}
+
+			// O consumer só retorna sozinho em caso de falha: reiniciar o worker
+			atomic.AddInt64(&p.restarts, 1)
+			log.Printf("⚠️ Worker %s-%d crashed, restarting in %s: %v", p.Name, w.id, restartDelay, err)
+			select {
+			case <-workerCtx.Done():
+				return
+			case <-time.After(restartDelay):
+			}
+		}
+	}()
+}
+
+// consume roda o consumer do worker, registrando a duração e o resultado de cada mensagem.
+// Um panic no processamento derruba apenas este consumer, que é reiniciado; a mensagem não
+// confirmada volta para a fila.
+func (s *Supervisor) consume(ctx context.Context, p *pool, w *worker) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	err = p.Consume(ctx, func(msg *queue.NotificationMessage) error {
+		start := time.Now()
+		handleErr := p.Handle(msg)
+		atomic.AddInt64(&p.windowNanos, int64(time.Since(start)))
+		atomic.AddInt64(&p.windowCount, 1)
+		atomic.AddInt64(&w.windowProcessed, 1)
+		atomic.AddInt64(&w.processed, 1)
+		if handleErr != nil {
+			atomic.AddInt64(&w.failed, 1)
+		}
+		return handleErr
+	})
+	if err == nil {
+		err = errors.New("consumer returned")
+	}
+	return err
+}
+
+// Stats descreve os pools para o GET /queue/stats: tamanho atual e limites, profundidade e
+// tempo médio de processamento da última avaliação, reinícios e a vazão de cada worker
+func (s *Supervisor) Stats() map[string]interface{} {
+	pools := map[string]interface{}{}
+	total := 0
+	for _, p := range s.pools {
+		p.mu.Lock()
+		workers := make([]map[string]interface{}, 0, len(p.active))
+		for _, w := range p.active {
+			workers = append(workers, map[string]interface{}{
+				"id":                 w.id,
+				"started_at":         w.startedAt,
+				"processed":          atomic.LoadInt64(&w.processed),
+				"failed":             atomic.LoadInt64(&w.failed),
+				"throughput_per_sec": w.rate,
+			})
+		}
+		stats := map[string]interface{}{
+			"workers":            len(p.active),
+			"min":                p.Min,
+			"max":                p.Max,
+			"queue_depth":        p.depth,
+			"avg_latency_ms":     p.latency.Milliseconds(),
+			"throughput_per_sec": p.rate,
+			"restarts":           atomic.LoadInt64(&p.restarts),
+			"per_worker":         workers,
+		}
+		if !p.scaledAt.IsZero() {
+			stats["last_scaled_at"] = p.scaledAt
+			stats["last_scale"] = p.lastScale
+		}
+		total += len(p.active)
+		p.mu.Unlock()
+		pools[p.Name] = stats
+	}
+
+	return map[string]interface{}{
+		"total":              total,
+		"scale_interval":     s.interval.String(),
+		"backlog_per_worker": s.backlogPerWorker,
+		"max_latency":        s.maxLatency.String(),
+		"pools":              pools,
+	}
+}
@@ -0,0 +1,209 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// CommandExchange recebe os comandos de envio publicados por outros sistemas
+	CommandExchange = "notifications.commands"
+	CommandQueue    = "notifications.commands"
+	// CommandDeadLetterQueue guarda os comandos inválidos, separados da DLQ de notificações
+	CommandDeadLetterExchange = "notifications.commands.dlx"
+	CommandDeadLetterQueue    = "notifications.commands.dlq"
+	// EventExchange recebe as respostas (status) dos comandos processados
+	EventExchange = "notifications.events"
+
+	// commandRetryDelay é a pausa antes de devolver à fila um comando que falhou por um
+	// erro temporário (ex: banco indisponível)
+	commandRetryDelay = 5 * time.Second
+)
+
+// ErrInvalidCommand indica que o comando não pode ser processado (corpo malformado, versão
+// desconhecida ou campos inválidos). O comando vai para a DLQ de comandos sem nova tentativa.
+var ErrInvalidCommand = errors.New("invalid command")
+
+// CommandDelivery é um comando recebido da exchange de comandos
+type CommandDelivery struct {
+	Body          []byte
+	MessageID     string
+	CorrelationID string
+	ReplyTo       string
+	RoutingKey    string
+}
+
+// declareCommandTopology declara a exchange e a fila de comandos, a DLQ de comandos inválidos
+// e a exchange de eventos
+func (r *RabbitMQClient) declareCommandTopology(channel *amqp.Channel) error {
+	for _, exchange := range []struct{ name, kind string }{
+		{CommandExchange, "topic"},
+		{CommandDeadLetterExchange, "fanout"},
+		{EventExchange, "topic"},
+	} {
+		if err := channel.ExchangeDeclare(exchange.name, exchange.kind, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("failed to declare exchange %s: %w", exchange.name, err)
+		}
+	}
+
+	_, err := channel.QueueDeclare(
+		CommandDeadLetterQueue, // name
+		true,                   // durable
+		false,                  // delete when unused
+		false,                  // exclusive
+		false,                  // no-wait
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", CommandDeadLetterQueue, err)
+	}
+	if err := channel.QueueBind(CommandDeadLetterQueue, "", CommandDeadLetterExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind queue %s: %w", CommandDeadLetterQueue, err)
+	}
+
+	_, err = channel.QueueDeclare(
+		CommandQueue, // name
+		true,         // durable
+		false,        // delete when unused
+		false,        // exclusive
+		false,        // no-wait
+		amqp.Table{
+			"x-dead-letter-exchange": CommandDeadLetterExchange,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", CommandQueue, err)
+	}
+	// Todas as routing keys: o tipo do comando vem no corpo
+	if err := channel.QueueBind(CommandQueue, "#", CommandExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind queue %s: %w", CommandQueue, err)
+	}
+	return nil
+}
+
+// ConsumeCommands consome a fila de comandos. Se o handler retornar nil o comando é
+// confirmado; se retornar ErrInvalidCommand, vai para a DLQ de comandos; outros erros
+// devolvem o comando para a fila após commandRetryDelay. Retorna quando o contexto é
+// cancelado ou o cliente é fechado.
+func (r *RabbitMQClient) ConsumeCommands(ctx context.Context, handler func(*CommandDelivery) error) error {
+	for ctx.Err() == nil {
+		if _, err := r.waitConnection(); err != nil {
+			if errors.Is(err, ErrClientClosed) {
+				return nil
+			}
+			return err
+		}
+
+		if err := r.consumeCommands(ctx, handler); err != nil {
+			if errors.Is(err, ErrClientClosed) || ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("⚠️ Command consumer stopped: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.closing:
+			return nil
+		case <-time.After(reconnectInitialBackoff):
+		}
+		log.Printf("🔄 Restarting consumer on %s...", CommandQueue)
+	}
+	return nil
+}
+
+func (r *RabbitMQClient) consumeCommands(ctx context.Context, handler func(*CommandDelivery) error) error {
+	channel, err := r.openChannel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	if err := channel.Qos(defaultPrefetch, 0, false); err != nil {
+		log.Printf("Warning: Failed to set QoS: %v", err)
+	}
+
+	msgs, err := channel.Consume(CommandQueue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to register consumer: %w", err)
+	}
+
+	atomic.AddInt32(&r.activeConsumers, 1)
+	defer atomic.AddInt32(&r.activeConsumers, -1)
+
+	log.Printf("🔄 Consumer started on %s, waiting for commands...", CommandQueue)
+
+	for {
+		var msg amqp.Delivery
+		var ok bool
+		select {
+		case <-ctx.Done():
+			log.Printf("🛑 Consumer on %s stopped taking messages", CommandQueue)
+			return ctx.Err()
+		case msg, ok = <-msgs:
+		}
+		if !ok {
+			break
+		}
+
+		err := handler(&CommandDelivery{
+			Body:          msg.Body,
+			MessageID:     msg.MessageId,
+			CorrelationID: msg.CorrelationId,
+			ReplyTo:       msg.ReplyTo,
+			RoutingKey:    msg.RoutingKey,
+		})
+		switch {
+		case err == nil:
+			msg.Ack(false)
+		case errors.Is(err, ErrInvalidCommand):
+			log.Printf("💀 Command %s sent to %s: %v", msg.MessageId, CommandDeadLetterQueue, err)
+			msg.Nack(false, false)
+		default:
+			// Erro temporário: pausar o consumer em vez de reprocessar o comando em loop
+			log.Printf("❌ Failed to process command %s, retrying in %s: %v", msg.MessageId, commandRetryDelay, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(commandRetryDelay):
+			}
+			msg.Nack(false, true)
+		}
+	}
+
+	select {
+	case <-r.closing:
+		return ErrClientClosed
+	default:
+	}
+	return errors.New("delivery channel closed")
+}
+
+// PublishEvent publica um evento na exchange de eventos e, se replyTo estiver preenchido,
+// também na fila de resposta indicada pelo remetente do comando. Eventos sem fila
+// interessada não são erro.
+func (r *RabbitMQClient) PublishEvent(routingKey, correlationID, replyTo string, body []byte) error {
+	msg := amqp.Publishing{
+		DeliveryMode:  amqp.Persistent,
+		ContentType:   "application/json",
+		Body:          body,
+		Timestamp:     time.Now(),
+		CorrelationId: correlationID,
+	}
+
+	if err := r.publish(EventExchange, routingKey, msg); err != nil && !errors.Is(err, ErrPublishReturned) {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	if replyTo != "" {
+		if err := r.publish("", replyTo, msg); err != nil {
+			return fmt.Errorf("failed to publish reply to %s: %w", replyTo, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package queue
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/google/uuid"
+)
+
+const (
+	deadLetterQueue = "notifications.dlq"
+
+	// maxDeadLetterPeek limita quantas mensagens são lidas da DLQ em uma consulta
+	maxDeadLetterPeek = 500
+)
+
+// DeathRecord é uma entrada do header x-death adicionado pelo broker a cada dead-letter
+type DeathRecord struct {
+	Queue    string     `json:"queue"`
+	Reason   string     `json:"reason"` // rejected, expired ou maxlen
+	Count    int64      `json:"count"`
+	Exchange string     `json:"exchange,omitempty"`
+	Time     *time.Time `json:"time,omitempty"`
+}
+
+// NotificationSummary resume a notificação contida na mensagem
+type NotificationSummary struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	Type        string     `json:"type"`
+	Purpose     string     `json:"purpose,omitempty"`
+	RecipientID *uuid.UUID `json:"recipient_id,omitempty"`
+	Broadcast   bool       `json:"broadcast"`
+	Priority    string     `json:"priority"`
+	Channel     string     `json:"channel,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// DeadLetter é uma mensagem da DLQ com o motivo do dead-letter
+type DeadLetter struct {
+	MessageID    string               `json:"message_id"`
+	Reason       string               `json:"reason,omitempty"`
+	OriginQueue  string               `json:"origin_queue,omitempty"`
+	RetryCount   int                  `json:"retry_count"`
+	PublishedAt  *time.Time           `json:"published_at,omitempty"`
+	Deaths       []DeathRecord        `json:"deaths"`
+	Notification *NotificationSummary `json:"notification,omitempty"`
+	// Error descreve por que o corpo não pôde ser lido como notificação
+	Error string `json:"error,omitempty"`
+}
+
+// validateDeadLetterPage recusa páginas vazias, offsets negativos e leituras além de maxDeadLetterPeek
+func validateDeadLetterPage(offset, limit int) error {
+	switch {
+	case limit <= 0:
+		return fmt.Errorf("%w: limit must be greater than zero", ErrInvalidDeadLetterPage)
+	case offset < 0:
+		return fmt.Errorf("%w: offset must not be negative", ErrInvalidDeadLetterPage)
+	case offset+limit > maxDeadLetterPeek:
+		return fmt.Errorf("%w: offset + limit must not exceed %d", ErrInvalidDeadLetterPage, maxDeadLetterPeek)
+	}
+	return nil
+}
+
+// PeekDeadLetters lê mensagens da DLQ sem removê-las. As mensagens são obtidas sem ack
+// e devolvidas à fila quando o canal é fechado.
+func (r *RabbitMQClient) PeekDeadLetters(offset, limit int) ([]DeadLetter, error) {
+	if err := validateDeadLetterPage(offset, limit); err != nil {
+		return nil, err
+	}
+
+	channel, err := r.openChannel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DLQ: %w", err)
+	}
+	defer channel.Close()
+
+	letters := []DeadLetter{}
+	for i := 0; i < offset+limit; i++ {
+		msg, ok, err := channel.Get(deadLetterQueue, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read DLQ: %w", err)
+		}
+		if !ok {
+			break
+		}
+		if i >= offset {
+			letters = append(letters, r.deadLetter(msg))
+		}
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetters devolve as mensagens para a fila de origem (canal da etapa ou prioridade
+// da notificação) com a contagem de tentativas zerada. Sem IDs, todas as mensagens presentes na DLQ são reprocessadas.
+func (r *RabbitMQClient) ReplayDeadLetters(messageIDs []string) ([]string, error) {
+	return r.drainDeadLetters(messageIDs, func(msg amqp.Delivery) error {
+		headers := amqp.Table{}
+		for k, v := range msg.Headers {
+			if k == RetryCountHeader || k == "x-death" {
+				continue
+			}
+			headers[k] = v
+		}
+		return r.publish(
+			"",                      // exchange
+			r.replayQueue(msg.Body), // routing key
+			amqp.Publishing{
+				Headers:      headers,
+				DeliveryMode: amqp.Persistent,
+				ContentType:  msg.ContentType,
+				Body:         msg.Body,
+				Timestamp:    time.Now(),
+				MessageId:    msg.MessageId,
+				Priority:     msg.Priority,
+			},
+		)
+	})
+}
+
+// DiscardDeadLetters remove as mensagens da DLQ. Sem IDs, a DLQ é esvaziada.
+func (r *RabbitMQClient) DiscardDeadLetters(messageIDs []string) ([]string, error) {
+	return r.drainDeadLetters(messageIDs, func(amqp.Delivery) error { return nil })
+}
+
+// drainDeadLetters percorre a DLQ uma única vez (até a quantidade de mensagens presente no
+// início), aplica a ação às mensagens selecionadas e as remove. As demais voltam para a fila
+// quando o canal é fechado. Retorna os IDs das mensagens processadas.
+func (r *RabbitMQClient) drainDeadLetters(messageIDs []string, action func(amqp.Delivery) error) ([]string, error) {
+	channel, err := r.openChannel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DLQ: %w", err)
+	}
+	defer channel.Close()
+
+	dlq, err := channel.QueueDeclarePassive(deadLetterQueue, true, false, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DLQ: %w", err)
+	}
+
+	selected := make(map[string]bool, len(messageIDs))
+	for _, id := range messageIDs {
+		selected[id] = true
+	}
+
+	processed := []string{}
+	for i := 0; i < dlq.Messages; i++ {
+		if len(selected) > 0 && len(processed) == len(selected) {
+			break
+		}
+
+		msg, ok, err := channel.Get(deadLetterQueue, false)
+		if err != nil {
+			return processed, fmt.Errorf("failed to read DLQ: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		id := deadLetterID(msg)
+		if len(selected) > 0 && !selected[id] {
+			continue
+		}
+
+		if err := action(msg); err != nil {
+			return processed, fmt.Errorf("failed to process dead letter %s: %w", id, err)
+		}
+		if err := msg.Ack(false); err != nil {
+			return processed, fmt.Errorf("failed to remove dead letter %s: %w", id, err)
+		}
+		processed = append(processed, id)
+	}
+
+	log.Printf("🧹 %d message(s) removed from DLQ", len(processed))
+	return processed, nil
+}
+
+func (r *RabbitMQClient) deadLetter(msg amqp.Delivery) DeadLetter {
+	letter := DeadLetter{
+		MessageID:  deadLetterID(msg),
+		RetryCount: retryCount(msg.Headers),
+		Deaths:     deathRecords(msg.Headers),
+	}
+	if !msg.Timestamp.IsZero() {
+		published := msg.Timestamp
+		letter.PublishedAt = &published
+	}
+
+	// Entradas de x-death vêm da mais recente para a mais antiga; as expirações das
+	// filas de retry fazem parte do fluxo normal e não explicam o dead-letter
+	for _, death := range letter.Deaths {
+		if r.isWorkQueue(death.Queue) || letter.Reason == "" {
+			letter.Reason = death.Reason
+			letter.OriginQueue = death.Queue
+		}
+		if r.isWorkQueue(death.Queue) {
+			break
+		}
+	}
+
+	letter.Notification = notificationSummary(msg.Body)
+	if letter.Notification == nil {
+		letter.Error = "message body is not a notification"
+	}
+	return letter
+}
+
+// notificationSummary lê a notificação do corpo da mensagem; nil se o corpo for inválido.
+// Mensagens da versão 2 trazem apenas ID, tipo e prioridade.
+func notificationSummary(body []byte) *NotificationSummary {
+	message, err := decodeMessage(body)
+	if err != nil {
+		return nil
+	}
+	if message.Notification == nil {
+		return &NotificationSummary{
+			ID:       message.NotificationID,
+			Type:     string(message.Type),
+			Priority: string(message.Priority),
+			Channel:  string(message.Channel),
+		}
+	}
+	n := message.Notification
+	return &NotificationSummary{
+		ID:          n.ID,
+		Title:       n.Title,
+		Type:        string(n.Type),
+		Purpose:     n.Purpose,
+		RecipientID: n.RecipientID,
+		Broadcast:   n.Broadcast,
+		Priority:    string(n.Priority),
+		CreatedAt:   n.CreatedAt,
+	}
+}
+
+// replayQueue escolhe a fila do canal da etapa ou, para mensagens de notificação, a fila de
+// prioridade; corpos inválidos vão para a fila normal, onde serão rejeitados pelo consumer
+func (q queueSettings) replayQueue(body []byte) string {
+	summary := notificationSummary(body)
+	if summary == nil {
+		return q.laneQueue(entity.PriorityNormal)
+	}
+	if summary.Channel != "" {
+		return q.channelQueue(entity.DeliveryChannel(summary.Channel))
+	}
+	return q.laneQueue(entity.NotificationPriority(summary.Priority))
+}
+
+// deadLetterID identifica a mensagem: o MessageId (ID da notificação) ou, na falta dele,
+// o hash do corpo
+func deadLetterID(msg amqp.Delivery) string {
+	if msg.MessageId != "" {
+		return msg.MessageId
+	}
+	sum := sha256.Sum256(msg.Body)
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+func deathRecords(headers amqp.Table) []DeathRecord {
+	records := []DeathRecord{}
+	deaths, _ := headers["x-death"].([]interface{})
+	for _, d := range deaths {
+		table, ok := d.(amqp.Table)
+		if !ok {
+			continue
+		}
+		record := DeathRecord{}
+		record.Queue, _ = table["queue"].(string)
+		record.Reason, _ = table["reason"].(string)
+		record.Exchange, _ = table["exchange"].(string)
+		record.Count, _ = table["count"].(int64)
+		if t, ok := table["time"].(time.Time); ok {
+			record.Time = &t
+		}
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,743 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/config"
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPollInterval = 1 * time.Second
+	defaultLockTimeout  = 5 * time.Minute
+
+	// messageTTL equivale ao x-message-ttl das filas do RabbitMQ
+	messageTTL = time.Hour
+	// depthCacheTTL é a validade da profundidade das filas usada no limite de publicação
+	depthCacheTTL = 2 * time.Second
+)
+
+// PostgresMessage é uma mensagem da fila no backend postgres. As prioridades, os canais e a
+// DLQ são valores da coluna queue, com os mesmos nomes das filas do RabbitMQ; mensagens
+// aguardando retry ficam na fila de origem com available_at no futuro. Priority ordena as
+// filas de canal, como o x-max-priority do RabbitMQ.
+type PostgresMessage struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
+	MessageID   string    `gorm:"not null;index"`
+	Queue       string    `gorm:"not null;index:idx_queue_messages_claim,priority:1"`
+	Body        []byte    `gorm:"not null"`
+	Priority    int       `gorm:"not null;default:0"`
+	RetryCount  int       `gorm:"not null;default:0"`
+	AvailableAt time.Time `gorm:"not null;index:idx_queue_messages_claim,priority:2"`
+	// LockedUntil marca a mensagem como em processamento; vencido o prazo, ela é entregue de novo
+	LockedUntil *time.Time
+	// LockedBy identifica a reserva: ack, retry e DLQ só valem para quem ainda a detém
+	LockedBy *string
+	// EnqueuedAt é a entrada na fila atual, base do TTL
+	EnqueuedAt     time.Time `gorm:"not null"`
+	Reason         string
+	OriginQueue    string
+	LastError      string
+	DeadLetteredAt *time.Time
+	CreatedAt      time.Time
+}
+
+func (PostgresMessage) TableName() string {
+	return "queue_messages"
+}
+
+// PostgresQueue implementa a fila sobre uma tabela do próprio banco da aplicação. Cada
+// worker reserva uma mensagem por vez com SELECT ... FOR UPDATE SKIP LOCKED, de modo que
+// vários workers e instâncias consomem a mesma fila sem entregar a mensagem duas vezes.
+type PostgresQueue struct {
+	queueSettings
+	db        *gorm.DB
+	startedAt time.Time
+
+	// wake é fechado a cada publicação para acordar os consumers desta instância; as
+	// demais instâncias percebem a mensagem no próximo QUEUE_POLL_INTERVAL
+	mu   sync.Mutex
+	wake chan struct{}
+
+	// depths guarda a profundidade de cada fila, recontada no máximo a cada depthCacheTTL e
+	// incrementada a cada publicação desta instância
+	depthMu        sync.Mutex
+	depths         map[string]int64
+	depthCheckedAt time.Time
+
+	activeConsumers int32
+	queueConsumers  map[string]*int32
+
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+func NewPostgresQueue(cfg *config.Config, db *gorm.DB) (*PostgresQueue, error) {
+	if err := db.AutoMigrate(&PostgresMessage{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate queue table: %w", err)
+	}
+
+	p := &PostgresQueue{
+		queueSettings:  queueSettings{config: cfg},
+		db:             db,
+		startedAt:      time.Now(),
+		wake:           make(chan struct{}),
+		queueConsumers: map[string]*int32{},
+		closing:        make(chan struct{}),
+	}
+	for _, queue := range p.workQueues() {
+		p.queueConsumers[queue] = new(int32)
+	}
+
+	log.Printf("✅ Postgres queue ready (table %s)", PostgresMessage{}.TableName())
+	return p, nil
+}
+
+// Backend identifica a implementação da fila
+func (p *PostgresQueue) Backend() string {
+	return BackendPostgres
+}
+
+func (p *PostgresQueue) pollInterval() time.Duration {
+	if p.config.Queue.PollInterval <= 0 {
+		return defaultPollInterval
+	}
+	return p.config.Queue.PollInterval
+}
+
+func (p *PostgresQueue) lockTimeout() time.Duration {
+	if p.config.Queue.LockTimeout <= 0 {
+		return defaultLockTimeout
+	}
+	return p.config.Queue.LockTimeout
+}
+
+func (p *PostgresQueue) closed() bool {
+	select {
+	case <-p.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *PostgresQueue) wakeChannel() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.wake
+}
+
+func (p *PostgresQueue) notify() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	close(p.wake)
+	p.wake = make(chan struct{})
+}
+
+// PublishNotification grava a notificação na fila da sua prioridade
+func (p *PostgresQueue) PublishNotification(notification *entity.Notification) error {
+	if p.closed() {
+		return ErrClientClosed
+	}
+
+	body, err := json.Marshal(newNotificationMessage(notification))
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	queue := p.laneQueue(notification.Priority)
+	if err := p.checkCapacity(queue); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	msg := &PostgresMessage{
+		ID:          uuid.New(),
+		MessageID:   notification.ID.String(),
+		Queue:       queue,
+		Body:        body,
+		AvailableAt: now,
+		EnqueuedAt:  now,
+	}
+	if err := p.db.Create(msg).Error; err != nil {
+		return fmt.Errorf("failed to publish message: %w (%v)", ErrPublishFailed, err)
+	}
+	p.countPublished(queue)
+	p.notify()
+
+	log.Printf("📤 Notification %s published to %s", notification.ID, msg.Queue)
+	return nil
+}
+
+// PublishLeg grava a etapa de um canal na fila do canal, com a prioridade da notificação
+func (p *PostgresQueue) PublishLeg(notification *entity.Notification, channel entity.DeliveryChannel) error {
+	if p.closed() {
+		return ErrClientClosed
+	}
+
+	body, err := json.Marshal(newLegMessage(notification, channel))
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	queue := p.channelQueue(channel)
+	if err := p.checkCapacity(queue); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	msg := &PostgresMessage{
+		ID:          uuid.New(),
+		MessageID:   legMessageID(notification, channel),
+		Queue:       queue,
+		Body:        body,
+		Priority:    int(messagePriority(notification.Priority)),
+		AvailableAt: now,
+		EnqueuedAt:  now,
+	}
+	if err := p.db.Create(msg).Error; err != nil {
+		return fmt.Errorf("failed to publish message: %w (%v)", ErrPublishFailed, err)
+	}
+	p.countPublished(queue)
+	p.notify()
+
+	log.Printf("📤 Notification %s %s leg published to %s", notification.ID, channel, msg.Queue)
+	return nil
+}
+
+// checkCapacity recusa a publicação quando a fila atingiu o limite de mensagens, como o
+// x-overflow reject-publish do RabbitMQ. A profundidade vem do cache, recontado no máximo a
+// cada depthCacheTTL em vez de um COUNT por publicação; publicações de outras instâncias
+// entre duas contagens podem ultrapassar o limite por pouco.
+func (p *PostgresQueue) checkCapacity(queue string) error {
+	p.depthMu.Lock()
+	defer p.depthMu.Unlock()
+	if p.depths == nil || time.Since(p.depthCheckedAt) >= depthCacheTTL {
+		if err := p.refreshDepths(); err != nil {
+			return fmt.Errorf("failed to publish message: %w (%v)", ErrPublishFailed, err)
+		}
+	}
+	if count := p.depths[queue]; count >= int64(p.maxLength()) {
+		return fmt.Errorf("failed to publish message: %w: %s has %d message(s)", ErrQueueSaturated, queue, count)
+	}
+	return nil
+}
+
+// countPublished soma a mensagem publicada à profundidade em cache
+func (p *PostgresQueue) countPublished(queue string) {
+	p.depthMu.Lock()
+	defer p.depthMu.Unlock()
+	if p.depths != nil {
+		p.depths[queue]++
+	}
+}
+
+// refreshDepths reconta as filas de prioridade e de canal; chamado com depthMu travado
+func (p *PostgresQueue) refreshDepths() error {
+	var rows []struct {
+		Queue    string
+		Messages int64
+	}
+	err := p.db.Model(&PostgresMessage{}).
+		Select("queue, COUNT(*) AS messages").
+		Where("queue IN ?", p.workQueues()).
+		Group("queue").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	depths := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		depths[row.Queue] = row.Messages
+	}
+	p.depths = depths
+	p.depthCheckedAt = time.Now()
+	return nil
+}
+
+// Saturated indica se alguma fila de prioridade ou de canal passou da marca de saturação
+func (p *PostgresQueue) Saturated() (bool, error) {
+	p.depthMu.Lock()
+	defer p.depthMu.Unlock()
+	if err := p.refreshDepths(); err != nil {
+		return false, fmt.Errorf("failed to check queue depth: %w", err)
+	}
+	for _, depth := range p.depths {
+		if depth >= int64(p.highWater()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Depths retorna as mensagens prontas (fora de retry e não reservadas) de cada fila de
+// prioridade e de canal
+func (p *PostgresQueue) Depths() (map[string]int, error) {
+	now := time.Now()
+	var ready []struct {
+		Queue    string
+		Messages int
+	}
+	err := p.db.Model(&PostgresMessage{}).
+		Select("queue, COUNT(*) AS messages").
+		Where("queue IN ? AND available_at <= ? AND (locked_until IS NULL OR locked_until < ?)", p.workQueues(), now, now).
+		Group("queue").
+		Scan(&ready).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to check queue depth: %w", err)
+	}
+	readyByQueue := map[string]int{}
+	for _, row := range ready {
+		readyByQueue[row.Queue] = row.Messages
+	}
+
+	depths := map[string]int{}
+	for _, priority := range entity.Priorities {
+		depths[string(priority)] = readyByQueue[p.laneQueue(priority)]
+	}
+	for _, channel := range entity.DeliveryChannels {
+		depths[string(channel)] = readyByQueue[p.channelQueue(channel)]
+	}
+	return depths, nil
+}
+
+// ConsumeNotifications processa as mensagens da fila da prioridade, uma por vez. Com a fila
+// vazia, aguarda uma nova publicação ou o intervalo de consulta. Retorna quando o contexto é
+// cancelado ou o cliente é fechado.
+func (p *PostgresQueue) ConsumeNotifications(ctx context.Context, priority entity.NotificationPriority, handler func(*NotificationMessage) error) error {
+	return p.consumeQueue(ctx, p.laneQueue(priority), handler)
+}
+
+// ConsumeChannel processa as etapas da fila do canal, como ConsumeNotifications
+func (p *PostgresQueue) ConsumeChannel(ctx context.Context, channel entity.DeliveryChannel, handler func(*NotificationMessage) error) error {
+	return p.consumeQueue(ctx, p.channelQueue(channel), handler)
+}
+
+func (p *PostgresQueue) consumeQueue(ctx context.Context, queue string, handler func(*NotificationMessage) error) error {
+	atomic.AddInt32(&p.activeConsumers, 1)
+	defer atomic.AddInt32(&p.activeConsumers, -1)
+	atomic.AddInt32(p.queueConsumers[queue], 1)
+	defer atomic.AddInt32(p.queueConsumers[queue], -1)
+
+	log.Printf("🔄 Consumer started on %s, waiting for messages...", queue)
+
+	for !p.closed() && ctx.Err() == nil {
+		// Obter o sinal antes da consulta para não perder uma publicação feita durante ela
+		wake := p.wakeChannel()
+
+		msg, err := p.claim(queue)
+		if err != nil {
+			log.Printf("⚠️ Failed to read queue %s: %v", queue, err)
+		}
+		if msg != nil {
+			p.handle(msg, handler)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-p.closing:
+		case <-wake:
+		case <-time.After(p.pollInterval()):
+		}
+	}
+	if ctx.Err() != nil {
+		log.Printf("🛑 Consumer on %s stopped taking messages", queue)
+	}
+	return nil
+}
+
+// claim reserva a próxima mensagem disponível da fila por LockTimeout
+func (p *PostgresQueue) claim(queue string) (*PostgresMessage, error) {
+	var claimed *PostgresMessage
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var messages []PostgresMessage
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("queue = ? AND available_at <= ? AND (locked_until IS NULL OR locked_until < ?)", queue, now, now).
+			Order("priority DESC, available_at ASC").
+			Limit(1).
+			Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		lockedUntil := now.Add(p.lockTimeout())
+		lockedBy := uuid.NewString()
+		err = tx.Model(&PostgresMessage{}).Where("id = ?", messages[0].ID).Updates(map[string]any{
+			"locked_until": lockedUntil,
+			"locked_by":    lockedBy,
+		}).Error
+		if err != nil {
+			return err
+		}
+		messages[0].LockedUntil = &lockedUntil
+		messages[0].LockedBy = &lockedBy
+		claimed = &messages[0]
+		return nil
+	})
+	return claimed, err
+}
+
+// handle entrega a mensagem ao handler e confirma, agenda o retry ou envia para a DLQ
+func (p *PostgresQueue) handle(msg *PostgresMessage, handler func(*NotificationMessage) error) {
+	if time.Since(msg.EnqueuedAt) > messageTTL {
+		p.deadLetter(msg, "expired", "")
+		log.Printf("💀 Message %s expired in %s", msg.MessageID, msg.Queue)
+		return
+	}
+
+	decoded, err := decodeMessage(msg.Body)
+	if err != nil {
+		log.Printf("❌ Failed to unmarshal message: %v", err)
+		p.deadLetter(msg, "rejected", "message body is not a notification")
+		return
+	}
+	notifMsg := decoded.NotificationMessage
+	notifMsg.RetryCount = msg.RetryCount
+	notifMsg.FinalAttempt = msg.RetryCount+1 >= p.retryPolicy(msg.Queue).maxAttempts
+
+	log.Printf("📥 Processing notification %s (retry: %d)", notifMsg.NotificationID, notifMsg.RetryCount)
+
+	if err := handler(&notifMsg); err != nil {
+		log.Printf("❌ Failed to process notification %s: %v", notifMsg.NotificationID, err)
+		p.retry(msg, err)
+		return
+	}
+
+	result := p.owned(msg).Delete(&PostgresMessage{})
+	if result.Error != nil {
+		// A mensagem volta a ser entregue quando a reserva vencer
+		log.Printf("⚠️ Failed to ack message %s: %v", msg.MessageID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		p.lockLost(msg, "ack")
+		return
+	}
+	log.Printf("✅ Notification %s processed successfully", notifMsg.NotificationID)
+}
+
+// retry devolve a mensagem para a fila de origem após o atraso do nível correspondente.
+// Esgotadas as tentativas, envia para a DLQ.
+func (p *PostgresQueue) retry(msg *PostgresMessage, cause error) {
+	policy := p.retryPolicy(msg.Queue)
+	attempt := msg.RetryCount + 1
+	if attempt >= policy.maxAttempts {
+		p.deadLetter(msg, "rejected", cause.Error())
+		log.Printf("💀 Message %s sent to DLQ after %d attempt(s)", msg.MessageID, attempt)
+		return
+	}
+
+	delay := policy.delay(msg.RetryCount)
+	availableAt := time.Now().Add(delay)
+	result := p.owned(msg).Model(&PostgresMessage{}).Updates(map[string]any{
+		"retry_count":  attempt,
+		"available_at": availableAt,
+		"enqueued_at":  availableAt,
+		"locked_until": nil,
+		"locked_by":    nil,
+		"last_error":   cause.Error(),
+	})
+	if result.Error != nil {
+		// Sem agendar o retry, a mensagem volta a ser entregue quando a reserva vencer
+		log.Printf("❌ Failed to schedule retry for message %s: %v", msg.MessageID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		p.lockLost(msg, "retry")
+		return
+	}
+
+	log.Printf("🔄 Message %s scheduled for retry %d/%d in %s", msg.MessageID, attempt, policy.maxAttempts-1, delay)
+}
+
+// deadLetter move a mensagem para a DLQ guardando a fila de origem e o motivo
+func (p *PostgresQueue) deadLetter(msg *PostgresMessage, reason, detail string) {
+	updates := map[string]any{
+		"queue":            deadLetterQueue,
+		"reason":           reason,
+		"origin_queue":     msg.Queue,
+		"dead_lettered_at": time.Now(),
+		"locked_until":     nil,
+		"locked_by":        nil,
+	}
+	if detail != "" {
+		updates["last_error"] = detail
+	}
+	result := p.owned(msg).Model(&PostgresMessage{}).Updates(updates)
+	if result.Error != nil {
+		log.Printf("❌ Failed to dead-letter message %s: %v", msg.MessageID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		p.lockLost(msg, "dead-letter")
+	}
+}
+
+// owned filtra a mensagem pela reserva desta entrega: vencido o LockTimeout, outro consumer
+// pode tê-la reservado, e só ele pode confirmá-la, agendar o retry ou enviá-la para a DLQ
+func (p *PostgresQueue) owned(msg *PostgresMessage) *gorm.DB {
+	return p.db.Where("id = ? AND locked_by = ?", msg.ID, *msg.LockedBy)
+}
+
+func (p *PostgresQueue) lockLost(msg *PostgresMessage, action string) {
+	log.Printf("⚠️ Message %s: %s skipped, its lock expired and it was claimed again", msg.MessageID, action)
+}
+
+// Status indica se o banco responde e quantos consumers estão ativos
+func (p *PostgresQueue) Status() ConnectionStatus {
+	status := ConnectionStatus{
+		State:           StateConnected,
+		ActiveConsumers: atomic.LoadInt32(&p.activeConsumers),
+	}
+	if p.closed() {
+		status.State = StateClosed
+		return status
+	}
+
+	sqlDB, err := p.db.DB()
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		status.State = StateReconnecting
+		status.LastError = err.Error()
+		return status
+	}
+
+	startedAt := p.startedAt
+	status.ConnectedAt = &startedAt
+	return status
+}
+
+// GetQueueStats retorna estatísticas da fila, no mesmo formato do RabbitMQ
+func (p *PostgresQueue) GetQueueStats() (map[string]interface{}, error) {
+	now := time.Now()
+
+	// Mensagens prontas para entrega (fora de retry e não reservadas), por fila
+	var ready []struct {
+		Queue    string
+		Messages int
+	}
+	err := p.db.Model(&PostgresMessage{}).
+		Select("queue, COUNT(*) AS messages").
+		Where("queue IN ? AND available_at <= ? AND (locked_until IS NULL OR locked_until < ?)", p.workQueues(), now, now).
+		Group("queue").
+		Scan(&ready).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue stats: %w", err)
+	}
+	readyByQueue := map[string]int{}
+	for _, row := range ready {
+		readyByQueue[row.Queue] = row.Messages
+	}
+
+	lanes := map[string]interface{}{}
+	messages, consumers := 0, 0
+	for _, priority := range entity.Priorities {
+		lane := p.laneQueue(priority)
+		laneConsumers := int(atomic.LoadInt32(p.queueConsumers[lane]))
+		lanes[string(priority)] = map[string]interface{}{
+			"queue_name": lane,
+			"messages":   readyByQueue[lane],
+			"consumers":  laneConsumers,
+		}
+		messages += readyByQueue[lane]
+		consumers += laneConsumers
+	}
+
+	channels := map[string]interface{}{}
+	for _, channel := range entity.DeliveryChannels {
+		queue := p.channelQueue(channel)
+		channels[string(channel)] = map[string]interface{}{
+			"queue_name":   queue,
+			"messages":     readyByQueue[queue],
+			"consumers":    int(atomic.LoadInt32(p.queueConsumers[queue])),
+			"prefetch":     p.prefetch(queue),
+			"max_attempts": p.retryPolicy(queue).maxAttempts,
+		}
+	}
+
+	var dlqMessages int64
+	if err := p.db.Model(&PostgresMessage{}).Where("queue = ?", deadLetterQueue).Count(&dlqMessages).Error; err != nil {
+		return nil, fmt.Errorf("failed to get queue stats: %w", err)
+	}
+
+	// Mensagens aguardando nova tentativa, pelo atraso do nível em que estão
+	var waiting []struct {
+		Queue      string
+		RetryCount int
+		Messages   int
+	}
+	err = p.db.Model(&PostgresMessage{}).
+		Select("queue, retry_count, COUNT(*) AS messages").
+		Where("queue IN ? AND available_at > ?", p.workQueues(), now).
+		Group("queue, retry_count").
+		Scan(&waiting).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue stats: %w", err)
+	}
+	retryMessages := map[string]int{}
+	for _, delay := range p.retryDelays() {
+		retryMessages[delay.String()] = 0
+	}
+	for _, row := range waiting {
+		level := row.RetryCount - 1
+		if level < 0 {
+			level = 0
+		}
+		retryMessages[p.retryPolicy(row.Queue).delay(level).String()] += row.Messages
+	}
+
+	stats := map[string]interface{}{
+		"backend":        BackendPostgres,
+		"queue_name":     p.config.RabbitMQ.QueueNotifications,
+		"messages":       messages,
+		"consumers":      consumers,
+		"lanes":          lanes,
+		"channels":       channels,
+		"dlq_messages":   int(dlqMessages),
+		"retry_messages": retryMessages,
+		"max_attempts":   p.retryPolicy(p.laneQueue(entity.PriorityNormal)).maxAttempts,
+		"max_length":     p.maxLength(),
+		"connection":     p.Status(),
+		"last_checked":   now,
+	}
+
+	return stats, nil
+}
+
+// PurgeQueue remove as mensagens prontas para entrega das filas de prioridade e de canal
+func (p *PostgresQueue) PurgeQueue() error {
+	now := time.Now()
+	result := p.db.
+		Where("queue IN ? AND available_at <= ? AND (locked_until IS NULL OR locked_until < ?)", p.workQueues(), now, now).
+		Delete(&PostgresMessage{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to purge queue: %w", result.Error)
+	}
+	log.Printf("🗑️ Queues %v purged (%d message(s))", p.workQueues(), result.RowsAffected)
+	return nil
+}
+
+// PeekDeadLetters lista as mensagens da DLQ, das mais antigas para as mais recentes
+func (p *PostgresQueue) PeekDeadLetters(offset, limit int) ([]DeadLetter, error) {
+	if err := validateDeadLetterPage(offset, limit); err != nil {
+		return nil, err
+	}
+
+	var messages []PostgresMessage
+	err := p.db.Where("queue = ?", deadLetterQueue).
+		Order("dead_lettered_at ASC, id ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DLQ: %w", err)
+	}
+
+	letters := make([]DeadLetter, 0, len(messages))
+	for _, msg := range messages {
+		published := msg.CreatedAt
+		letter := DeadLetter{
+			MessageID:   msg.MessageID,
+			Reason:      msg.Reason,
+			OriginQueue: msg.OriginQueue,
+			RetryCount:  msg.RetryCount,
+			PublishedAt: &published,
+			Deaths: []DeathRecord{{
+				Queue:  msg.OriginQueue,
+				Reason: msg.Reason,
+				Count:  1,
+				Time:   msg.DeadLetteredAt,
+			}},
+			Notification: notificationSummary(msg.Body),
+		}
+		if letter.Notification == nil {
+			letter.Error = "message body is not a notification"
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetters devolve as mensagens para a fila de origem (canal da etapa ou prioridade
+// da notificação) com a contagem de tentativas zerada. Sem IDs, todas as mensagens da DLQ são reprocessadas.
+func (p *PostgresQueue) ReplayDeadLetters(messageIDs []string) ([]string, error) {
+	processed, err := p.drainDeadLetters(messageIDs, func(msg *PostgresMessage) error {
+		now := time.Now()
+		return p.db.Model(&PostgresMessage{}).Where("id = ? AND queue = ?", msg.ID, deadLetterQueue).Updates(map[string]any{
+			"queue":            p.replayQueue(msg.Body),
+			"retry_count":      0,
+			"available_at":     now,
+			"enqueued_at":      now,
+			"locked_until":     nil,
+			"locked_by":        nil,
+			"reason":           "",
+			"origin_queue":     "",
+			"last_error":       "",
+			"dead_lettered_at": nil,
+		}).Error
+	})
+	if len(processed) > 0 {
+		p.notify()
+	}
+	return processed, err
+}
+
+// DiscardDeadLetters remove as mensagens da DLQ. Sem IDs, a DLQ é esvaziada.
+func (p *PostgresQueue) DiscardDeadLetters(messageIDs []string) ([]string, error) {
+	return p.drainDeadLetters(messageIDs, func(msg *PostgresMessage) error {
+		return p.db.Delete(&PostgresMessage{}, "id = ? AND queue = ?", msg.ID, deadLetterQueue).Error
+	})
+}
+
+// drainDeadLetters aplica a ação às mensagens selecionadas da DLQ, uma a uma, e retorna
+// os IDs processados
+func (p *PostgresQueue) drainDeadLetters(messageIDs []string, action func(*PostgresMessage) error) ([]string, error) {
+	query := p.db.Where("queue = ?", deadLetterQueue)
+	if len(messageIDs) > 0 {
+		query = query.Where("message_id IN ?", messageIDs)
+	}
+
+	var messages []PostgresMessage
+	if err := query.Order("dead_lettered_at ASC, id ASC").Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("failed to read DLQ: %w", err)
+	}
+
+	processed := []string{}
+	seen := map[string]bool{}
+	for i := range messages {
+		msg := &messages[i]
+		if err := action(msg); err != nil {
+			return processed, fmt.Errorf("failed to process dead letter %s: %w", msg.MessageID, err)
+		}
+		if !seen[msg.MessageID] {
+			seen[msg.MessageID] = true
+			processed = append(processed, msg.MessageID)
+		}
+	}
+
+	log.Printf("🧹 %d message(s) removed from DLQ", len(processed))
+	return processed, nil
+}
+
+// Close encerra os consumers. A conexão com o banco pertence à aplicação e não é fechada.
+func (p *PostgresQueue) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closing)
+	})
+	return nil
+}
@@ -0,0 +1,361 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prefeitura-rio/app-notification-core/internal/config"
+	"github.com/prefeitura-rio/app-notification-core/internal/entity"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	BackendRabbitMQ = "rabbitmq"
+	BackendPostgres = "postgres"
+
+	defaultMaxAttempts = 4
+	defaultPrefetch    = 10
+
+	// maxMessagePriority é o x-max-priority das filas de canal
+	maxMessagePriority = 2
+
+	// defaultMaxLength é o limite de mensagens de cada fila quando RABBITMQ_MAX_LENGTH está vazio
+	defaultMaxLength = 100000
+	// highWaterRatio é a fração do limite a partir da qual a fila é considerada saturada: o
+	// restante fica reservado para as mensagens que voltam das filas de retry
+	highWaterRatio = 0.9
+
+	// MessageVersion é a versão do formato das mensagens publicadas. A versão 1 trazia a
+	// notificação inteira; a 2 traz apenas o ID e os metadados de roteamento.
+	MessageVersion = 2
+)
+
+// defaultRetryDelays são os atrasos usados quando RABBITMQ_RETRY_DELAYS está vazio
+var defaultRetryDelays = []time.Duration{30 * time.Second, 5 * time.Minute, 30 * time.Minute}
+
+var (
+	// ErrNotConnected é retornado enquanto o cliente tenta reconectar ao backend da fila
+	ErrNotConnected = errors.New("queue backend is not connected")
+	// ErrClientClosed é retornado após Close
+	ErrClientClosed = errors.New("queue client is closed")
+
+	// ErrPublishNacked indica que o broker recusou a mensagem (ex: fila cheia ou falha interna)
+	ErrPublishNacked = errors.New("message rejected by broker")
+	// ErrPublishReturned indica que a mensagem não foi roteada para nenhuma fila
+	ErrPublishReturned = errors.New("message returned as unroutable")
+	// ErrPublishTimeout indica que o broker não confirmou a mensagem dentro do prazo
+	ErrPublishTimeout = errors.New("publish confirmation timed out")
+	// ErrPublishFailed indica que o backend não gravou a mensagem
+	ErrPublishFailed = errors.New("queue did not accept the message")
+	// ErrQueueSaturated indica que a fila atingiu o limite de mensagens e recusou a publicação
+	ErrQueueSaturated = errors.New("queue is full")
+
+	// ErrInvalidDeadLetterPage indica limit ou offset fora do intervalo aceito na leitura da DLQ
+	ErrInvalidDeadLetterPage = errors.New("invalid DLQ page")
+)
+
+// IsPublishError indica se o erro foi causado pela fila não aceitar a mensagem,
+// e não por um problema na própria notificação
+func IsPublishError(err error) bool {
+	return errors.Is(err, ErrPublishNacked) ||
+		errors.Is(err, ErrPublishReturned) ||
+		errors.Is(err, ErrPublishTimeout) ||
+		errors.Is(err, ErrPublishFailed) ||
+		errors.Is(err, ErrQueueSaturated) ||
+		errors.Is(err, ErrNotConnected) ||
+		errors.Is(err, ErrClientClosed)
+}
+
+type ConnectionState string
+
+const (
+	StateConnected    ConnectionState = "connected"
+	StateReconnecting ConnectionState = "reconnecting"
+	StateClosed       ConnectionState = "closed"
+)
+
+// ConnectionStatus descreve o estado da conexão, exposto no /health/ready
+type ConnectionStatus struct {
+	State           ConnectionState `json:"state"`
+	ActiveConsumers int32           `json:"active_consumers"`
+	Reconnects      int64           `json:"reconnects"`
+	LastError       string          `json:"last_error,omitempty"`
+	ConnectedAt     *time.Time      `json:"connected_at,omitempty"`
+}
+
+// NotificationMessage referencia a notificação pelo ID. O worker carrega a versão atual do
+// banco, então edições e cancelamentos feitos após a publicação são respeitados. Mensagens
+// das filas de canal trazem também o canal da etapa a entregar.
+type NotificationMessage struct {
+	Version        int                         `json:"version"`
+	NotificationID uuid.UUID                   `json:"notification_id"`
+	Priority       entity.NotificationPriority `json:"priority"`
+	Type           entity.NotificationType     `json:"type"`
+	Channel        entity.DeliveryChannel      `json:"channel,omitempty"`
+	Timestamp      time.Time                   `json:"timestamp"`
+	// RetryCount é mantido fora do corpo (header x-retry-count no RabbitMQ, coluna no
+	// Postgres); o corpo não muda entre tentativas
+	RetryCount int `json:"-"`
+	// FinalAttempt indica que uma falha nesta tentativa esgota o retry e envia a mensagem
+	// para a DLQ
+	FinalAttempt bool `json:"-"`
+}
+
+func newNotificationMessage(notification *entity.Notification) NotificationMessage {
+	return NotificationMessage{
+		Version:        MessageVersion,
+		NotificationID: notification.ID,
+		Priority:       notification.Priority,
+		Type:           notification.Type,
+		Timestamp:      time.Now(),
+	}
+}
+
+func newLegMessage(notification *entity.Notification, channel entity.DeliveryChannel) NotificationMessage {
+	message := newNotificationMessage(notification)
+	message.Channel = channel
+	return message
+}
+
+// legMessageID identifica a mensagem de uma etapa; a mesma notificação tem uma mensagem por canal
+func legMessageID(notification *entity.Notification, channel entity.DeliveryChannel) string {
+	return notification.ID.String() + ":" + string(channel)
+}
+
+// wireMessage é o corpo publicado. Notification só existe em mensagens da versão 1, que
+// ainda podem estar nas filas de retry ou na DLQ.
+type wireMessage struct {
+	NotificationMessage
+	Notification *entity.Notification `json:"notification,omitempty"`
+}
+
+// decodeMessage lê o corpo da mensagem nas versões 1 e 2
+func decodeMessage(body []byte) (*wireMessage, error) {
+	var message wireMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, err
+	}
+	if message.Version < 2 && message.Notification != nil {
+		message.Version = 1
+		message.NotificationID = message.Notification.ID
+		message.Priority = message.Notification.Priority
+		message.Type = message.Notification.Type
+	}
+	if message.NotificationID == uuid.Nil {
+		return nil, errors.New("message does not reference a notification")
+	}
+	return &message, nil
+}
+
+// Queue é a fila de notificações, independente do backend.
+//
+// A entrega tem dois estágios: as filas de prioridade recebem a notificação e seus workers
+// publicam uma mensagem por canal (PublishLeg) nas filas de canal, consumidas por pools
+// próprios. Assim um canal lento não atrasa os demais.
+//
+// ConsumeNotifications e ConsumeChannel entregam cada mensagem ao handler: se ele retornar
+// nil a mensagem é confirmada (ack); se retornar erro, ela volta para a fila após o atraso
+// do nível de retry e, esgotadas as tentativas da fila, vai para a DLQ. Mensagens que não
+// contêm uma notificação vão direto para a DLQ. Cancelado o contexto, o consumer deixa de
+// receber mensagens, conclui a que está processando e retorna.
+//
+// Cada fila aceita até RABBITMQ_MAX_LENGTH mensagens; além disso a publicação é recusada com
+// ErrQueueSaturated. Saturated indica se alguma fila passou de 90% do limite, para que os
+// produtores reduzam o ritmo antes da recusa. Depths retorna as mensagens prontas para
+// entrega de cada fila, pela prioridade (high, normal, low) ou pelo canal (in-app, push,
+// email), usadas para dimensionar os pools de workers.
+type Queue interface {
+	Backend() string
+	PublishNotification(notification *entity.Notification) error
+	PublishLeg(notification *entity.Notification, channel entity.DeliveryChannel) error
+	ConsumeNotifications(ctx context.Context, priority entity.NotificationPriority, handler func(*NotificationMessage) error) error
+	ConsumeChannel(ctx context.Context, channel entity.DeliveryChannel, handler func(*NotificationMessage) error) error
+	Saturated() (bool, error)
+	Depths() (map[string]int, error)
+	Status() ConnectionStatus
+	GetQueueStats() (map[string]interface{}, error)
+	PurgeQueue() error
+	PeekDeadLetters(offset, limit int) ([]DeadLetter, error)
+	ReplayDeadLetters(messageIDs []string) ([]string, error)
+	DiscardDeadLetters(messageIDs []string) ([]string, error)
+	Close() error
+}
+
+// New cria a fila do backend configurado em QUEUE_BACKEND (rabbitmq, o padrão, ou postgres)
+func New(cfg *config.Config, db *gorm.DB) (Queue, error) {
+	switch cfg.Queue.Backend {
+	case "", BackendRabbitMQ:
+		client, err := NewRabbitMQClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case BackendPostgres:
+		client, err := NewPostgresQueue(cfg, db)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+	return nil, fmt.Errorf("unknown queue backend %q", cfg.Queue.Backend)
+}
+
+// queueSettings reúne os nomes das filas e a política de retry, comuns aos backends
+type queueSettings struct {
+	config *config.Config
+}
+
+// laneQueue retorna a fila da prioridade. A prioridade normal usa a fila configurada em
+// RABBITMQ_QUEUE_NOTIFICATIONS; as demais recebem o sufixo .high e .low.
+func (q queueSettings) laneQueue(priority entity.NotificationPriority) string {
+	switch priority {
+	case entity.PriorityHigh, entity.PriorityLow:
+		return q.config.RabbitMQ.QueueNotifications + "." + string(priority)
+	}
+	return q.config.RabbitMQ.QueueNotifications
+}
+
+// channelQueue retorna a fila do canal de entrega, ex: notifications.email
+func (q queueSettings) channelQueue(channel entity.DeliveryChannel) string {
+	return q.config.RabbitMQ.QueueNotifications + "." + string(channel)
+}
+
+// isWorkQueue indica se a fila é uma das filas de prioridade ou de canal (e não uma fila
+// de retry)
+func (q queueSettings) isWorkQueue(name string) bool {
+	for _, queue := range q.workQueues() {
+		if queue == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isLaneQueue indica se a fila é uma das filas de prioridade
+func (q queueSettings) isLaneQueue(name string) bool {
+	for _, queue := range q.laneQueues() {
+		if queue == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (q queueSettings) laneQueues() []string {
+	queues := make([]string, 0, len(entity.Priorities))
+	for _, priority := range entity.Priorities {
+		queues = append(queues, q.laneQueue(priority))
+	}
+	return queues
+}
+
+func (q queueSettings) channelQueues() []string {
+	queues := make([]string, 0, len(entity.DeliveryChannels))
+	for _, channel := range entity.DeliveryChannels {
+		queues = append(queues, q.channelQueue(channel))
+	}
+	return queues
+}
+
+// workQueues lista as filas consumidas pelos workers: prioridades e canais
+func (q queueSettings) workQueues() []string {
+	return append(q.laneQueues(), q.channelQueues()...)
+}
+
+// channelConfig retorna a configuração do canal da fila; false para as filas de prioridade
+func (q queueSettings) channelConfig(queue string) (config.ChannelQueueConfig, bool) {
+	for _, channel := range entity.DeliveryChannels {
+		if q.channelQueue(channel) == queue {
+			return q.config.RabbitMQ.Channels[channel], true
+		}
+	}
+	return config.ChannelQueueConfig{}, false
+}
+
+// maxLength é o limite de mensagens de cada fila de trabalho
+func (q queueSettings) maxLength() int {
+	if q.config.RabbitMQ.MaxLength > 0 {
+		return q.config.RabbitMQ.MaxLength
+	}
+	return defaultMaxLength
+}
+
+// highWater é a quantidade de mensagens a partir da qual a fila é considerada saturada
+func (q queueSettings) highWater() int {
+	return int(float64(q.maxLength()) * highWaterRatio)
+}
+
+// retryPolicy são os atrasos e o total de tentativas de uma fila
+type retryPolicy struct {
+	delays      []time.Duration
+	maxAttempts int
+}
+
+// delay escolhe o atraso da próxima tentativa; além do último nível, repete o último
+func (p retryPolicy) delay(retryCount int) time.Duration {
+	if retryCount >= len(p.delays) {
+		return p.delays[len(p.delays)-1]
+	}
+	return p.delays[retryCount]
+}
+
+// retryPolicy retorna a política da fila: as filas de canal podem substituir os atrasos e
+// as tentativas gerais
+func (q queueSettings) retryPolicy(queue string) retryPolicy {
+	policy := retryPolicy{delays: q.config.RabbitMQ.RetryDelays, maxAttempts: q.config.RabbitMQ.MaxAttempts}
+	if channel, ok := q.channelConfig(queue); ok {
+		if len(channel.RetryDelays) > 0 {
+			policy.delays = channel.RetryDelays
+		}
+		if channel.MaxAttempts > 0 {
+			policy.maxAttempts = channel.MaxAttempts
+		}
+	}
+	if len(policy.delays) == 0 {
+		policy.delays = defaultRetryDelays
+	}
+	if policy.maxAttempts <= 0 {
+		policy.maxAttempts = defaultMaxAttempts
+	}
+	return policy
+}
+
+// prefetch é a quantidade de mensagens entregues a cada consumer antes do ack
+func (q queueSettings) prefetch(queue string) int {
+	if channel, ok := q.channelConfig(queue); ok && channel.Prefetch > 0 {
+		return channel.Prefetch
+	}
+	return defaultPrefetch
+}
+
+// retryDelays lista os atrasos distintos de todas as filas, para as estatísticas e a
+// declaração das filas de retry
+func (q queueSettings) retryDelays() []time.Duration {
+	var delays []time.Duration
+	seen := map[time.Duration]bool{}
+	for _, queue := range q.workQueues() {
+		for _, delay := range q.retryPolicy(queue).delays {
+			if !seen[delay] {
+				seen[delay] = true
+				delays = append(delays, delay)
+			}
+		}
+	}
+	return delays
+}
+
+// messagePriority converte a prioridade da notificação na prioridade da mensagem, usada
+// para ordenar as filas de canal (que recebem todas as prioridades)
+func messagePriority(priority entity.NotificationPriority) uint8 {
+	switch priority {
+	case entity.PriorityHigh:
+		return 2
+	case entity.PriorityLow:
+		return 0
+	}
+	return 1
+}
@@ -3,8 +3,11 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -12,20 +15,84 @@ import (
 	"github.com/prefeitura-rio/app-notification-core/internal/entity"
 )
 
+const (
+	reconnectInitialBackoff = 1 * time.Second
+	reconnectMaxBackoff     = 30 * time.Second
+
+	// defaultPublishChannels é o tamanho do pool de canais de publicação quando
+	// RABBITMQ_PUBLISH_CHANNELS está vazio
+	defaultPublishChannels = 8
+
+	// RetryCountHeader guarda quantas vezes a mensagem já foi reprocessada
+	RetryCountHeader = "x-retry-count"
+)
+
+// RabbitMQClient mantém a conexão com o RabbitMQ, reconectando com backoff quando ela cai.
+// Cada consumer usa um canal próprio e as publicações usam um pool de canais confirm,
+// recriados a cada reconexão.
 type RabbitMQClient struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
-	config  *config.Config
+	queueSettings
+
+	mu          sync.RWMutex
+	conn        *amqp.Connection
+	state       ConnectionState
+	lastError   error
+	connectedAt time.Time
+	// ready é fechado quando há conexão disponível e recriado quando ela cai
+	ready chan struct{}
+
+	// publishers são os canais confirm das publicações; idle guarda os livres. Cada canal
+	// publica uma mensagem por vez e aguarda a confirmação, e os canais publicam em paralelo.
+	publishers []*confirmChannel
+	idle       chan *confirmChannel
+
+	activeConsumers int32
+	reconnects      int64
+
+	closing   chan struct{}
+	closeOnce sync.Once
 }
 
-type NotificationMessage struct {
-	Notification *entity.Notification `json:"notification"`
-	Timestamp    time.Time             `json:"timestamp"`
-	RetryCount   int                   `json:"retry_count"`
+func NewRabbitMQClient(cfg *config.Config) (*RabbitMQClient, error) {
+	r := &RabbitMQClient{
+		queueSettings: queueSettings{config: cfg},
+		ready:         make(chan struct{}),
+		closing:       make(chan struct{}),
+	}
+	channels := cfg.RabbitMQ.PublishChannels
+	if channels <= 0 {
+		channels = defaultPublishChannels
+	}
+	r.idle = make(chan *confirmChannel, channels)
+	for i := 0; i < channels; i++ {
+		publisher := &confirmChannel{}
+		r.publishers = append(r.publishers, publisher)
+		r.idle <- publisher
+	}
+
+	// A primeira conexão é síncrona: sem RabbitMQ o serviço não sobe
+	conn, err := r.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	// A política fica gravada no broker, então é aplicada apenas na inicialização e não a
+	// cada reconexão
+	if err := r.applyQueuePolicy(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to apply RabbitMQ queue policy: %w", err)
+	}
+	r.setConnected(conn)
+
+	go r.watch(conn)
+
+	log.Printf("✅ RabbitMQ connected to %s", cfg.RabbitMQ.URL)
+	return r, nil
 }
 
-func NewRabbitMQClient(cfg *config.Config) (*RabbitMQClient, error) {
-	conn, err := amqp.Dial(cfg.RabbitMQ.URL)
+// connect abre a conexão e declara a topologia (filas, DLX e DLQ)
+func (r *RabbitMQClient) connect() (*amqp.Connection, error) {
+	conn, err := amqp.Dial(r.config.RabbitMQ.URL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
@@ -35,29 +102,26 @@ func NewRabbitMQClient(cfg *config.Config) (*RabbitMQClient, error) {
 		conn.Close()
 		return nil, fmt.Errorf("failed to open channel: %w", err)
 	}
+	defer channel.Close()
 
-	// Declarar fila com configurações de durabilidade
-	_, err = channel.QueueDeclare(
-		cfg.RabbitMQ.QueueNotifications, // name
-		true,                              // durable
-		false,                             // delete when unused
-		false,                             // exclusive
-		false,                             // no-wait
-		amqp.Table{
-			"x-message-ttl":             int32(3600000), // 1 hora
-			"x-max-length":              int32(100000),  // máximo 100k mensagens
-			"x-dead-letter-exchange":    "notifications.dlx",
-			"x-dead-letter-routing-key": "notifications.dlq",
-		},
-	)
-	if err != nil {
-		channel.Close()
+	if err := r.declareTopology(conn, channel); err != nil {
 		conn.Close()
-		return nil, fmt.Errorf("failed to declare queue: %w", err)
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (r *RabbitMQClient) declareTopology(conn *amqp.Connection, channel *amqp.Channel) error {
+	// Declarar uma fila por prioridade e uma por canal com configurações de durabilidade.
+	// As filas de canal recebem todas as prioridades e as ordenam pela prioridade da mensagem.
+	for _, queue := range r.workQueues() {
+		if err := r.declareWorkQueue(conn, channel, queue); err != nil {
+			return err
+		}
 	}
 
 	// Declarar exchange para Dead Letter Queue
-	err = channel.ExchangeDeclare(
+	err := channel.ExchangeDeclare(
 		"notifications.dlx", // name
 		"direct",            // type
 		true,                // durable
@@ -67,7 +131,7 @@ func NewRabbitMQClient(cfg *config.Config) (*RabbitMQClient, error) {
 		nil,                 // arguments
 	)
 	if err != nil {
-		log.Printf("Warning: Failed to declare DLX: %v", err)
+		return fmt.Errorf("failed to declare DLX: %w", err)
 	}
 
 	// Declarar Dead Letter Queue
@@ -80,7 +144,7 @@ func NewRabbitMQClient(cfg *config.Config) (*RabbitMQClient, error) {
 		nil,
 	)
 	if err != nil {
-		log.Printf("Warning: Failed to declare DLQ: %v", err)
+		return fmt.Errorf("failed to declare DLQ: %w", err)
 	}
 
 	// Bind DLQ ao exchange
@@ -92,49 +156,386 @@ func NewRabbitMQClient(cfg *config.Config) (*RabbitMQClient, error) {
 		nil,
 	)
 	if err != nil {
-		log.Printf("Warning: Failed to bind DLQ: %v", err)
+		return fmt.Errorf("failed to bind DLQ: %w", err)
+	}
+
+	// Filas de retry de cada fila, com os atrasos da sua política: sem consumers, a
+	// mensagem expira após o TTL da fila e volta para a fila de origem pelo dead-letter no
+	// exchange padrão
+	for _, queue := range r.workQueues() {
+		for _, delay := range r.retryPolicy(queue).delays {
+			_, err = channel.QueueDeclare(
+				r.retryQueueName(queue, delay), // name
+				true,                           // durable
+				false,                          // delete when unused
+				false,                          // exclusive
+				false,                          // no-wait
+				amqp.Table{
+					"x-message-ttl":             delay.Milliseconds(),
+					"x-dead-letter-exchange":    "",
+					"x-dead-letter-routing-key": queue,
+				},
+			)
+			if err != nil {
+				return fmt.Errorf("failed to declare retry queue %s: %w", r.retryQueueName(queue, delay), err)
+			}
+		}
+	}
+
+	if r.config.RabbitMQ.CommandsEnabled {
+		if err := r.declareCommandTopology(channel); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// declareWorkQueue declara a fila de trabalho se ela ainda não existir. Filas existentes não
+// são declaradas novamente: o RabbitMQ recusa (406 PRECONDITION_FAILED) a declaração com
+// argumentos diferentes dos da fila, e filas criadas por versões anteriores têm outros
+// argumentos. Filas novas já nascem com x-max-length e x-overflow reject-publish, então
+// mantêm o limite mesmo sem a política de applyQueuePolicy, que vale também para as
+// existentes e acompanha mudanças de RABBITMQ_MAX_LENGTH (vale o menor dos dois limites).
+func (r *RabbitMQClient) declareWorkQueue(conn *amqp.Connection, channel *amqp.Channel, queue string) error {
+	exists, err := queueExists(conn, queue)
+	if err != nil {
+		return fmt.Errorf("failed to check queue %s: %w", queue, err)
+	}
+	if exists {
+		return nil
 	}
 
-	// Configurar QoS (prefetch)
-	err = channel.Qos(
-		10,    // prefetch count
-		0,     // prefetch size
-		false, // global
+	args := amqp.Table{
+		"x-message-ttl":             int32(3600000), // 1 hora
+		"x-max-length":              int32(r.maxLength()),
+		"x-overflow":                "reject-publish",
+		"x-dead-letter-exchange":    "notifications.dlx",
+		"x-dead-letter-routing-key": "notifications.dlq",
+	}
+	if !r.isLaneQueue(queue) {
+		args["x-max-priority"] = int32(maxMessagePriority)
+	}
+	_, err = channel.QueueDeclare(
+		queue, // name
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		args,
 	)
 	if err != nil {
-		log.Printf("Warning: Failed to set QoS: %v", err)
+		return fmt.Errorf("failed to declare queue %s: %w", queue, err)
+	}
+	return nil
+}
+
+// queueExists verifica a fila com uma declaração passiva, em canal próprio: a declaração de
+// uma fila inexistente (404) fecha o canal
+func queueExists(conn *amqp.Connection, queue string) (bool, error) {
+	channel, err := conn.Channel()
+	if err != nil {
+		return false, fmt.Errorf("failed to open channel: %w", err)
 	}
+	defer channel.Close()
 
-	log.Printf("✅ RabbitMQ connected to %s", cfg.RabbitMQ.URL)
-	return &RabbitMQClient{
-		conn:    conn,
-		channel: channel,
-		config:  cfg,
-	}, nil
+	if _, err := channel.QueueDeclarePassive(queue, true, false, false, false, nil); err != nil {
+		var amqpErr *amqp.Error
+		if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
 }
 
-// PublishNotification publica uma notificação na fila
-func (r *RabbitMQClient) PublishNotification(notification *entity.Notification) error {
-	message := NotificationMessage{
-		Notification: notification,
-		Timestamp:    time.Now(),
-		RetryCount:   0,
+// retryQueueName nomeia a fila de retry pela fila de origem e atraso, ex: notifications.high.retry.30s
+func (r *RabbitMQClient) retryQueueName(queue string, delay time.Duration) string {
+	return queue + ".retry." + delay.String()
+}
+
+// retryCount lê o header x-retry-count (ausente em mensagens novas)
+func retryCount(headers amqp.Table) int {
+	switch v := headers[RetryCountHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+func (r *RabbitMQClient) setConnected(conn *amqp.Connection) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state == StateClosed {
+		// Close foi chamado durante a reconexão
+		conn.Close()
+		return false
+	}
+	r.conn = conn
+	r.state = StateConnected
+	r.lastError = nil
+	r.connectedAt = time.Now()
+	close(r.ready)
+	return true
+}
+
+func (r *RabbitMQClient) setDisconnected(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state != StateConnected {
+		return
+	}
+	r.state = StateReconnecting
+	r.lastError = err
+	r.ready = make(chan struct{})
+}
+
+// watch aguarda o fechamento da conexão e reconecta com backoff exponencial
+func (r *RabbitMQClient) watch(conn *amqp.Connection) {
+	for {
+		closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+		select {
+		case <-r.closing:
+			return
+		case amqpErr := <-closed:
+			var err error = ErrNotConnected
+			if amqpErr != nil {
+				err = amqpErr
+			}
+			log.Printf("⚠️ RabbitMQ connection lost: %v", err)
+			r.setDisconnected(err)
+			r.resetPublisher()
+		}
+
+		backoff := reconnectInitialBackoff
+		for {
+			select {
+			case <-r.closing:
+				return
+			case <-time.After(backoff):
+			}
+
+			newConn, err := r.connect()
+			if err == nil {
+				conn = newConn
+				break
+			}
+
+			log.Printf("⚠️ RabbitMQ reconnect failed, retrying in %s: %v", backoff, err)
+			r.mu.Lock()
+			r.lastError = err
+			r.mu.Unlock()
+
+			backoff *= 2
+			if backoff > reconnectMaxBackoff {
+				backoff = reconnectMaxBackoff
+			}
+		}
+
+		if !r.setConnected(conn) {
+			return
+		}
+		atomic.AddInt64(&r.reconnects, 1)
+		log.Printf("✅ RabbitMQ reconnected to %s", r.config.RabbitMQ.URL)
+	}
+}
+
+// connection retorna a conexão atual sem aguardar reconexão
+func (r *RabbitMQClient) connection() (*amqp.Connection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	switch r.state {
+	case StateClosed:
+		return nil, ErrClientClosed
+	case StateConnected:
+		return r.conn, nil
+	}
+	return nil, ErrNotConnected
+}
+
+// waitConnection bloqueia até haver conexão disponível ou o cliente ser fechado
+func (r *RabbitMQClient) waitConnection() (*amqp.Connection, error) {
+	for {
+		r.mu.RLock()
+		ready, state, conn := r.ready, r.state, r.conn
+		r.mu.RUnlock()
+
+		if state == StateClosed {
+			return nil, ErrClientClosed
+		}
+		if state == StateConnected {
+			if !conn.IsClosed() {
+				return conn, nil
+			}
+			// Conexão caiu e o watch ainda não registrou: aguardar a troca de estado
+			ready = nil
+		}
+
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-ready:
+		case <-r.closing:
+			return nil, ErrClientClosed
+		}
 	}
+}
 
-	body, err := json.Marshal(message)
+// openChannel abre um canal próprio na conexão atual
+func (r *RabbitMQClient) openChannel() (*amqp.Channel, error) {
+	conn, err := r.connection()
 	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
+		return nil, err
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	return channel, nil
+}
+
+// confirmChannel é um canal em modo confirm do pool de publicação. mu é travado durante a
+// publicação e pelo resetPublisher.
+type confirmChannel struct {
+	mu      sync.Mutex
+	channel *amqp.Channel
+	returns chan amqp.Return
+}
+
+// open retorna o canal, recriando-o se estiver fechado. Deve ser chamado com mu travado.
+func (c *confirmChannel) open(r *RabbitMQClient) (*amqp.Channel, error) {
+	if c.channel != nil && !c.channel.IsClosed() {
+		return c.channel, nil
+	}
+	channel, err := r.openChannel()
+	if err != nil {
+		return nil, err
+	}
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	// O broker envia o basic.return antes do ack da mesma mensagem, então a devolução já
+	// está no buffer quando a confirmação chega. O buffer é esvaziado após cada confirmação.
+	c.channel = channel
+	c.returns = channel.NotifyReturn(make(chan amqp.Return, 16))
+	return channel, nil
+}
+
+// discard fecha o canal; a próxima publicação abre outro. Deve ser chamado com mu travado.
+func (c *confirmChannel) discard() {
+	if c.channel != nil {
+		c.channel.Close()
+		c.channel = nil
+		c.returns = nil
+	}
+}
+
+func (r *RabbitMQClient) resetPublisher() {
+	for _, publisher := range r.publishers {
+		publisher.mu.Lock()
+		publisher.discard()
+		publisher.mu.Unlock()
 	}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// publish publica a mensagem como mandatory e aguarda a confirmação do broker, num canal livre
+// do pool. Retorna ErrPublishNacked, ErrPublishReturned ou ErrPublishTimeout quando a mensagem
+// não é aceita; o prazo inclui a espera por um canal livre.
+func (r *RabbitMQClient) publish(exchange, routingKey string, msg amqp.Publishing) error {
+	timeout := r.config.RabbitMQ.PublishTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	err = r.channel.PublishWithContext(
+	var publisher *confirmChannel
+	select {
+	case publisher = <-r.idle:
+	case <-ctx.Done():
+		return fmt.Errorf("%w after %s waiting for a publish channel", ErrPublishTimeout, timeout)
+	case <-r.closing:
+		return ErrClientClosed
+	}
+	defer func() { r.idle <- publisher }()
+
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+
+	channel, err := publisher.open(r)
+	if err != nil {
+		return err
+	}
+	returns := publisher.returns
+
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(
 		ctx,
-		"",                                  // exchange
-		r.config.RabbitMQ.QueueNotifications, // routing key
-		false,                               // mandatory
-		false,                               // immediate
+		exchange,
+		routingKey,
+		true,  // mandatory
+		false, // immediate
+		msg,
+	)
+	if err != nil {
+		// O canal pode ter sido fechado pelo broker; o próximo publish abre outro
+		publisher.discard()
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		// Sem confirmação não se sabe o destino da mensagem: descarta o canal para
+		// que confirmações atrasadas não sejam atribuídas à próxima publicação
+		publisher.discard()
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w after %s", ErrPublishTimeout, timeout)
+		}
+		return err
+	}
+
+	var returnErr error
+	for drained := false; !drained; {
+		select {
+		case ret := <-returns:
+			if ret.MessageId == msg.MessageId {
+				returnErr = fmt.Errorf("%w: %d %s", ErrPublishReturned, ret.ReplyCode, ret.ReplyText)
+			}
+		default:
+			drained = true
+		}
+	}
+
+	if !acked {
+		return ErrPublishNacked
+	}
+	return returnErr
+}
+
+// saturationError marca como ErrQueueSaturated a recusa de uma fila de trabalho: com
+// x-overflow reject-publish, o nack indica que a fila atingiu x-max-length
+func saturationError(err error) error {
+	if errors.Is(err, ErrPublishNacked) {
+		return fmt.Errorf("%w: %w", ErrQueueSaturated, err)
+	}
+	return err
+}
+
+// PublishNotification publica uma notificação na fila
+func (r *RabbitMQClient) PublishNotification(notification *entity.Notification) error {
+	body, err := json.Marshal(newNotificationMessage(notification))
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	err = r.publish(
+		"",                                 // exchange
+		r.laneQueue(notification.Priority), // routing key
 		amqp.Publishing{
 			DeliveryMode: amqp.Persistent,
 			ContentType:  "application/json",
@@ -144,94 +545,325 @@ func (r *RabbitMQClient) PublishNotification(notification *entity.Notification)
 		},
 	)
 	if err != nil {
-		return fmt.Errorf("failed to publish message: %w", err)
+		return fmt.Errorf("failed to publish message: %w", saturationError(err))
+	}
+
+	log.Printf("📤 Notification %s published to %s (confirmed)", notification.ID, r.laneQueue(notification.Priority))
+	return nil
+}
+
+// PublishLeg publica a etapa de um canal na fila do canal, com a prioridade da notificação
+func (r *RabbitMQClient) PublishLeg(notification *entity.Notification, channel entity.DeliveryChannel) error {
+	body, err := json.Marshal(newLegMessage(notification, channel))
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	err = r.publish(
+		"",                      // exchange
+		r.channelQueue(channel), // routing key
+		amqp.Publishing{
+			DeliveryMode: amqp.Persistent,
+			ContentType:  "application/json",
+			Body:         body,
+			Timestamp:    time.Now(),
+			MessageId:    legMessageID(notification, channel),
+			Priority:     messagePriority(notification.Priority),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to publish message: %w", saturationError(err))
 	}
 
-	log.Printf("📤 Notification %s published to queue", notification.ID)
+	log.Printf("📤 Notification %s %s leg published to %s (confirmed)", notification.ID, channel, r.channelQueue(channel))
+	return nil
+}
+
+// ConsumeNotifications consome mensagens da fila da prioridade em um canal próprio. Se a
+// conexão ou o canal caírem, o consumer é registrado novamente após a reconexão. Retorna
+// quando o contexto é cancelado ou o cliente é fechado.
+func (r *RabbitMQClient) ConsumeNotifications(ctx context.Context, priority entity.NotificationPriority, handler func(*NotificationMessage) error) error {
+	return r.consumeQueue(ctx, r.laneQueue(priority), handler)
+}
+
+// ConsumeChannel consome as etapas da fila do canal, como ConsumeNotifications
+func (r *RabbitMQClient) ConsumeChannel(ctx context.Context, channel entity.DeliveryChannel, handler func(*NotificationMessage) error) error {
+	return r.consumeQueue(ctx, r.channelQueue(channel), handler)
+}
+
+func (r *RabbitMQClient) consumeQueue(ctx context.Context, queue string, handler func(*NotificationMessage) error) error {
+	for ctx.Err() == nil {
+		if _, err := r.waitConnection(); err != nil {
+			if errors.Is(err, ErrClientClosed) {
+				return nil
+			}
+			return err
+		}
+
+		if err := r.consume(ctx, queue, handler); err != nil {
+			if errors.Is(err, ErrClientClosed) || ctx.Err() != nil {
+				return nil
+			}
+			log.Printf("⚠️ Consumer stopped: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.closing:
+			return nil
+		case <-time.After(reconnectInitialBackoff):
+		}
+		log.Printf("🔄 Restarting consumer on %s...", queue)
+	}
 	return nil
 }
 
-// ConsumeNotifications consome mensagens da fila
-func (r *RabbitMQClient) ConsumeNotifications(handler func(*NotificationMessage) error) error {
-	msgs, err := r.channel.Consume(
-		r.config.RabbitMQ.QueueNotifications, // queue
-		"",                                     // consumer
-		false,                                  // auto-ack
-		false,                                  // exclusive
-		false,                                  // no-local
-		false,                                  // no-wait
-		nil,                                    // args
+// consume registra o consumer e processa as mensagens até o canal ser fechado ou o contexto
+// ser cancelado. As mensagens recebidas pelo prefetch e ainda não processadas voltam para a
+// fila quando o canal é fechado.
+func (r *RabbitMQClient) consume(ctx context.Context, queue string, handler func(*NotificationMessage) error) error {
+	channel, err := r.openChannel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	// Configurar QoS (prefetch) do canal deste consumer
+	if err := channel.Qos(
+		r.prefetch(queue), // prefetch count
+		0,                 // prefetch size
+		false,             // global
+	); err != nil {
+		log.Printf("Warning: Failed to set QoS: %v", err)
+	}
+
+	msgs, err := channel.Consume(
+		queue, // queue
+		"",    // consumer
+		false, // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // args
 	)
 	if err != nil {
 		return fmt.Errorf("failed to register consumer: %w", err)
 	}
 
-	log.Printf("🔄 Consumer started, waiting for messages...")
+	atomic.AddInt32(&r.activeConsumers, 1)
+	defer atomic.AddInt32(&r.activeConsumers, -1)
+
+	log.Printf("🔄 Consumer started on %s, waiting for messages...", queue)
+
+	for {
+		var msg amqp.Delivery
+		var ok bool
+		select {
+		case <-ctx.Done():
+			log.Printf("🛑 Consumer on %s stopped taking messages", queue)
+			return ctx.Err()
+		case msg, ok = <-msgs:
+		}
+		if !ok {
+			break
+		}
+		if ctx.Err() != nil {
+			// Cancelado enquanto a mensagem chegava: ela volta para a fila
+			msg.Nack(false, true)
+			return ctx.Err()
+		}
 
-	for msg := range msgs {
-		var notifMsg NotificationMessage
-		if err := json.Unmarshal(msg.Body, &notifMsg); err != nil {
+		decoded, err := decodeMessage(msg.Body)
+		if err != nil {
 			log.Printf("❌ Failed to unmarshal message: %v", err)
 			msg.Nack(false, false) // Envia para DLQ
 			continue
 		}
+		notifMsg := decoded.NotificationMessage
+		notifMsg.RetryCount = retryCount(msg.Headers)
+		notifMsg.FinalAttempt = notifMsg.RetryCount+1 >= r.retryPolicy(queue).maxAttempts
 
-		log.Printf("📥 Processing notification %s (retry: %d)", notifMsg.Notification.ID, notifMsg.RetryCount)
+		log.Printf("📥 Processing notification %s (retry: %d)", notifMsg.NotificationID, notifMsg.RetryCount)
 
 		// Processar mensagem
 		if err := handler(&notifMsg); err != nil {
-			log.Printf("❌ Failed to process notification %s: %v", notifMsg.Notification.ID, err)
-
-			// Retry logic
-			if notifMsg.RetryCount < 3 {
-				// Republicar com retry incrementado
-				notifMsg.RetryCount++
-				body, _ := json.Marshal(notifMsg)
-
-				r.channel.Publish(
-					"",
-					r.config.RabbitMQ.QueueNotifications,
-					false,
-					false,
-					amqp.Publishing{
-						DeliveryMode: amqp.Persistent,
-						ContentType:  "application/json",
-						Body:         body,
-					},
-				)
-				msg.Ack(false)
-				log.Printf("🔄 Notification %s requeued (retry %d/3)", notifMsg.Notification.ID, notifMsg.RetryCount)
-			} else {
-				// Após 3 tentativas, envia para DLQ
-				msg.Nack(false, false)
-				log.Printf("💀 Notification %s sent to DLQ after 3 retries", notifMsg.Notification.ID)
-			}
+			log.Printf("❌ Failed to process notification %s: %v", notifMsg.NotificationID, err)
+			r.retry(msg, queue, notifMsg.RetryCount)
 			continue
 		}
 
 		// Sucesso
 		msg.Ack(false)
-		log.Printf("✅ Notification %s processed successfully", notifMsg.Notification.ID)
+		log.Printf("✅ Notification %s processed successfully", notifMsg.NotificationID)
 	}
 
-	return nil
+	select {
+	case <-r.closing:
+		return ErrClientClosed
+	default:
+	}
+	return errors.New("delivery channel closed")
+}
+
+// retry agenda uma nova tentativa na fila de retry do nível correspondente, com o mesmo
+// corpo e o header x-retry-count incrementado. Esgotadas as tentativas, envia para a DLQ.
+func (r *RabbitMQClient) retry(msg amqp.Delivery, queue string, retries int) {
+	policy := r.retryPolicy(queue)
+	attempt := retries + 1
+	if attempt >= policy.maxAttempts {
+		msg.Nack(false, false)
+		log.Printf("💀 Message %s sent to DLQ after %d attempt(s)", msg.MessageId, attempt)
+		return
+	}
+
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[RetryCountHeader] = int32(attempt)
+
+	delay := policy.delay(retries)
+	err := r.publish(
+		"",                             // exchange padrão
+		r.retryQueueName(queue, delay), // routing key
+		amqp.Publishing{
+			Headers:      headers,
+			DeliveryMode: amqp.Persistent,
+			ContentType:  msg.ContentType,
+			Body:         msg.Body,
+			Timestamp:    msg.Timestamp,
+			MessageId:    msg.MessageId,
+			Priority:     msg.Priority,
+		},
+	)
+	if err != nil {
+		// Sem agendar o retry, devolve a mensagem original para a fila
+		log.Printf("❌ Failed to schedule retry for message %s: %v", msg.MessageId, err)
+		msg.Nack(false, true)
+		return
+	}
+
+	msg.Ack(false)
+	log.Printf("🔄 Message %s scheduled for retry %d/%d in %s", msg.MessageId, attempt, policy.maxAttempts-1, delay)
+}
+
+// Backend identifica a implementação da fila
+func (r *RabbitMQClient) Backend() string {
+	return BackendRabbitMQ
+}
+
+// Status retorna o estado da conexão e a quantidade de consumers ativos
+func (r *RabbitMQClient) Status() ConnectionStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	status := ConnectionStatus{
+		State:           r.state,
+		ActiveConsumers: atomic.LoadInt32(&r.activeConsumers),
+		Reconnects:      atomic.LoadInt64(&r.reconnects),
+	}
+	if r.lastError != nil {
+		status.LastError = r.lastError.Error()
+	}
+	if r.state == StateConnected {
+		connectedAt := r.connectedAt
+		status.ConnectedAt = &connectedAt
+	}
+	return status
+}
+
+// IsConnected indica se há conexão ativa com o RabbitMQ
+func (r *RabbitMQClient) IsConnected() bool {
+	conn, err := r.connection()
+	return err == nil && !conn.IsClosed()
+}
+
+// Saturated indica se alguma fila de prioridade ou de canal passou da marca de saturação
+func (r *RabbitMQClient) Saturated() (bool, error) {
+	depths, err := r.Depths()
+	if err != nil {
+		return false, err
+	}
+	for _, messages := range depths {
+		if messages >= r.highWater() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Depths retorna as mensagens prontas de cada fila de prioridade e de canal
+func (r *RabbitMQClient) Depths() (map[string]int, error) {
+	// Canal temporário: uma declaração passiva que falha fecha o canal
+	channel, err := r.openChannel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check queue depth: %w", err)
+	}
+	defer channel.Close()
+
+	depths := map[string]int{}
+	for _, priority := range entity.Priorities {
+		queue, err := channel.QueueDeclarePassive(r.laneQueue(priority), true, false, false, false, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check queue depth: %w", err)
+		}
+		depths[string(priority)] = queue.Messages
+	}
+	for _, deliveryChannel := range entity.DeliveryChannels {
+		queue, err := channel.QueueDeclarePassive(r.channelQueue(deliveryChannel), true, false, false, false, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check queue depth: %w", err)
+		}
+		depths[string(deliveryChannel)] = queue.Messages
+	}
+	return depths, nil
 }
 
 // GetQueueStats retorna estatísticas da fila
 func (r *RabbitMQClient) GetQueueStats() (map[string]interface{}, error) {
-	queue, err := r.channel.QueueDeclarePassive(
-		r.config.RabbitMQ.QueueNotifications,
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+	// Canal temporário: uma declaração passiva que falha fecha o canal
+	channel, err := r.openChannel()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get queue stats: %w", err)
 	}
+	defer channel.Close()
+
+	// Mensagens e consumers de cada fila de prioridade
+	lanes := map[string]interface{}{}
+	messages, consumers := 0, 0
+	for _, priority := range entity.Priorities {
+		queue, err := channel.QueueDeclarePassive(r.laneQueue(priority), true, false, false, false, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get queue stats: %w", err)
+		}
+		lanes[string(priority)] = map[string]interface{}{
+			"queue_name": queue.Name,
+			"messages":   queue.Messages,
+			"consumers":  queue.Consumers,
+		}
+		messages += queue.Messages
+		consumers += queue.Consumers
+	}
 
-	dlq, _ := r.channel.QueueDeclarePassive(
+	// Mensagens e consumers da fila de cada canal
+	channels := map[string]interface{}{}
+	for _, deliveryChannel := range entity.DeliveryChannels {
+		name := r.channelQueue(deliveryChannel)
+		queue, err := channel.QueueDeclarePassive(name, true, false, false, false, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get queue stats: %w", err)
+		}
+		channels[string(deliveryChannel)] = map[string]interface{}{
+			"queue_name":   queue.Name,
+			"messages":     queue.Messages,
+			"consumers":    queue.Consumers,
+			"prefetch":     r.prefetch(name),
+			"max_attempts": r.retryPolicy(name).maxAttempts,
+		}
+	}
+
+	dlq, _ := channel.QueueDeclarePassive(
 		"notifications.dlq",
 		true,
 		false,
@@ -240,34 +872,67 @@ func (r *RabbitMQClient) GetQueueStats() (map[string]interface{}, error) {
 		nil,
 	)
 
+	// Mensagens aguardando nova tentativa, por atraso (somando prioridades e canais)
+	retryMessages := map[string]int{}
+	for _, queue := range r.workQueues() {
+		for _, delay := range r.retryPolicy(queue).delays {
+			retryQueue, err := channel.QueueDeclarePassive(r.retryQueueName(queue, delay), true, false, false, false, nil)
+			if err == nil {
+				retryMessages[delay.String()] += retryQueue.Messages
+			}
+		}
+	}
+
 	stats := map[string]interface{}{
-		"queue_name":    queue.Name,
-		"messages":      queue.Messages,
-		"consumers":     queue.Consumers,
-		"dlq_messages":  dlq.Messages,
-		"last_checked":  time.Now(),
+		"backend":        BackendRabbitMQ,
+		"queue_name":     r.config.RabbitMQ.QueueNotifications,
+		"messages":       messages,
+		"consumers":      consumers,
+		"lanes":          lanes,
+		"channels":       channels,
+		"dlq_messages":   dlq.Messages,
+		"retry_messages": retryMessages,
+		"max_attempts":   r.retryPolicy(r.laneQueue(entity.PriorityNormal)).maxAttempts,
+		"max_length":     r.maxLength(),
+		"connection":     r.Status(),
+		"last_checked":   time.Now(),
 	}
 
 	return stats, nil
 }
 
-// PurgeQueue limpa todas as mensagens da fila
+// PurgeQueue limpa todas as mensagens das filas de prioridade e de canal
 func (r *RabbitMQClient) PurgeQueue() error {
-	_, err := r.channel.QueuePurge(r.config.RabbitMQ.QueueNotifications, false)
+	channel, err := r.openChannel()
 	if err != nil {
 		return fmt.Errorf("failed to purge queue: %w", err)
 	}
-	log.Printf("🗑️ Queue %s purged", r.config.RabbitMQ.QueueNotifications)
+	defer channel.Close()
+
+	for _, queue := range r.workQueues() {
+		if _, err := channel.QueuePurge(queue, false); err != nil {
+			return fmt.Errorf("failed to purge queue: %w", err)
+		}
+		log.Printf("🗑️ Queue %s purged", queue)
+	}
 	return nil
 }
 
-// Close fecha a conexão com RabbitMQ
+// Close fecha a conexão com RabbitMQ e encerra os consumers
 func (r *RabbitMQClient) Close() error {
-	if r.channel != nil {
-		r.channel.Close()
-	}
-	if r.conn != nil {
-		return r.conn.Close()
-	}
-	return nil
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.closing)
+		r.resetPublisher()
+
+		r.mu.Lock()
+		r.state = StateClosed
+		conn := r.conn
+		r.mu.Unlock()
+
+		if conn != nil && !conn.IsClosed() {
+			err = conn.Close()
+		}
+	})
+	return err
 }
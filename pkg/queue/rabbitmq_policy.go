@@ -0,0 +1,157 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// workQueuePolicy é o nome da política das filas de prioridade e de canal
+	workQueuePolicy = "notification-core-work-queues"
+	// workQueuePolicyPriority é a prioridade mínima da política; o RabbitMQ aplica uma única
+	// política por fila, então ela sobe acima das políticas que encobre (ver inheritPolicies)
+	workQueuePolicyPriority = 10
+
+	managementTimeout = 10 * time.Second
+)
+
+type queuePolicy struct {
+	Pattern    string         `json:"pattern"`
+	Definition map[string]any `json:"definition"`
+	Priority   int            `json:"priority"`
+	ApplyTo    string         `json:"apply-to"`
+}
+
+// applyQueuePolicy aplica, pela API de gerenciamento, a política que limita as filas de
+// trabalho a RABBITMQ_MAX_LENGTH mensagens e recusa novas publicações (nack) com a fila cheia,
+// em vez de descartar as mais antigas. Políticas, ao contrário dos argumentos de declaração,
+// podem ser alteradas em filas existentes. Em filas criadas com x-max-length, vale o menor
+// entre o argumento e a política. Sem a política, filas criadas por versões anteriores
+// descartariam as mensagens mais antigas, então a falha impede a inicialização.
+func (r *RabbitMQClient) applyQueuePolicy() error {
+	managementURL := strings.TrimRight(r.config.RabbitMQ.ManagementURL, "/")
+	if managementURL == "" {
+		return errors.New("RABBITMQ_MANAGEMENT_URL is required to apply the queue length limit and reject-publish overflow")
+	}
+
+	uri, err := amqp.ParseURI(r.config.RabbitMQ.URL)
+	if err != nil {
+		return fmt.Errorf("invalid RabbitMQ URL: %w", err)
+	}
+	endpoint := managementURL + "/api/policies/" + url.PathEscape(uri.Vhost)
+
+	var existing []namedPolicy
+	if err := managementRequest(uri, http.MethodGet, endpoint, nil, &existing); err != nil {
+		return fmt.Errorf("failed to list policies: %w", err)
+	}
+
+	patterns := make([]string, 0, len(r.workQueues()))
+	for _, queue := range r.workQueues() {
+		patterns = append(patterns, regexp.QuoteMeta(queue))
+	}
+	policy := queuePolicy{
+		Pattern: "^(" + strings.Join(patterns, "|") + ")$",
+		ApplyTo: "queues",
+	}
+	policy.Definition, policy.Priority = r.inheritPolicies(existing)
+	policy.Definition["max-length"] = r.maxLength()
+	policy.Definition["overflow"] = "reject-publish"
+
+	if err := managementRequest(uri, http.MethodPut, endpoint+"/"+workQueuePolicy, policy, nil); err != nil {
+		return fmt.Errorf("failed to apply policy %s: %w", workQueuePolicy, err)
+	}
+
+	log.Printf("✅ RabbitMQ policy %s applied (max-length %d, overflow reject-publish)", workQueuePolicy, r.maxLength())
+	return nil
+}
+
+// inheritPolicies copia para a política das filas de trabalho as definições das políticas
+// que ela encobre (ex: ha-mode, queue-mode de operação). O RabbitMQ aplica uma única política
+// por fila, a de maior prioridade; por isso a política recebe prioridade acima delas e as
+// definições de prioridade maior prevalecem. Alterações nessas políticas só chegam às filas
+// de trabalho na próxima inicialização.
+func (r *RabbitMQClient) inheritPolicies(existing []namedPolicy) (map[string]any, int) {
+	sort.SliceStable(existing, func(i, j int) bool { return existing[i].Priority < existing[j].Priority })
+
+	definition := map[string]any{}
+	priority := workQueuePolicyPriority
+	for _, other := range existing {
+		if other.Name == workQueuePolicy || (other.ApplyTo != "queues" && other.ApplyTo != "all") {
+			continue
+		}
+		pattern, err := regexp.Compile(other.Pattern)
+		if err != nil {
+			log.Printf("Warning: RabbitMQ policy %s has a pattern Go cannot parse (%v), not inherited", other.Name, err)
+			continue
+		}
+		if !r.matchesWorkQueue(pattern) {
+			continue
+		}
+		for key, value := range other.Definition {
+			definition[key] = value
+		}
+		priority = max(priority, other.Priority+1)
+		log.Printf("RabbitMQ policy %s inherits the definition of policy %s", workQueuePolicy, other.Name)
+	}
+	return definition, priority
+}
+
+func (r *RabbitMQClient) matchesWorkQueue(pattern *regexp.Regexp) bool {
+	for _, queue := range r.workQueues() {
+		if pattern.MatchString(queue) {
+			return true
+		}
+	}
+	return false
+}
+
+type namedPolicy struct {
+	Name string `json:"name"`
+	queuePolicy
+}
+
+// managementRequest chama a API de gerenciamento com as credenciais de RABBITMQ_URL
+func managementRequest(uri amqp.URI, method, endpoint string, payload, out any) error {
+	var body io.Reader
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	request, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(uri.Username, uri.Password)
+	request.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: managementTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("management API returned %d: %s", response.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	if out != nil {
+		return json.NewDecoder(response.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/teambition/rrule-go"
+)
+
+type Kind string
+
+const (
+	// KindCron aceita expressões de 5 campos (minuto, hora, dia, mês, dia da semana) e
+	// atalhos como @daily e @weekly
+	KindCron Kind = "cron"
+	// KindRRule aceita uma RRULE do iCalendar (RFC 5545), com ou sem o prefixo "RRULE:"
+	KindRRule Kind = "rrule"
+)
+
+// ErrInvalidRule é retornado quando a expressão, o tipo ou o fuso não são válidos
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Rule calcula as ocorrências de uma recorrência no fuso configurado
+type Rule interface {
+	// Next retorna a primeira ocorrência estritamente após t; zero se não houver mais
+	Next(t time.Time) time.Time
+	// Location é o fuso da regra
+	Location() *time.Location
+}
+
+// Parse interpreta a expressão no fuso timezone. start é o início da série: ocorrências
+// anteriores a ele não são geradas. Nas RRULEs o DTSTART é a meia-noite do dia de start no
+// fuso, então a hora das ocorrências vem só da regra: sem BYHOUR elas caem à meia-noite, e
+// BYHOUR sem BYMINUTE/BYSECOND cai na hora cheia (BYHOUR=9 é 09:00:00).
+func Parse(kind Kind, expression, timezone string, start time.Time) (Rule, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidRule, timezone)
+	}
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, fmt.Errorf("%w: expression is required", ErrInvalidRule)
+	}
+
+	switch kind {
+	case KindCron:
+		schedule, err := cron.ParseStandard(expression)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+		if spec, ok := schedule.(*cron.SpecSchedule); ok {
+			spec.Location = loc
+		}
+		return &cronRule{schedule: schedule, start: start, loc: loc}, nil
+	case KindRRule:
+		option, err := rrule.StrToROption(strings.TrimPrefix(expression, "RRULE:"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+		local := start.In(loc)
+		option.Dtstart = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		if len(option.Byhour) > 0 && len(option.Byminute) == 0 {
+			option.Byminute = []int{0}
+		}
+		if (len(option.Byhour) > 0 || len(option.Byminute) > 0) && len(option.Bysecond) == 0 {
+			option.Bysecond = []int{0}
+		}
+		rule, err := rrule.NewRRule(*option)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+		return &rruleRule{rule: rule, start: start, loc: loc}, nil
+	}
+	return nil, fmt.Errorf("%w: kind must be cron or rrule", ErrInvalidRule)
+}
+
+type cronRule struct {
+	schedule cron.Schedule
+	start    time.Time
+	loc      *time.Location
+}
+
+func (r *cronRule) Next(t time.Time) time.Time {
+	// A primeira ocorrência pode ser o próprio início
+	if t.Before(r.start) {
+		t = r.start.Add(-time.Second)
+	}
+	return r.schedule.Next(t)
+}
+
+func (r *cronRule) Location() *time.Location {
+	return r.loc
+}
+
+type rruleRule struct {
+	rule  *rrule.RRule
+	start time.Time
+	loc   *time.Location
+}
+
+func (r *rruleRule) Next(t time.Time) time.Time {
+	// O DTSTART é a meia-noite: ocorrências do dia anteriores ao início não são geradas
+	if t.Before(r.start) {
+		return r.rule.After(r.start, true)
+	}
+	return r.rule.After(t, false)
+}
+
+func (r *rruleRule) Location() *time.Location {
+	return r.loc
+}
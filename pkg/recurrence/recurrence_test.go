@@ -0,0 +1,159 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load location %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestParse(t *testing.T) {
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		kind       Kind
+		expression string
+		timezone   string
+		wantErr    bool
+	}{
+		{name: "cron", kind: KindCron, expression: "0 9 * * 1", timezone: "America/Sao_Paulo"},
+		{name: "cron descriptor", kind: KindCron, expression: "@daily", timezone: "UTC"},
+		{name: "rrule", kind: KindRRule, expression: "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9", timezone: "America/Sao_Paulo"},
+		{name: "rrule with prefix", kind: KindRRule, expression: "RRULE:FREQ=DAILY;COUNT=3", timezone: "UTC"},
+		{name: "surrounding spaces", kind: KindCron, expression: "  0 9 * * 1  ", timezone: "UTC"},
+		{name: "empty expression", kind: KindCron, expression: "   ", timezone: "UTC", wantErr: true},
+		{name: "unknown timezone", kind: KindCron, expression: "0 9 * * 1", timezone: "Mars/Olympus", wantErr: true},
+		{name: "invalid cron", kind: KindCron, expression: "61 9 * * 1", timezone: "UTC", wantErr: true},
+		{name: "cron with seconds", kind: KindCron, expression: "0 0 9 * * 1", timezone: "UTC", wantErr: true},
+		{name: "invalid rrule", kind: KindRRule, expression: "FREQ=SOMETIMES", timezone: "UTC", wantErr: true},
+		{name: "unknown kind", kind: Kind("ical"), expression: "0 9 * * 1", timezone: "UTC", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.kind, tt.expression, tt.timezone, start)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRule) {
+					t.Fatalf("Parse() error = %v, want ErrInvalidRule", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() unexpected error: %v", err)
+			}
+			if rule.Location().String() != tt.timezone {
+				t.Errorf("Location() = %s, want %s", rule.Location(), tt.timezone)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	saoPaulo := mustLoad(t, "America/Sao_Paulo")
+	// Segunda-feira, 2 de março de 2026, 09:37:22 em São Paulo
+	start := time.Date(2026, 3, 2, 9, 37, 22, 0, saoPaulo)
+
+	tests := []struct {
+		name       string
+		kind       Kind
+		expression string
+		start      time.Time
+		after      time.Time
+		want       time.Time
+	}{
+		{
+			name:       "rrule BYHOUR fires on the hour",
+			kind:       KindRRule,
+			expression: "FREQ=WEEKLY;BYDAY=MO;BYHOUR=9",
+			start:      start,
+			after:      start,
+			want:       time.Date(2026, 3, 9, 9, 0, 0, 0, saoPaulo),
+		},
+		{
+			name:       "rrule BYHOUR later on the start day",
+			kind:       KindRRule,
+			expression: "FREQ=WEEKLY;BYDAY=MO;BYHOUR=18",
+			start:      start,
+			after:      start.Add(-time.Hour),
+			want:       time.Date(2026, 3, 2, 18, 0, 0, 0, saoPaulo),
+		},
+		{
+			name:       "rrule BYMINUTE is kept",
+			kind:       KindRRule,
+			expression: "FREQ=DAILY;BYHOUR=8;BYMINUTE=30",
+			start:      start,
+			after:      start,
+			want:       time.Date(2026, 3, 3, 8, 30, 0, 0, saoPaulo),
+		},
+		{
+			name:       "rrule without BYHOUR fires at midnight",
+			kind:       KindRRule,
+			expression: "FREQ=DAILY",
+			start:      start,
+			after:      start,
+			want:       time.Date(2026, 3, 3, 0, 0, 0, 0, saoPaulo),
+		},
+		{
+			name:       "rrule skips occurrences before start",
+			kind:       KindRRule,
+			expression: "FREQ=DAILY;BYHOUR=9",
+			start:      start,
+			after:      time.Date(2026, 3, 1, 0, 0, 0, 0, saoPaulo),
+			want:       time.Date(2026, 3, 3, 9, 0, 0, 0, saoPaulo),
+		},
+		{
+			name:       "rrule exhausted by UNTIL",
+			kind:       KindRRule,
+			expression: "FREQ=DAILY;BYHOUR=9;UNTIL=20260304T000000Z",
+			start:      start,
+			after:      time.Date(2026, 3, 3, 9, 0, 0, 0, saoPaulo),
+			want:       time.Time{},
+		},
+		{
+			name:       "cron in the rule timezone",
+			kind:       KindCron,
+			expression: "0 9 * * 1",
+			start:      start,
+			after:      start,
+			want:       time.Date(2026, 3, 9, 9, 0, 0, 0, saoPaulo),
+		},
+		{
+			name:       "cron first occurrence can be the start",
+			kind:       KindCron,
+			expression: "0 9 * * *",
+			start:      time.Date(2026, 3, 2, 9, 0, 0, 0, saoPaulo),
+			after:      time.Date(2026, 3, 1, 0, 0, 0, 0, saoPaulo),
+			want:       time.Date(2026, 3, 2, 9, 0, 0, 0, saoPaulo),
+		},
+		{
+			name:       "cron is strictly after t",
+			kind:       KindCron,
+			expression: "0 9 * * *",
+			start:      start,
+			after:      time.Date(2026, 3, 3, 9, 0, 0, 0, saoPaulo),
+			want:       time.Date(2026, 3, 4, 9, 0, 0, 0, saoPaulo),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.kind, tt.expression, "America/Sao_Paulo", tt.start)
+			if err != nil {
+				t.Fatalf("Parse() unexpected error: %v", err)
+			}
+			got := rule.Next(tt.after)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.after, got, tt.want)
+			}
+		})
+	}
+}
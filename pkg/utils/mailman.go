@@ -34,20 +34,31 @@ func NewMailmanClient(url, apiKey string) *MailmanClient {
 	}
 }
 
-func (m *MailmanClient) SendEmail(req *MailmanRequest) error {
+// mailmanResponse cobre os nomes usados pelo relay para o identificador da mensagem
+type mailmanResponse struct {
+	MessageID      string `json:"message_id"`
+	MessageIDCamel string `json:"messageId"`
+	SESMessageID   string `json:"MessageId"`
+	ID             string `json:"id"`
+}
+
+// SendEmail envia o email pelo relay e retorna o identificador da mensagem no provedor,
+// usado para relacionar os eventos de bounce/complaint recebidos pelo webhook.
+// O identificador é vazio se o relay não o informar.
+func (m *MailmanClient) SendEmail(req *MailmanRequest) (string, error) {
 	if len(req.ToAddresses) == 0 {
-		return fmt.Errorf("to_addresses is required")
+		return "", fmt.Errorf("to_addresses is required")
 	}
 	if req.Subject == "" {
-		return fmt.Errorf("subject is required")
+		return "", fmt.Errorf("subject is required")
 	}
 	if req.Body == "" {
-		return fmt.Errorf("body is required")
+		return "", fmt.Errorf("body is required")
 	}
 
 	jsonData, err := json.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	baseURL := strings.TrimSuffix(m.url, "/")
@@ -58,7 +69,7 @@ func (m *MailmanClient) SendEmail(req *MailmanRequest) error {
 
 	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	httpReq.Header.Set("accept", "application/json")
@@ -68,17 +79,27 @@ func (m *MailmanClient) SendEmail(req *MailmanRequest) error {
 	resp, err := m.client.Do(httpReq)
 	if err != nil {
 		log.Printf("Mailman: HTTP request failed: %v", err)
-		return fmt.Errorf("failed to send request: %w", err)
+		return "", fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	log.Printf("Mailman: Response status: %d", resp.StatusCode)
 
+	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusAccepted {
-		body, _ := io.ReadAll(resp.Body)
 		log.Printf("Mailman: Error response body: %s", string(body))
-		return fmt.Errorf("email service returned status %d: %s", resp.StatusCode, string(body))
+		return "", fmt.Errorf("email service returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	return nil
+	var parsed mailmanResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		log.Printf("Mailman: Response without message id: %s", string(body))
+		return "", nil
+	}
+	for _, id := range []string{parsed.MessageID, parsed.MessageIDCamel, parsed.SESMessageID, parsed.ID} {
+		if id != "" {
+			return id, nil
+		}
+	}
+	return "", nil
 }
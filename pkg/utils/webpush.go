@@ -2,14 +2,20 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 
 	webpush "github.com/SherClockHolmes/webpush-go"
 	"github.com/prefeitura-rio/app-notification-core/internal/config"
 	"github.com/prefeitura-rio/app-notification-core/internal/entity"
 )
 
+// ErrPushRejected indica que o serviço de push recusou a subscription ou a mensagem (4xx,
+// exceto 429, ex: 410 para subscriptions canceladas); repetir o envio não adianta
+var ErrPushRejected = errors.New("push service rejected the notification")
+
 type WebPushClient struct {
 	config *config.Config
 }
@@ -64,6 +70,9 @@ func (w *WebPushClient) SendPush(subscription *entity.Subscription, notification
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return fmt.Errorf("%w: status %d", ErrPushRejected, resp.StatusCode)
+	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return fmt.Errorf("push service returned status %d", resp.StatusCode)
 	}
@@ -0,0 +1,195 @@
+package validation
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+const (
+	// DefaultCountryCode é o DDI usado quando o telefone não informa o país
+	DefaultCountryCode = "55"
+	// DefaultAreaCode é o DDD usado quando o telefone não informa a área (Rio de Janeiro)
+	DefaultAreaCode = "21"
+)
+
+var (
+	ErrInvalidCPF   = errors.New("invalid CPF")
+	ErrInvalidPhone = errors.New("invalid phone number")
+	ErrInvalidEmail = errors.New("invalid email address")
+)
+
+// IsValidationError indica se o erro foi causado por um identificador inválido
+func IsValidationError(err error) bool {
+	return errors.Is(err, ErrInvalidCPF) ||
+		errors.Is(err, ErrInvalidPhone) ||
+		errors.Is(err, ErrInvalidEmail)
+}
+
+// NormalizeCPF remove a pontuação do CPF e verifica os dígitos verificadores.
+// Retorna apenas os 11 dígitos, por exemplo "123.456.789-09" -> "12345678909".
+func NormalizeCPF(cpf string) (string, error) {
+	digits := onlyDigits(cpf)
+	if len(digits) != 11 {
+		return "", ErrInvalidCPF
+	}
+
+	// Sequências repetidas (000.000.000-00, 111.111.111-11...) passam no cálculo mas são inválidas
+	if strings.Count(digits, digits[:1]) == len(digits) {
+		return "", ErrInvalidCPF
+	}
+
+	if cpfCheckDigit(digits[:9]) != digits[9] || cpfCheckDigit(digits[:10]) != digits[10] {
+		return "", ErrInvalidCPF
+	}
+
+	return digits, nil
+}
+
+// IsValidCPF indica se o CPF possui dígitos verificadores válidos
+func IsValidCPF(cpf string) bool {
+	_, err := NormalizeCPF(cpf)
+	return err == nil
+}
+
+// NormalizePhone converte o telefone para o formato E.164 (+5521999998888).
+// Números sem DDI recebem +55 e números sem DDD recebem o DDD 21.
+func NormalizePhone(phone string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	international := strings.HasPrefix(phone, "+") || strings.HasPrefix(phone, "00")
+
+	digits := onlyDigits(phone)
+	if strings.HasPrefix(phone, "00") {
+		digits = digits[2:]
+	}
+
+	if international {
+		if !strings.HasPrefix(digits, DefaultCountryCode) {
+			// Números estrangeiros: apenas valida o tamanho permitido pelo E.164
+			if len(digits) < 8 || len(digits) > 15 {
+				return "", ErrInvalidPhone
+			}
+			return "+" + digits, nil
+		}
+		digits = digits[len(DefaultCountryCode):]
+	} else {
+		// Prefixo de discagem nacional (0 + DDD)
+		digits = strings.TrimLeft(digits, "0")
+		if (len(digits) == 12 || len(digits) == 13) && strings.HasPrefix(digits, DefaultCountryCode) {
+			digits = digits[len(DefaultCountryCode):]
+		}
+	}
+
+	switch len(digits) {
+	case 8, 9:
+		digits = DefaultAreaCode + digits
+	case 10, 11:
+	default:
+		return "", ErrInvalidPhone
+	}
+
+	// DDD não começa com zero e celulares (9 dígitos) começam com 9
+	if digits[0] == '0' || (len(digits) == 11 && digits[2] != '9') {
+		return "", ErrInvalidPhone
+	}
+
+	return "+" + DefaultCountryCode + digits, nil
+}
+
+// NormalizeEmail remove espaços, converte para minúsculas e valida a sintaxe do email
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", ErrInvalidEmail
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return "", ErrInvalidEmail
+	}
+
+	at := strings.LastIndex(email, "@")
+	domain := email[at+1:]
+	if at < 1 || !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", ErrInvalidEmail
+	}
+
+	return email, nil
+}
+
+// NormalizeIdentity normaliza os identificadores informados, mantendo vazios os ausentes
+func NormalizeIdentity(cpf, phone, email string) (string, string, string, error) {
+	var err error
+	if strings.TrimSpace(cpf) != "" {
+		if cpf, err = NormalizeCPF(cpf); err != nil {
+			return "", "", "", err
+		}
+	} else {
+		cpf = ""
+	}
+	if strings.TrimSpace(phone) != "" {
+		if phone, err = NormalizePhone(phone); err != nil {
+			return "", "", "", err
+		}
+	} else {
+		phone = ""
+	}
+	if strings.TrimSpace(email) != "" {
+		if email, err = NormalizeEmail(email); err != nil {
+			return "", "", "", err
+		}
+	} else {
+		email = ""
+	}
+	return cpf, phone, email, nil
+}
+
+// SanitizeIdentity normaliza os identificadores descartando os inválidos, útil para
+// dados de terceiros (como claims do JWT) que não devem impedir a requisição
+func SanitizeIdentity(cpf, phone, email string) (string, string, string) {
+	cpf, _ = NormalizeCPF(cpf)
+	phone, _ = NormalizePhone(phone)
+	email, _ = NormalizeEmail(email)
+	return cpf, phone, email
+}
+
+// ClassifyIdentifier identifica se um valor avulso é um email, um CPF ou um telefone,
+// já normalizado. Onze dígitos com dígitos verificadores válidos são tratados como CPF;
+// caso contrário, o valor é interpretado como telefone.
+func ClassifyIdentifier(identifier string) (cpf, phone, email string, err error) {
+	identifier = strings.TrimSpace(identifier)
+	if strings.Contains(identifier, "@") {
+		email, err = NormalizeEmail(identifier)
+		return "", "", email, err
+	}
+	if !strings.HasPrefix(identifier, "+") && IsValidCPF(identifier) {
+		cpf, err = NormalizeCPF(identifier)
+		return cpf, "", "", err
+	}
+	phone, err = NormalizePhone(identifier)
+	return "", phone, "", err
+}
+
+func cpfCheckDigit(digits string) byte {
+	sum := 0
+	weight := len(digits) + 1
+	for i := 0; i < len(digits); i++ {
+		sum += int(digits[i]-'0') * weight
+		weight--
+	}
+	rest := (sum * 10) % 11
+	if rest == 10 {
+		rest = 0
+	}
+	return byte('0' + rest)
+}
+
+func onlyDigits(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,219 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeCPF(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		valid bool
+	}{
+		{name: "formatted", input: "123.456.789-09", want: "12345678909", valid: true},
+		{name: "unformatted", input: "52998224725", want: "52998224725", valid: true},
+		{name: "surrounding spaces", input: "  111.444.777-35 ", want: "11144477735", valid: true},
+		{name: "mixed punctuation", input: "529 982 247/25", want: "52998224725", valid: true},
+		{name: "wrong first check digit", input: "123.456.789-19", valid: false},
+		{name: "wrong second check digit", input: "123.456.789-00", valid: false},
+		{name: "repeated ones", input: "111.111.111-11", valid: false},
+		{name: "repeated zeros", input: "00000000000", valid: false},
+		{name: "repeated nines", input: "999.999.999-99", valid: false},
+		{name: "too short", input: "1234567890", valid: false},
+		{name: "too long", input: "123456789091", valid: false},
+		{name: "empty", input: "", valid: false},
+		{name: "letters", input: "abc.def.ghi-jk", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeCPF(tt.input)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidCPF) {
+					t.Fatalf("NormalizeCPF(%q) error = %v, want ErrInvalidCPF", tt.input, err)
+				}
+				if IsValidCPF(tt.input) {
+					t.Errorf("IsValidCPF(%q) = true, want false", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeCPF(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeCPF(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if !IsValidCPF(tt.input) {
+				t.Errorf("IsValidCPF(%q) = false, want true", tt.input)
+			}
+		})
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		valid bool
+	}{
+		{name: "mobile with area code", input: "21 99999-8888", want: "+5521999998888", valid: true},
+		{name: "mobile with parentheses", input: "(21) 99999-8888", want: "+5521999998888", valid: true},
+		{name: "mobile unformatted", input: "21999998888", want: "+5521999998888", valid: true},
+		{name: "mobile with +55", input: "+55 21 99999-8888", want: "+5521999998888", valid: true},
+		{name: "mobile with 55 without plus", input: "5521999998888", want: "+5521999998888", valid: true},
+		{name: "mobile with 00 prefix", input: "0055 21 99999 8888", want: "+5521999998888", valid: true},
+		{name: "national dialing prefix", input: "021 99999-8888", want: "+5521999998888", valid: true},
+		{name: "mobile without area code", input: "99999-8888", want: "+5521999998888", valid: true},
+		{name: "other area code", input: "(11) 98765-4321", want: "+5511987654321", valid: true},
+		{name: "without leading 9", input: "21 9999-8888", want: "+552199998888", valid: true},
+		{name: "without leading 9 with +55", input: "+55 21 9999-8888", want: "+552199998888", valid: true},
+		{name: "landline without area code", input: "3333-4444", want: "+552133334444", valid: true},
+		{name: "landline with area code", input: "(21) 3333-4444", want: "+552133334444", valid: true},
+		{name: "foreign number", input: "+1 415 555 2671", want: "+14155552671", valid: true},
+		{name: "11 digits not starting with 9", input: "21 89999-8888", valid: false},
+		{name: "too short", input: "12345", valid: false},
+		{name: "too long", input: "21 99999-8888-1", valid: false},
+		{name: "foreign too short", input: "+1 234", valid: false},
+		{name: "empty", input: "", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizePhone(tt.input)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidPhone) {
+					t.Fatalf("NormalizePhone(%q) = %q, %v, want ErrInvalidPhone", tt.input, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizePhone(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizePhone(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		valid bool
+	}{
+		{name: "lowercase", input: "joao.silva@example.com", want: "joao.silva@example.com", valid: true},
+		{name: "mixed case", input: "Joao.Silva@Example.COM", want: "joao.silva@example.com", valid: true},
+		{name: "surrounding whitespace", input: "  maria@rio.rj.gov.br\t", want: "maria@rio.rj.gov.br", valid: true},
+		{name: "plus tag", input: "Maria+Avisos@Example.com", want: "maria+avisos@example.com", valid: true},
+		{name: "empty", input: "", valid: false},
+		{name: "only spaces", input: "   ", valid: false},
+		{name: "missing at", input: "joao.example.com", valid: false},
+		{name: "missing local part", input: "@example.com", valid: false},
+		{name: "domain without dot", input: "joao@localhost", valid: false},
+		{name: "domain starting with dot", input: "joao@.example.com", valid: false},
+		{name: "display name", input: "Joao <joao@example.com>", valid: false},
+		{name: "inner space", input: "joao silva@example.com", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeEmail(tt.input)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidEmail) {
+					t.Fatalf("NormalizeEmail(%q) = %q, %v, want ErrInvalidEmail", tt.input, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeEmail(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeEmail(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeIdentity(t *testing.T) {
+	tests := []struct {
+		name                         string
+		cpf, phone, email            string
+		wantCPF, wantPhone, wantMail string
+		wantErr                      error
+	}{
+		{
+			name: "all identifiers", cpf: "123.456.789-09", phone: "(21) 99999-8888", email: " Joao@Example.com ",
+			wantCPF: "12345678909", wantPhone: "+5521999998888", wantMail: "joao@example.com",
+		},
+		{name: "blank values stay empty", cpf: "  ", phone: "", email: "\t", wantCPF: "", wantPhone: "", wantMail: ""},
+		{name: "only phone", phone: "99999-8888", wantPhone: "+5521999998888"},
+		{name: "invalid cpf", cpf: "111.111.111-11", phone: "21999998888", wantErr: ErrInvalidCPF},
+		{name: "invalid phone", phone: "123", wantErr: ErrInvalidPhone},
+		{name: "invalid email", email: "joao@localhost", wantErr: ErrInvalidEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpf, phone, email, err := NormalizeIdentity(tt.cpf, tt.phone, tt.email)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || !IsValidationError(err) {
+					t.Fatalf("NormalizeIdentity() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeIdentity() unexpected error: %v", err)
+			}
+			if cpf != tt.wantCPF || phone != tt.wantPhone || email != tt.wantMail {
+				t.Errorf("NormalizeIdentity() = %q, %q, %q, want %q, %q, %q", cpf, phone, email, tt.wantCPF, tt.wantPhone, tt.wantMail)
+			}
+		})
+	}
+}
+
+func TestClassifyIdentifier(t *testing.T) {
+	tests := []struct {
+		name                         string
+		input                        string
+		wantCPF, wantPhone, wantMail string
+		wantErr                      bool
+	}{
+		{name: "email", input: " Joao@Example.com", wantMail: "joao@example.com"},
+		{name: "formatted cpf", input: "123.456.789-09", wantCPF: "12345678909"},
+		{name: "unformatted cpf", input: "52998224725", wantCPF: "52998224725"},
+		{name: "eleven digits with invalid cpf are a phone", input: "21999998888", wantPhone: "+5521999998888"},
+		{name: "plus sign forces phone", input: "+5521999998888", wantPhone: "+5521999998888"},
+		{name: "invalid email", input: "joao@", wantErr: true},
+		{name: "invalid phone", input: "123", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpf, phone, email, err := ClassifyIdentifier(tt.input)
+			if tt.wantErr {
+				if !IsValidationError(err) {
+					t.Fatalf("ClassifyIdentifier(%q) error = %v, want a validation error", tt.input, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ClassifyIdentifier(%q) unexpected error: %v", tt.input, err)
+			}
+			if cpf != tt.wantCPF || phone != tt.wantPhone || email != tt.wantMail {
+				t.Errorf("ClassifyIdentifier(%q) = %q, %q, %q, want %q, %q, %q", tt.input, cpf, phone, email, tt.wantCPF, tt.wantPhone, tt.wantMail)
+			}
+		})
+	}
+}
+
+func TestSanitizeIdentity(t *testing.T) {
+	cpf, phone, email := SanitizeIdentity("111.111.111-11", "(21) 99999-8888", "not-an-email")
+	if cpf != "" || phone != "+5521999998888" || email != "" {
+		t.Errorf("SanitizeIdentity() = %q, %q, %q, want \"\", \"+5521999998888\", \"\"", cpf, phone, email)
+	}
+}